@@ -11,8 +11,10 @@ import (
 
 	"zeem/internal/config"
 	"zeem/internal/handlers"
+	"zeem/internal/models"
 	"zeem/internal/services"
 	"zeem/internal/static"
+	"zeem/internal/store"
 )
 
 func main() {
@@ -32,10 +34,73 @@ func main() {
 	// Log allowed origins
 	log.Printf("Allowed origins: %v\n", cfg.AllowedOrigins)
 
+	// Initialize storage
+	var roomStore store.Store
+	switch cfg.StoreDriver {
+	case "bolt":
+		boltStore, err := store.NewBoltStore(cfg.StorePath)
+		if err != nil {
+			log.Fatal("Failed to open store: ", err)
+		}
+		roomStore = boltStore
+	default:
+		roomStore = store.NewMemoryStoreWithChatLimit(cfg.ChatHistorySize)
+	}
+	defer roomStore.Close()
+	log.Printf("Using %s store\n", cfg.StoreDriver)
+
 	// Initialize services
-	roomManager := services.NewRoomManager()
+	roomManager := services.NewRoomManagerWithStore(roomStore)
+	roomManager.SetDefaultSettings(models.RoomSettings{
+		StageSlots:             cfg.StageSlots,
+		BroadcasterGracePeriod: time.Duration(cfg.BroadcasterGraceSeconds) * time.Second,
+		ChatHistorySize:        cfg.ChatHistorySize,
+		ChatJoinHistory:        cfg.ChatJoinHistory,
+		TypingTimeout:          time.Duration(cfg.TypingTimeoutSeconds) * time.Second,
+		GroupCapacity:          cfg.GroupCapacity,
+		Overflow:               models.OverflowPolicy(cfg.RoomOverflow),
+	})
+	roomManager.SetEmptyRoomTTL(time.Duration(cfg.RoomEmptyTTLMinutes) * time.Minute)
+	if err := roomManager.Restore(); err != nil {
+		log.Fatal("Failed to restore rooms: ", err)
+	}
 	webrtcManager := services.NewWebRTCManager()
 	wsHandler := handlers.NewWebSocketHandler(roomManager, webrtcManager)
+	wsHandler.SetChatModerator(services.NewChatModerator(
+		services.SanitizeFilter(),
+		services.MaxLengthFilter(cfg.ChatMaxLength),
+		services.LinkFilter(services.LinkPolicy(cfg.ChatLinkPolicy)),
+		services.BlocklistFilter(cfg.ChatBlocklist),
+		services.RateLimitFilter(cfg.ChatRateLimit, time.Duration(cfg.ChatRateWindowSeconds)*time.Second),
+	))
+	reactionEmojis := cfg.ReactionEmojis
+	if len(reactionEmojis) == 0 {
+		reactionEmojis = services.DefaultReactionEmojis
+	}
+	wsHandler.SetReactionConfig(services.ReactionConfig{
+		Allowed:    reactionEmojis,
+		RateLimit:  cfg.ReactionRateLimit,
+		RateWindow: time.Duration(cfg.ReactionWindowSeconds) * time.Second,
+		Interval:   time.Duration(cfg.ReactionIntervalMillis) * time.Millisecond,
+	})
+	sfuManager := services.NewSFUManager()
+	sfuManager.SetLastN(cfg.LastN)
+	wsHandler.SetSFUManager(sfuManager, time.Duration(cfg.SpeakerIntervalMillis)*time.Millisecond)
+	roomHandler := handlers.NewRoomHandler(roomManager)
+
+	fileStore, err := store.NewDiskFileStore(cfg.FileStoragePath)
+	if err != nil {
+		log.Fatal("Failed to open file storage: ", err)
+	}
+	allowedTypes := cfg.FileAllowedTypes
+	if len(allowedTypes) == 0 {
+		allowedTypes = services.DefaultFileTypes
+	}
+	fileManager := services.NewFileManager(fileStore, int64(cfg.FileMaxBytes), allowedTypes,
+		time.Duration(cfg.FileRetentionHours)*time.Hour)
+	wsHandler.SetFileManager(fileManager)
+	fileHandler := handlers.NewFileHandler(wsHandler, fileManager)
+	metadataHandler := handlers.NewMetadataHandler(wsHandler)
 
 	router := gin.Default()
 
@@ -77,7 +142,7 @@ func main() {
 
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		// Security headers
 		c.Writer.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
@@ -111,6 +176,17 @@ func main() {
 	// WebSocket endpoint
 	router.GET("/ws", wsHandler.HandleConnection)
 
+	// REST API
+	api := router.Group("/api")
+	api.POST("/rooms", roomHandler.CreateRoom)
+	api.GET("/rooms/:roomId", roomHandler.GetRoom)
+	api.GET("/rooms/:roomId/chat", roomHandler.GetChatHistory)
+	api.GET("/rooms/:roomId/chat/export", roomHandler.ExportChat)
+	api.POST("/rooms/:roomId/files", fileHandler.Upload)
+	api.GET("/rooms/:roomId/files/:fileId", fileHandler.Download)
+	api.PATCH("/rooms/:roomId/metadata", metadataHandler.SetRoomMetadata)
+	api.PATCH("/rooms/:roomId/participants/:participantId/attributes", metadataHandler.SetParticipantAttributes)
+
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
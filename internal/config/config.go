@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -10,6 +11,56 @@ type Config struct {
 	AllowedOrigins []string
 	Environment    string
 	Host           string
+	// StageSlots is how many viewers may be on a broadcast stage at once
+	StageSlots int
+	// BroadcasterGraceSeconds is how long a broadcast waits for a disconnected broadcaster
+	BroadcasterGraceSeconds int
+	// ChatHistorySize is the number of public chat messages each room keeps
+	ChatHistorySize int
+	// ChatJoinHistory is the number of recent chat messages sent on join
+	ChatJoinHistory int
+	// TypingTimeoutSeconds is how long a typing indicator lasts without a refresh
+	TypingTimeoutSeconds int
+	// GroupCapacity is the default number of participants in a group room
+	GroupCapacity int
+	// RoomOverflow is what happens to joiners of a full room: "reject", "queue" or "viewer"
+	RoomOverflow string
+	// RoomEmptyTTLMinutes is how long a room may stay empty before it is unloaded from memory; 0 keeps rooms
+	RoomEmptyTTLMinutes int
+	// StoreDriver selects the storage backend: "memory" or "bolt"
+	StoreDriver string
+	// StorePath is the database file used by file-based storage backends
+	StorePath string
+	// ChatMaxLength is the maximum number of characters in a chat message
+	ChatMaxLength int
+	// ChatRateLimit is the number of chat messages a sender may send per ChatRateWindowSeconds
+	ChatRateLimit int
+	// ChatRateWindowSeconds is the sliding window of the chat rate limit
+	ChatRateWindowSeconds int
+	// ChatBlocklist holds words that are masked in chat messages
+	ChatBlocklist []string
+	// ChatLinkPolicy is what happens to links in chat: "allow", "strip" or "block"
+	ChatLinkPolicy string
+	// ReactionEmojis holds the allowed live reactions; empty means the built-in set
+	ReactionEmojis []string
+	// ReactionRateLimit is the number of reactions a participant may send per ReactionWindowSeconds
+	ReactionRateLimit int
+	// ReactionWindowSeconds is the sliding window of the reaction rate limit
+	ReactionWindowSeconds int
+	// ReactionIntervalMillis is how long reactions are aggregated before a burst is broadcast
+	ReactionIntervalMillis int
+	// SpeakerIntervalMillis is how often rooms are told their active speakers
+	SpeakerIntervalMillis int
+	// LastN is how many recent speakers' video each SFU subscriber receives; 0 means everyone's
+	LastN int
+	// FileStoragePath is the directory that holds files shared in room chat
+	FileStoragePath string
+	// FileMaxBytes is the maximum size of a shared file
+	FileMaxBytes int
+	// FileAllowedTypes holds the MIME types that may be shared; empty means the built-in list
+	FileAllowedTypes []string
+	// FileRetentionHours is how long shared files are kept after a room empties
+	FileRetentionHours int
 }
 
 func New() *Config {
@@ -21,12 +72,77 @@ func New() *Config {
 	allowedOrigins := strings.Split(getEnv("ALLOWED_ORIGINS", "*"), ",")
 	environment := getEnv("ENV", "development")
 	host := getEnv("HOST", "0.0.0.0")
+	stageSlots := getEnvInt("STAGE_SLOTS", 3)
+	broadcasterGraceSeconds := getEnvInt("BROADCASTER_GRACE_SECONDS", 30)
+	chatHistorySize := getEnvInt("CHAT_HISTORY_SIZE", 500)
+	chatJoinHistory := getEnvInt("CHAT_JOIN_HISTORY", 50)
+	typingTimeoutSeconds := getEnvInt("TYPING_TIMEOUT_SECONDS", 5)
+	groupCapacity := getEnvInt("GROUP_CAPACITY", 8)
+	roomOverflow := getEnv("ROOM_OVERFLOW", "reject")
+	roomEmptyTTLMinutes := getEnvInt("ROOM_EMPTY_TTL_MINUTES", 0)
+	storeDriver := getEnv("STORE_DRIVER", "memory")
+	storePath := getEnv("STORE_PATH", "zeem.db")
+	chatMaxLength := getEnvInt("CHAT_MAX_LENGTH", 2000)
+	chatRateLimit := getEnvInt("CHAT_RATE_LIMIT", 5)
+	chatRateWindowSeconds := getEnvInt("CHAT_RATE_WINDOW_SECONDS", 10)
+	chatLinkPolicy := getEnv("CHAT_LINK_POLICY", "allow")
+
+	reactionRateLimit := getEnvInt("REACTION_RATE_LIMIT", 10)
+	reactionWindowSeconds := getEnvInt("REACTION_RATE_WINDOW_SECONDS", 5)
+	reactionIntervalMillis := getEnvInt("REACTION_INTERVAL_MS", 500)
+	speakerIntervalMillis := getEnvInt("ACTIVE_SPEAKER_INTERVAL_MS", 500)
+	lastN := getEnvInt("LAST_N", 6)
+
+	var reactionEmojis []string
+	if emojis := getEnv("REACTION_EMOJIS", ""); emojis != "" {
+		reactionEmojis = strings.Split(emojis, ",")
+	}
+
+	fileStoragePath := getEnv("FILE_STORAGE_PATH", "uploads")
+	fileMaxBytes := getEnvInt("FILE_MAX_BYTES", 10<<20)
+	fileRetentionHours := getEnvInt("FILE_RETENTION_HOURS", 0)
+
+	var fileAllowedTypes []string
+	if allowedTypes := getEnv("FILE_ALLOWED_TYPES", ""); allowedTypes != "" {
+		fileAllowedTypes = strings.Split(allowedTypes, ",")
+	}
+
+	var chatBlocklist []string
+	if blocklist := getEnv("CHAT_BLOCKLIST", ""); blocklist != "" {
+		chatBlocklist = strings.Split(blocklist, ",")
+	}
 
 	return &Config{
 		Port:           port,
 		AllowedOrigins: allowedOrigins,
 		Environment:    environment,
 		Host:           host,
+		StageSlots:     stageSlots,
+
+		BroadcasterGraceSeconds: broadcasterGraceSeconds,
+		ChatHistorySize:         chatHistorySize,
+		ChatJoinHistory:         chatJoinHistory,
+		TypingTimeoutSeconds:    typingTimeoutSeconds,
+		GroupCapacity:           groupCapacity,
+		RoomOverflow:            roomOverflow,
+		RoomEmptyTTLMinutes:     roomEmptyTTLMinutes,
+		StoreDriver:             storeDriver,
+		StorePath:               storePath,
+		ChatMaxLength:           chatMaxLength,
+		ChatRateLimit:           chatRateLimit,
+		ChatRateWindowSeconds:   chatRateWindowSeconds,
+		ChatBlocklist:           chatBlocklist,
+		ChatLinkPolicy:          chatLinkPolicy,
+		ReactionEmojis:          reactionEmojis,
+		ReactionRateLimit:       reactionRateLimit,
+		ReactionWindowSeconds:   reactionWindowSeconds,
+		ReactionIntervalMillis:  reactionIntervalMillis,
+		SpeakerIntervalMillis:   speakerIntervalMillis,
+		LastN:                   lastN,
+		FileStoragePath:         fileStoragePath,
+		FileMaxBytes:            fileMaxBytes,
+		FileAllowedTypes:        fileAllowedTypes,
+		FileRetentionHours:      fileRetentionHours,
 	}
 }
 
@@ -36,3 +152,10 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"zeem/internal/models"
+)
+
+const (
+	// defaultBreakoutCountdown is how long breakout rooms count down before closing when no countdown is given
+	defaultBreakoutCountdown = 60 * time.Second
+	// breakoutLeaveGrace is how long participants of a closed breakout room
+	// have to follow move_to_room before they are disconnected
+	breakoutLeaveGrace = 10 * time.Second
+)
+
+// breakoutPayload is the data of breakout_* messages
+type breakoutPayload struct {
+	Count            int               `json:"count,omitempty"`            // breakout_open
+	Assignments      map[string]string `json:"assignments,omitempty"`      // breakout_assign: participant ID -> room ID
+	Auto             bool              `json:"auto,omitempty"`             // breakout_assign: spread everyone evenly
+	Text             string            `json:"text,omitempty"`             // breakout_broadcast
+	CountdownSeconds int               `json:"countdownSeconds,omitempty"` // breakout_close
+}
+
+// handleBreakoutMessage opens, fills, messages or closes the breakout rooms of a room
+func (h *WebSocketHandler) handleBreakoutMessage(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload breakoutPayload
+	if err := decodeData(msg.Data, &payload); err != nil {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+
+	var err error
+	switch msg.Type {
+	case "breakout_open":
+		if _, err = h.roomManager.OpenBreakouts(room, p.ID, payload.Count); err == nil {
+			h.broadcastBreakoutState(room, p.ID)
+		}
+
+	case "breakout_assign":
+		var assignments map[string]string
+		if payload.Auto {
+			assignments, err = room.AutoAssignBreakouts(p.ID)
+		} else {
+			assignments, err = room.AssignBreakouts(p.ID, payload.Assignments)
+		}
+		if err == nil {
+			h.broadcastBreakoutState(room, p.ID)
+			for participantID, roomID := range assignments {
+				h.sendMoveToRoom(room, participantID, roomID)
+			}
+		}
+
+	case "breakout_broadcast":
+		err = h.broadcastToBreakouts(room, p, payload.Text)
+
+	case "breakout_close":
+		countdown := time.Duration(payload.CountdownSeconds) * time.Second
+		if countdown <= 0 {
+			countdown = defaultBreakoutCountdown
+		}
+		err = h.closeBreakouts(room, p, countdown)
+	}
+	if err != nil {
+		h.sendError(p, err)
+	}
+}
+
+// broadcastToBreakouts sends a host's message to everyone in the breakout rooms
+func (h *WebSocketHandler) broadcastToBreakouts(room *models.Room, p *models.Participant, text string) error {
+	breakouts, err := room.ManagedBreakouts(p.ID)
+	if err != nil {
+		return err
+	}
+	if text, err = h.chatModerator.Moderate(p.ID, text); err != nil {
+		return err
+	}
+
+	h.forEachBreakout(breakouts, func(breakout *models.Room) {
+		h.broadcastToRoom(breakout, SignalingMessage{
+			Type:     "breakout_message",
+			RoomID:   breakout.ID,
+			SenderID: p.ID,
+			Data: map[string]interface{}{
+				"senderName":   p.Username,
+				"text":         text,
+				"parentRoomId": room.ID,
+			},
+		}, "")
+	})
+	return nil
+}
+
+// closeBreakouts announces the countdown in every breakout room and, once it
+// runs out, sends everyone back to the parent room. Each breakout is removed
+// once it is empty; anyone still in it after breakoutLeaveGrace is disconnected.
+func (h *WebSocketHandler) closeBreakouts(room *models.Room, p *models.Participant, countdown time.Duration) error {
+	closesAt := time.Now().Add(countdown)
+	if err := room.StartClosingBreakouts(p.ID, closesAt); err != nil {
+		return err
+	}
+	state := room.GetBreakouts()
+	h.broadcastBreakoutState(room, p.ID)
+	h.forEachBreakout(state.Rooms, func(breakout *models.Room) {
+		h.broadcastToRoom(breakout, SignalingMessage{
+			Type:     "breakout_closing",
+			RoomID:   breakout.ID,
+			SenderID: p.ID,
+			Data: map[string]interface{}{
+				"closesAt":         state.ClosesAt,
+				"countdownSeconds": int(countdown / time.Second),
+				"parentRoomId":     room.ID,
+			},
+		}, "")
+	})
+
+	time.AfterFunc(countdown, func() {
+		breakouts := room.EndBreakouts(closesAt)
+		if breakouts == nil {
+			return
+		}
+		for _, b := range breakouts {
+			h.roomManager.CloseBreakout(b.ID)
+		}
+		h.forEachBreakout(breakouts, func(breakout *models.Room) {
+			for _, participant := range breakout.GetParticipants() {
+				h.sendMoveToRoom(breakout, participant.ID, room.ID)
+			}
+		})
+		h.broadcastBreakoutState(room, p.ID)
+
+		time.AfterFunc(breakoutLeaveGrace, func() {
+			h.forEachBreakout(breakouts, func(breakout *models.Room) {
+				for _, participant := range breakout.GetParticipants() {
+					participant.Close()
+				}
+			})
+		})
+	})
+	return nil
+}
+
+// forEachBreakout calls fn for each open breakout room
+func (h *WebSocketHandler) forEachBreakout(breakouts []models.BreakoutRoom, fn func(*models.Room)) {
+	for _, b := range breakouts {
+		if breakout := h.roomManager.GetRoom(b.ID); breakout != nil {
+			fn(breakout)
+		}
+	}
+}
+
+// sendMoveToRoom tells a participant to leave room and join another one.
+// The client re-joins signaling and media with the new room ID.
+func (h *WebSocketHandler) sendMoveToRoom(room *models.Room, participantID, targetRoomID string) {
+	h.sendToParticipants(room, SignalingMessage{
+		Type:   "move_to_room",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"roomId": targetRoomID,
+		},
+	}, []string{participantID})
+}
+
+// broadcastBreakoutState sends the breakout rooms and assignments to the whole room
+func (h *WebSocketHandler) broadcastBreakoutState(room *models.Room, senderID string) {
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:     "breakout_state",
+		RoomID:   room.ID,
+		SenderID: senderID,
+		Data:     room.GetBreakouts(),
+	}, "")
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"zeem/internal/models"
+)
+
+// handleBroadcasterLeft announces what happens to a broadcast after its broadcaster disconnected:
+// either a stage participant took over, or the broadcast is paused for the grace period.
+func (h *WebSocketHandler) handleBroadcasterLeft(room *models.Room, formerID string) {
+	switch room.GetBroadcastStatus() {
+	case models.BroadcastLive:
+		h.broadcastHandover(room, formerID, room.GetBroadcasterID())
+
+	case models.BroadcastPaused:
+		pausedAt := room.GetPausedAt()
+		grace := room.Settings.BroadcasterGracePeriod
+
+		h.broadcastToRoom(room, SignalingMessage{
+			Type:     "broadcast_paused",
+			RoomID:   room.ID,
+			SenderID: formerID,
+			Data: map[string]interface{}{
+				"reason":             "broadcaster_reconnecting",
+				"gracePeriodSeconds": int(grace / time.Second),
+				"resumeBy":           pausedAt.Add(grace).Unix(),
+			},
+		}, "")
+
+		time.AfterFunc(grace, func() {
+			if !room.EndPausedBroadcast(pausedAt) {
+				return
+			}
+			h.broadcastToRoom(room, SignalingMessage{
+				Type:     "broadcast_ended",
+				RoomID:   room.ID,
+				SenderID: formerID,
+			}, "")
+		})
+	}
+}
+
+// handleTransferBroadcaster lets the broadcaster hand the broadcast to another participant
+func (h *WebSocketHandler) handleTransferBroadcaster(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var target targetPayload
+	if err := decodeData(msg.Data, &target); err != nil || target.ParticipantID == "" {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+
+	if err := room.TransferBroadcaster(p.ID, target.ParticipantID); err != nil {
+		h.sendError(p, err)
+		return
+	}
+
+	h.broadcastRoleChanged(room, p.ID)
+	h.broadcastHandover(room, p.ID, target.ParticipantID)
+}
+
+// broadcastHandover announces the new broadcaster to the room
+func (h *WebSocketHandler) broadcastHandover(room *models.Room, fromID, toID string) {
+	h.broadcastRoleChanged(room, toID)
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:     "broadcast_handover",
+		RoomID:   room.ID,
+		SenderID: toID,
+		Data: map[string]interface{}{
+			"from": fromID,
+			"to":   toID,
+		},
+	}, "")
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"zeem/internal/models"
+)
+
+const (
+	// defaultChatPageSize is the page size used when a history request doesn't set one
+	defaultChatPageSize = 50
+	// maxChatPageSize caps the page size of history requests
+	maxChatPageSize = 100
+)
+
+// chatHistoryRequest is the data of a chat_history_request message
+type chatHistoryRequest struct {
+	Before int64 `json:"before"`
+	Limit  int   `json:"limit"`
+}
+
+// chatPage is a page of chat history with the cursor of the preceding page
+type chatPage struct {
+	Messages   []models.ChatMessage `json:"messages"`
+	NextCursor int64                `json:"nextCursor,omitempty"`
+}
+
+// chatPageLimit clamps a requested page size
+func chatPageLimit(limit int) int {
+	if limit <= 0 {
+		return defaultChatPageSize
+	}
+	if limit > maxChatPageSize {
+		return maxChatPageSize
+	}
+	return limit
+}
+
+// getChatPage returns a page of the room's public chat history
+func getChatPage(room *models.Room, before int64, limit int) chatPage {
+	messages, cursor := room.GetChatPage(before, chatPageLimit(limit))
+	return chatPage{
+		Messages:   messages,
+		NextCursor: cursor,
+	}
+}
+
+// chatUpdatePayload is the data of chat_edit, chat_delete, chat_react and chat_edit_history messages
+type chatUpdatePayload struct {
+	MessageID string `json:"messageId"`
+	Content   string `json:"content,omitempty"`
+	Emoji     string `json:"emoji,omitempty"`
+}
+
+// directMessagePayload is the data of a direct_message message
+type directMessagePayload struct {
+	Content    string   `json:"content"`
+	Recipients []string `json:"recipients"`
+}
+
+// chatPayload is the object form of a chat message's data, used for replies.
+// Plain messages may send their content as a bare string instead.
+type chatPayload struct {
+	Content string `json:"content"`
+	ReplyTo string `json:"replyTo,omitempty"`
+}
+
+// handleChat stores a chat message and sends it to the whole room.
+// Messages starting with a slash are run as commands instead.
+func (h *WebSocketHandler) handleChat(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload chatPayload
+	if content, ok := msg.Data.(string); ok {
+		payload.Content = content
+	} else if err := decodeData(msg.Data, &payload); err != nil {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+	if isCommand(payload.Content) {
+		h.handleCommand(room, p, payload.Content)
+		return
+	}
+	if strings.HasPrefix(payload.Content, commandPrefix+commandPrefix) {
+		payload.Content = strings.TrimPrefix(payload.Content, commandPrefix)
+	}
+	content, err := h.chatModerator.Moderate(p.ID, payload.Content)
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+
+	chatMsg, err := room.AddChatMessage(models.ChatMessage{
+		SenderID:   p.ID,
+		SenderName: p.Username,
+		Content:    content,
+		Timestamp:  time.Now().Unix(),
+		ReplyTo:    payload.ReplyTo,
+	})
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	h.roomManager.SaveChatMessage(room.ID, chatMsg)
+	h.stopTyping(room, p.ID)
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:     "chat",
+		RoomID:   room.ID,
+		SenderID: p.ID,
+		Data:     chatMsg,
+	}, "")
+	h.notifyMentions(room, chatMsg)
+}
+
+// notifyMentions sends a mention notification to each participant @mentioned in a message
+func (h *WebSocketHandler) notifyMentions(room *models.Room, chatMsg models.ChatMessage) {
+	if len(chatMsg.Mentions) == 0 {
+		return
+	}
+	h.sendToParticipants(room, SignalingMessage{
+		Type:     "mention",
+		RoomID:   room.ID,
+		SenderID: chatMsg.SenderID,
+		Data:     chatMsg,
+	}, chatMsg.Mentions)
+}
+
+// handleDirectMessage stores a direct message and delivers it only to its
+// recipients and sender
+func (h *WebSocketHandler) handleDirectMessage(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload directMessagePayload
+	if err := decodeData(msg.Data, &payload); err != nil {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+	// Slash commands act on the whole room, so a direct message is always text
+	content, err := h.chatModerator.Moderate(p.ID, payload.Content)
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+
+	chatMsg, err := room.AddDirectMessage(models.ChatMessage{
+		SenderID:   p.ID,
+		SenderName: p.Username,
+		Content:    content,
+		Timestamp:  time.Now().Unix(),
+	}, payload.Recipients)
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	h.roomManager.SaveChatMessage(room.ID, chatMsg)
+
+	h.sendChatMessage(room, SignalingMessage{
+		Type:     "direct_message",
+		RoomID:   room.ID,
+		SenderID: p.ID,
+		Data:     chatMsg,
+	}, chatMsg)
+}
+
+// handleChatHistoryRequest sends a page of older chat messages to the requester
+func (h *WebSocketHandler) handleChatHistoryRequest(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var req chatHistoryRequest
+	if err := decodeData(msg.Data, &req); err != nil {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+
+	if err := p.Send(SignalingMessage{
+		Type:   "chat_history",
+		RoomID: room.ID,
+		Data:   getChatPage(room, req.Before, req.Limit),
+	}); err != nil {
+		log.Printf("Error sending chat history to participant %s: %v", p.ID, err)
+	}
+}
+
+// handleChatUpdate edits, deletes or reacts to an existing chat message and
+// sends the updated message to the whole room
+func (h *WebSocketHandler) handleChatUpdate(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload chatUpdatePayload
+	if err := decodeData(msg.Data, &payload); err != nil || payload.MessageID == "" {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+
+	var (
+		chatMsg models.ChatMessage
+		err     error
+	)
+	switch msg.Type {
+	case "chat_edit":
+		var content string
+		if content, err = h.chatModerator.Moderate(p.ID, payload.Content); err == nil {
+			chatMsg, err = room.EditChatMessage(p.ID, payload.MessageID, content)
+		}
+	case "chat_delete":
+		chatMsg, err = room.DeleteChatMessage(p.ID, payload.MessageID)
+	case "chat_react":
+		if payload.Emoji == "" {
+			return
+		}
+		if err = h.reactions.Validate(p.ID, payload.Emoji); err == nil {
+			chatMsg, err = room.ReactToChatMessage(p.ID, payload.MessageID, payload.Emoji)
+		}
+	}
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	h.roomManager.SaveChatMessage(room.ID, chatMsg)
+
+	h.sendChatMessage(room, SignalingMessage{
+		Type:     msg.Type,
+		RoomID:   room.ID,
+		SenderID: p.ID,
+		Data:     chatMsg,
+	}, chatMsg)
+}
+
+// handleChatEditHistory sends a moderator the previous versions of a message
+func (h *WebSocketHandler) handleChatEditHistory(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload chatUpdatePayload
+	if err := decodeData(msg.Data, &payload); err != nil || payload.MessageID == "" {
+		log.Printf("Invalid chat_edit_history from participant %s: %v", p.ID, err)
+		return
+	}
+	edits, err := room.GetChatEdits(p.ID, payload.MessageID)
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	if err := p.Send(SignalingMessage{
+		Type:   "chat_edit_history",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"messageId": payload.MessageID,
+			"edits":     edits,
+		},
+	}); err != nil {
+		log.Printf("Error sending chat edit history to participant %s: %v", p.ID, err)
+	}
+}
+
+// sendChatMessage delivers a message about chatMsg to everyone allowed to see it:
+// the whole room for public messages, the conversation members for direct messages
+func (h *WebSocketHandler) sendChatMessage(room *models.Room, msg SignalingMessage, chatMsg models.ChatMessage) {
+	if !chatMsg.IsDirect() {
+		h.broadcastToRoom(room, msg, "")
+		return
+	}
+	h.sendToParticipants(room, msg, append([]string{chatMsg.SenderID}, chatMsg.Recipients...))
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"zeem/internal/models"
+)
+
+// commandPrefix starts a slash command in chat. Doubling it ("//") posts a
+// message that starts with a literal slash.
+const commandPrefix = "/"
+
+// commandError is a failed slash command reported back to its sender
+type commandError struct {
+	Code    string
+	Message string
+}
+
+func (e *commandError) Error() string {
+	return e.Message
+}
+
+// commandContext is what a command handler gets to work with
+type commandContext struct {
+	room        *models.Room
+	participant *models.Participant
+	// args are the whitespace-separated words after the command name
+	args []string
+	// text is everything after the command name, with inner spacing kept
+	text string
+}
+
+// chatCommand is a slash command that can be typed in chat
+type chatCommand struct {
+	// Name is what follows the slash, e.g. "mute"
+	Name string
+	// Syntax shows how to call the command, e.g. "/mute @name"
+	Syntax string
+	// Description is shown in /help
+	Description string
+	// Allowed decides which roles may run the command; nil allows everyone
+	Allowed func(models.Role) bool
+	// Run executes the command
+	Run func(h *WebSocketHandler, ctx commandContext) error
+}
+
+// allows reports whether a participant with the given role may run the command
+func (c *chatCommand) allows(role models.Role) bool {
+	return c.Allowed == nil || c.Allowed(role)
+}
+
+// usageError reports a command called with the wrong arguments
+func (c *chatCommand) usageError() error {
+	return &commandError{Code: "invalid_command", Message: "usage: " + c.Syntax}
+}
+
+// commandRegistry holds the slash commands known to the server by name
+type commandRegistry struct {
+	commands map[string]*chatCommand
+}
+
+// newCommandRegistry creates a registry holding the given commands
+func newCommandRegistry(commands ...*chatCommand) *commandRegistry {
+	r := &commandRegistry{commands: make(map[string]*chatCommand)}
+	for _, c := range commands {
+		r.register(c)
+	}
+	return r
+}
+
+// register adds a command, replacing any command with the same name
+func (r *commandRegistry) register(c *chatCommand) {
+	r.commands[strings.ToLower(c.Name)] = c
+}
+
+// lookup returns the command with the given name
+func (r *commandRegistry) lookup(name string) (*chatCommand, bool) {
+	c, ok := r.commands[strings.ToLower(name)]
+	return c, ok
+}
+
+// available returns the commands a role may run, sorted by name
+func (r *commandRegistry) available(role models.Role) []*chatCommand {
+	commands := make([]*chatCommand, 0, len(r.commands))
+	for _, c := range r.commands {
+		if c.allows(role) {
+			commands = append(commands, c)
+		}
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// defaultCommands returns the built-in slash commands
+func defaultCommands() *commandRegistry {
+	return newCommandRegistry(
+		&chatCommand{
+			Name:        "help",
+			Syntax:      "/help",
+			Description: "List the commands you can use",
+			Run:         (*WebSocketHandler).runHelpCommand,
+		},
+		&chatCommand{
+			Name:        "raise",
+			Syntax:      "/raise",
+			Description: "Raise your hand to ask for the stage",
+			Run: func(h *WebSocketHandler, ctx commandContext) error {
+				h.handleHand(ctx.room, ctx.participant, true)
+				return nil
+			},
+		},
+		&chatCommand{
+			Name:        "lower",
+			Syntax:      "/lower",
+			Description: "Lower your hand",
+			Run: func(h *WebSocketHandler, ctx commandContext) error {
+				h.handleHand(ctx.room, ctx.participant, false)
+				return nil
+			},
+		},
+		&chatCommand{
+			Name:        "mute",
+			Syntax:      "/mute @name",
+			Description: "Turn off a participant's microphone",
+			Allowed:     models.Role.CanModerate,
+			Run:         (*WebSocketHandler).runMuteCommand,
+		},
+		&chatCommand{
+			Name:        "poll",
+			Syntax:      "/poll question | option | option...",
+			Description: "Start a single-choice poll",
+			Allowed:     models.Role.CanModerate,
+			Run:         (*WebSocketHandler).runPollCommand,
+		},
+		&chatCommand{
+			Name:        "topic",
+			Syntax:      "/topic [text]",
+			Description: "Set the room topic, or clear it without text",
+			Allowed:     models.Role.CanModerate,
+			Run:         (*WebSocketHandler).runTopicCommand,
+		},
+	)
+}
+
+// isCommand reports whether chat content is a slash command
+func isCommand(content string) bool {
+	return strings.HasPrefix(content, commandPrefix) && !strings.HasPrefix(content, commandPrefix+commandPrefix)
+}
+
+// handleCommand parses a slash command typed in chat and runs it.
+// Failures are sent back to the sender as errors.
+func (h *WebSocketHandler) handleCommand(room *models.Room, p *models.Participant, content string) {
+	line := strings.TrimPrefix(content, commandPrefix)
+	name, text, _ := strings.Cut(line, " ")
+	text = strings.TrimSpace(text)
+
+	command, ok := h.commands.lookup(name)
+	if !ok {
+		h.sendError(p, &commandError{
+			Code:    "unknown_command",
+			Message: fmt.Sprintf("unknown command /%s, type /help for a list", name),
+		})
+		return
+	}
+	view, ok := room.GetParticipantView(p.ID)
+	if !ok {
+		return
+	}
+	if !command.allows(view.Role) {
+		h.sendError(p, models.ErrPermissionDenied)
+		return
+	}
+
+	err := command.Run(h, commandContext{
+		room:        room,
+		participant: p,
+		args:        strings.Fields(text),
+		text:        text,
+	})
+	if err != nil {
+		h.sendError(p, err)
+	}
+}
+
+// commandHelp describes a command in the reply to /help
+type commandHelp struct {
+	Name        string `json:"name"`
+	Syntax      string `json:"syntax"`
+	Description string `json:"description"`
+}
+
+// runHelpCommand sends the sender the commands they may use
+func (h *WebSocketHandler) runHelpCommand(ctx commandContext) error {
+	view, _ := ctx.room.GetParticipantView(ctx.participant.ID)
+	available := h.commands.available(view.Role)
+	help := make([]commandHelp, 0, len(available))
+	for _, c := range available {
+		help = append(help, commandHelp{Name: c.Name, Syntax: c.Syntax, Description: c.Description})
+	}
+
+	if err := ctx.participant.Send(SignalingMessage{
+		Type:   "command_help",
+		RoomID: ctx.room.ID,
+		Data: map[string]interface{}{
+			"commands": help,
+		},
+	}); err != nil {
+		log.Printf("Error sending command help to participant %s: %v", ctx.participant.ID, err)
+	}
+	return nil
+}
+
+// runMuteCommand turns off the microphone of the participant named in the arguments
+func (h *WebSocketHandler) runMuteCommand(ctx commandContext) error {
+	command, _ := h.commands.lookup("mute")
+	if len(ctx.args) != 1 {
+		return command.usageError()
+	}
+	target, err := ctx.room.FindParticipant(strings.TrimPrefix(ctx.args[0], "@"))
+	if err != nil {
+		return err
+	}
+
+	delta, err := ctx.room.MuteParticipant(ctx.participant.ID, target.ID)
+	if err != nil {
+		return err
+	}
+	if err := target.Send(SignalingMessage{
+		Type:     "muted",
+		RoomID:   ctx.room.ID,
+		SenderID: ctx.participant.ID,
+	}); err != nil {
+		log.Printf("Error sending mute to participant %s: %v", target.ID, err)
+	}
+	if !delta.IsEmpty() {
+		h.broadcastToRoom(ctx.room, SignalingMessage{
+			Type:     "media_state",
+			RoomID:   ctx.room.ID,
+			SenderID: target.ID,
+			Data:     delta,
+		}, target.ID)
+	}
+	return nil
+}
+
+// runTopicCommand sets or clears the room topic and tells the room if it changed
+func (h *WebSocketHandler) runTopicCommand(ctx commandContext) error {
+	topic := ctx.text
+	if topic != "" {
+		var err error
+		if topic, err = h.chatModerator.Moderate(ctx.participant.ID, topic); err != nil {
+			return err
+		}
+	}
+	diff, err := ctx.room.SetTopic(ctx.participant.ID, topic)
+	if err != nil {
+		return err
+	}
+	h.broadcastRoomMetadata(ctx.room, ctx.participant.ID, diff)
+	return nil
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"zeem/internal/models"
+	"zeem/internal/services"
+)
+
+// ErrorPayload is the data of an "error" message sent back to a client
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// roomFullPayload is the error sent to a participant turned away from a full room
+type roomFullPayload struct {
+	ErrorPayload
+	Participants int `json:"participants"`
+	Capacity     int `json:"capacity,omitempty"`
+}
+
+// errorCode maps model, moderation and command errors to the codes clients can switch on
+func errorCode(err error) string {
+	var moderationErr *services.ModerationError
+	if errors.As(err, &moderationErr) {
+		return moderationErr.Code
+	}
+	var commandErr *commandError
+	if errors.As(err, &commandErr) {
+		return commandErr.Code
+	}
+
+	switch err {
+	case models.ErrInvalidConnectionType:
+		return "invalid_connection_type"
+	case models.ErrRoomFull:
+		return "room_full"
+	case models.ErrBroadcasterExists:
+		return "broadcaster_exists"
+	case models.ErrBroadcastEnded:
+		return "broadcast_ended"
+	case models.ErrParticipantNotFound:
+		return "participant_not_found"
+	case models.ErrAmbiguousParticipant:
+		return "ambiguous_participant"
+	case models.ErrPermissionDenied:
+		return "permission_denied"
+	case models.ErrInvalidRole:
+		return "invalid_role"
+	case models.ErrStageFull:
+		return "stage_full"
+	case models.ErrNotPresenter:
+		return "not_presenter"
+	case models.ErrMessageNotFound:
+		return "message_not_found"
+	case models.ErrPollNotFound:
+		return "poll_not_found"
+	case models.ErrPollClosed:
+		return "poll_closed"
+	case models.ErrAlreadyVoted:
+		return "already_voted"
+	case models.ErrInvalidPoll:
+		return "invalid_poll"
+	case models.ErrTooManyPollOptions:
+		return "too_many_poll_options"
+	case models.ErrPollTextTooLong:
+		return "poll_text_too_long"
+	case models.ErrQuestionNotFound:
+		return "question_not_found"
+	case models.ErrInvalidQuestionState:
+		return "invalid_question_state"
+	case models.ErrBreakoutsOpen:
+		return "breakouts_open"
+	case models.ErrNoBreakouts:
+		return "no_breakouts"
+	case models.ErrInvalidBreakout:
+		return "invalid_breakout"
+	case models.ErrBreakoutClosed:
+		return "breakout_closed"
+	case models.ErrInvalidMetadata:
+		return "invalid_metadata"
+	case models.ErrInvalidSpotlight:
+		return "invalid_spotlight"
+	case models.ErrInvalidPin:
+		return "invalid_pin"
+	default:
+		return "bad_request"
+	}
+}
+
+// sendError reports a failed request back to the participant that made it
+func (h *WebSocketHandler) sendError(p *models.Participant, err error) {
+	writeErr := p.Send(SignalingMessage{
+		Type: "error",
+		Data: ErrorPayload{
+			Code:    errorCode(err),
+			Message: err.Error(),
+		},
+	})
+	if writeErr != nil {
+		log.Printf("Error sending error to participant %s: %v", p.ID, writeErr)
+	}
+}
+
+// sendJoinError reports why a participant could not join a room. A full
+// room also reports how many participants are in it.
+func (h *WebSocketHandler) sendJoinError(room *models.Room, p *models.Participant, err error) {
+	payload := ErrorPayload{Code: errorCode(err), Message: err.Error()}
+	var data interface{} = payload
+	if err == models.ErrRoomFull {
+		data = roomFullPayload{
+			ErrorPayload: payload,
+			Participants: room.ParticipantCount(),
+			Capacity:     room.Capacity,
+		}
+	}
+	if writeErr := p.Send(SignalingMessage{Type: "error", RoomID: room.ID, Data: data}); writeErr != nil {
+		log.Printf("Error sending join error to participant %s: %v", p.ID, writeErr)
+	}
+}
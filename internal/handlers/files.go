@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"zeem/internal/models"
+	"zeem/internal/services"
+	"zeem/internal/store"
+)
+
+// FileHandler serves uploads and downloads of files shared in room chat
+type FileHandler struct {
+	ws          *WebSocketHandler
+	fileManager *services.FileManager
+}
+
+// NewFileHandler creates a file handler. Uploaded files are posted to the
+// room chat through the WebSocket handler.
+func NewFileHandler(ws *WebSocketHandler, fm *services.FileManager) *FileHandler {
+	return &FileHandler{
+		ws:          ws,
+		fileManager: fm,
+	}
+}
+
+// Upload stores a file sent as the "file" field of a multipart form and posts
+// it to the room chat, with the optional "message" field as its text. Only
+// participants currently in the room may upload.
+func (h *FileHandler) Upload(c *gin.Context) {
+	room := h.ws.roomManager.GetRoom(c.Param("roomId"))
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+	p := room.GetParticipantByToken(requestToken(c))
+	if p == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing token"})
+		return
+	}
+
+	// Leave room for the multipart framing and the message field
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.fileManager.MaxBytes()+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrFileTooLarge.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing file"})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.fileManager.Save(room.ID, header.Filename, file)
+	switch err {
+	case nil:
+	case services.ErrFileTooLarge:
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case services.ErrFileTypeNotAllowed:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
+		return
+	}
+
+	// Moderate the message only once the file is accepted, so a rejected
+	// upload doesn't use up the sender's rate limit
+	content := c.Request.FormValue("message")
+	if content != "" {
+		if content, err = h.ws.chatModerator.Moderate(p.ID, content); err != nil {
+			h.fileManager.Delete(room.ID, attachment.ID)
+			c.JSON(http.StatusBadRequest, ErrorPayload{Code: errorCode(err), Message: err.Error()})
+			return
+		}
+	}
+
+	chatMsg, err := room.AddChatMessage(models.ChatMessage{
+		SenderID:   p.ID,
+		SenderName: p.Username,
+		Content:    content,
+		Timestamp:  time.Now().Unix(),
+		Attachment: &attachment,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorPayload{Code: errorCode(err), Message: err.Error()})
+		return
+	}
+	h.ws.roomManager.SaveChatMessage(room.ID, chatMsg)
+	h.ws.broadcastToRoom(room, SignalingMessage{
+		Type:     "chat",
+		RoomID:   room.ID,
+		SenderID: p.ID,
+		Data:     chatMsg,
+	}, "")
+	h.ws.notifyMentions(room, chatMsg)
+
+	c.JSON(http.StatusCreated, chatMsg)
+}
+
+// Download returns the contents of a shared file. It requires the access token
+// of a current or former member of the room.
+func (h *FileHandler) Download(c *gin.Context) {
+	roomID := c.Param("roomId")
+	if _, ok := h.ws.roomManager.Authenticate(roomID, requestToken(c)); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing token"})
+		return
+	}
+
+	room := h.ws.roomManager.GetRoom(roomID)
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+	attachment, ok := room.GetAttachment(c.Param("fileId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	contents, err := h.fileManager.Open(roomID, attachment.ID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+		return
+	}
+	defer contents.Close()
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{
+		"filename": attachment.Name,
+	})
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.Type, contents, map[string]string{
+		"Content-Disposition": disposition,
+	})
+}
+
+// requestToken returns the participant token of a request, taken from a
+// bearer Authorization header or the token query parameter
+func requestToken(c *gin.Context) string {
+	if token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); token != "" {
+		return token
+	}
+	return c.Query("token")
+}
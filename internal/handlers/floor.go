@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"log"
+
+	"zeem/internal/models"
+)
+
+// handleFloorMessage handles screen-share floor requests in screen sharing rooms
+func (h *WebSocketHandler) handleFloorMessage(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	target := targetPayload{ParticipantID: p.ID}
+	if msg.Type == "grant_floor" {
+		if err := decodeData(msg.Data, &target); err != nil {
+			log.Printf("Invalid grant_floor from participant %s: %v", p.ID, err)
+			return
+		}
+		if target.ParticipantID == "" {
+			target.ParticipantID = p.ID
+		}
+	}
+
+	h.changeFloor(room, func() error {
+		switch msg.Type {
+		case "request_floor":
+			_, err := room.RequestFloor(p.ID)
+			return err
+		case "release_floor":
+			room.ReleaseFloor(p.ID)
+			return nil
+		default: // grant_floor
+			return room.GrantFloor(p.ID, target.ParticipantID)
+		}
+	}, p)
+}
+
+// changeFloor applies a floor change and, if the floor moved, tells the room
+// about the new floor state and the previous presenter's stopped screen share.
+// The SFU forwards only the new presenter's screen track.
+func (h *WebSocketHandler) changeFloor(room *models.Room, change func() error, requester *models.Participant) {
+	before := room.GetFloor()
+	previous, hadPresenter := room.GetParticipantView(before.Presenter)
+
+	if err := change(); err != nil {
+		if requester != nil {
+			h.sendError(requester, err)
+		}
+		return
+	}
+
+	after := room.GetFloor()
+	if after.Presenter == before.Presenter && len(after.Queue) == len(before.Queue) {
+		return
+	}
+	if h.sfuManager != nil && after.Presenter != before.Presenter {
+		h.sfuManager.SetFloor(room.ID, after.Presenter)
+	}
+
+	if hadPresenter && previous.Media.Screen && after.Presenter != previous.ID {
+		stopped := false
+		h.broadcastToRoom(room, SignalingMessage{
+			Type:     "media_state",
+			RoomID:   room.ID,
+			SenderID: previous.ID,
+			Data:     models.MediaStateUpdate{Screen: &stopped},
+		}, "")
+	}
+
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:   "floor_state",
+		RoomID: room.ID,
+		Data:   after,
+	}, "")
+}
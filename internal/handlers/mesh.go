@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"log"
+
+	"zeem/internal/models"
+)
+
+// relaySignal forwards an offer, answer or ICE candidate. Messages with a
+// targetId go to that participant only, as group rooms require; others go to
+// everyone else in the room.
+func (h *WebSocketHandler) relaySignal(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	if msg.TargetID == "" {
+		h.broadcastToRoom(room, msg, p.ID)
+		return
+	}
+	target := room.GetParticipant(msg.TargetID)
+	if target == nil {
+		h.sendError(p, models.ErrParticipantNotFound)
+		return
+	}
+	if err := target.Send(msg); err != nil {
+		log.Printf("Error relaying %s to participant %s: %v", msg.Type, target.ID, err)
+	}
+}
+
+// sendMeshPeers tells a newcomer to a group room whom to send offers to.
+// Everyone already in the room waits for the newcomer's offer, so every pair
+// of participants negotiates exactly once.
+func (h *WebSocketHandler) sendMeshPeers(room *models.Room, p *models.Participant) {
+	if err := p.Send(SignalingMessage{
+		Type:   "mesh_peers",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"peers": room.MeshPeers(p.ID),
+		},
+	}); err != nil {
+		log.Printf("Error sending mesh peers to participant %s: %v", p.ID, err)
+	}
+}
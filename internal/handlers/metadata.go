@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"zeem/internal/models"
+)
+
+// roomMetadataPayload is the data of set_room_metadata
+type roomMetadataPayload struct {
+	Metadata models.MetadataUpdate `json:"metadata"`
+}
+
+// participantAttributesPayload is the data of set_participant_attributes
+type participantAttributesPayload struct {
+	ParticipantID string                `json:"participantId"` // Defaults to the sender
+	Attributes    models.MetadataUpdate `json:"attributes"`
+}
+
+// handleSetRoomMetadata updates the room metadata and broadcasts what changed
+func (h *WebSocketHandler) handleSetRoomMetadata(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload roomMetadataPayload
+	if err := decodeData(msg.Data, &payload); err != nil {
+		log.Printf("Invalid room metadata from participant %s: %v", p.ID, err)
+		return
+	}
+	if err := h.moderateTopic(p.ID, payload.Metadata); err != nil {
+		h.sendError(p, err)
+		return
+	}
+	diff, err := room.SetRoomMetadata(p.ID, payload.Metadata)
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	h.broadcastRoomMetadata(room, p.ID, diff)
+}
+
+// handleSetParticipantAttributes updates a participant's attributes and broadcasts what changed
+func (h *WebSocketHandler) handleSetParticipantAttributes(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload participantAttributesPayload
+	if err := decodeData(msg.Data, &payload); err != nil {
+		log.Printf("Invalid participant attributes from participant %s: %v", p.ID, err)
+		return
+	}
+	targetID := payload.ParticipantID
+	if targetID == "" {
+		targetID = p.ID
+	}
+	diff, err := room.SetParticipantAttributes(p.ID, targetID, payload.Attributes)
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	h.broadcastParticipantAttributes(room, targetID, diff)
+}
+
+// moderateTopic runs a topic set through room metadata past the chat
+// moderator, as /topic does. Clearing the topic is left alone.
+func (h *WebSocketHandler) moderateTopic(actorID string, update models.MetadataUpdate) error {
+	topic := update[models.TopicKey]
+	if topic == nil || *topic == "" {
+		return nil
+	}
+	moderated, err := h.chatModerator.Moderate(actorID, *topic)
+	if err != nil {
+		return err
+	}
+	update[models.TopicKey] = &moderated
+	return nil
+}
+
+// broadcastRoomMetadata sends the changed room metadata keys to the room.
+// A changed topic is also announced as topic_changed.
+func (h *WebSocketHandler) broadcastRoomMetadata(room *models.Room, senderID string, diff models.MetadataUpdate) {
+	if len(diff) == 0 {
+		return
+	}
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:     "room_metadata_changed",
+		RoomID:   room.ID,
+		SenderID: senderID,
+		Data: map[string]interface{}{
+			"metadata": diff,
+		},
+	}, "")
+
+	if _, ok := diff[models.TopicKey]; ok {
+		h.broadcastToRoom(room, SignalingMessage{
+			Type:     "topic_changed",
+			RoomID:   room.ID,
+			SenderID: senderID,
+			Data: map[string]interface{}{
+				"topic": room.GetTopic(),
+			},
+		}, "")
+	}
+}
+
+// broadcastParticipantAttributes sends a participant's changed attribute keys to the room
+func (h *WebSocketHandler) broadcastParticipantAttributes(room *models.Room, participantID string, diff models.MetadataUpdate) {
+	if len(diff) == 0 {
+		return
+	}
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:     "participant_attributes_changed",
+		RoomID:   room.ID,
+		SenderID: participantID,
+		Data: map[string]interface{}{
+			"participantId": participantID,
+			"attributes":    diff,
+		},
+	}, "")
+}
+
+// MetadataHandler serves REST edits of room metadata and participant attributes
+type MetadataHandler struct {
+	ws *WebSocketHandler
+}
+
+// NewMetadataHandler creates a metadata handler. Changes are broadcast to the
+// room through the WebSocket handler.
+func NewMetadataHandler(ws *WebSocketHandler) *MetadataHandler {
+	return &MetadataHandler{ws: ws}
+}
+
+// SetRoomMetadata applies a JSON object of keys to values, or to null to
+// remove them, to a room's metadata. It requires the token of a host or
+// broadcaster currently in the room.
+func (h *MetadataHandler) SetRoomMetadata(c *gin.Context) {
+	room, p, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+	var update models.MetadataUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := h.ws.moderateTopic(p.ID, update); err != nil {
+		c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	diff, err := room.SetRoomMetadata(p.ID, update)
+	if err != nil {
+		c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.ws.broadcastRoomMetadata(room, p.ID, diff)
+	c.JSON(http.StatusOK, gin.H{"metadata": room.GetRoomMetadata()})
+}
+
+// SetParticipantAttributes applies a JSON object of keys to values, or to
+// null to remove them, to a participant's attributes. It requires the token
+// of that participant, or of a host or broadcaster, currently in the room.
+func (h *MetadataHandler) SetParticipantAttributes(c *gin.Context) {
+	room, p, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+	var update models.MetadataUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	targetID := c.Param("participantId")
+	diff, err := room.SetParticipantAttributes(p.ID, targetID, update)
+	if err != nil {
+		c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.ws.broadcastParticipantAttributes(room, targetID, diff)
+	view, _ := room.GetParticipantView(targetID)
+	c.JSON(http.StatusOK, gin.H{"attributes": view.Attributes})
+}
+
+// authorize resolves the room of a request and the participant its token belongs to
+func (h *MetadataHandler) authorize(c *gin.Context) (*models.Room, *models.Participant, bool) {
+	room := h.ws.roomManager.GetRoom(c.Param("roomId"))
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return nil, nil, false
+	}
+	p := room.GetParticipantByToken(requestToken(c))
+	if p == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing token"})
+		return nil, nil, false
+	}
+	return room, p, true
+}
+
+// metadataErrorStatus maps a metadata update error to its HTTP status
+func metadataErrorStatus(err error) int {
+	switch err {
+	case models.ErrPermissionDenied:
+		return http.StatusForbidden
+	case models.ErrParticipantNotFound:
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
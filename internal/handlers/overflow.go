@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"log"
+
+	"zeem/internal/models"
+)
+
+// joinRoom adds a participant to a room. When the room is full its overflow
+// policy decides whether the participant is turned away, admitted as a
+// viewer or queued; queued participants wait here until a slot frees up. It
+// reports whether the participant got in.
+func (h *WebSocketHandler) joinRoom(room *models.Room, p *models.Participant, messages <-chan SignalingMessage) bool {
+	err := room.AddParticipant(p)
+	if err == models.ErrRoomFull {
+		switch room.Settings.Overflow {
+		case models.OverflowViewer:
+			room.AddOverflowViewer(p)
+			return true
+		case models.OverflowQueue:
+			return h.waitForSlot(room, p, messages)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to add participant: %v", err)
+		h.sendJoinError(room, p, err)
+		return false
+	}
+	return true
+}
+
+// waitForSlot queues a participant until they are admitted or disconnect.
+// Messages sent while waiting are dropped.
+func (h *WebSocketHandler) waitForSlot(room *models.Room, p *models.Participant, messages <-chan SignalingMessage) bool {
+	admitted := make(chan struct{})
+	h.admissionsMutex.Lock()
+	h.admissions[p.ID] = admitted
+	h.admissionsMutex.Unlock()
+	defer func() {
+		h.admissionsMutex.Lock()
+		delete(h.admissions, p.ID)
+		h.admissionsMutex.Unlock()
+	}()
+
+	if room.JoinWaitQueue(p) {
+		return true
+	}
+	h.sendQueuePositions(room)
+
+	for {
+		select {
+		case <-admitted:
+			return true
+		case _, ok := <-messages:
+			if ok {
+				continue
+			}
+			if !room.LeaveWaitQueue(p.ID) {
+				// Admitted just as the connection closed; the usual leave cleans up
+				return true
+			}
+			h.sendQueuePositions(room)
+			return false
+		}
+	}
+}
+
+// admitWaiting lets queued participants into the room's free slots, in order
+func (h *WebSocketHandler) admitWaiting(room *models.Room) {
+	admitted := room.AdmitWaiting()
+	if len(admitted) == 0 {
+		return
+	}
+	h.admissionsMutex.Lock()
+	for _, p := range admitted {
+		if ch, ok := h.admissions[p.ID]; ok {
+			close(ch)
+		}
+	}
+	h.admissionsMutex.Unlock()
+	h.sendQueuePositions(room)
+}
+
+// sendQueuePositions tells everyone in the wait queue where they stand
+func (h *WebSocketHandler) sendQueuePositions(room *models.Room) {
+	waiting := room.GetWaitQueue()
+	for i, p := range waiting {
+		if err := p.Send(SignalingMessage{
+			Type:   "queue_position",
+			RoomID: room.ID,
+			Data: map[string]interface{}{
+				"position": i + 1,
+				"waiting":  len(waiting),
+			},
+		}); err != nil {
+			log.Printf("Error sending queue position to participant %s: %v", p.ID, err)
+		}
+	}
+}
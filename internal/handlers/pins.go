@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"log"
+
+	"zeem/internal/models"
+)
+
+// handlePin pins or unpins a public chat message and sends the new pins to the whole room
+func (h *WebSocketHandler) handlePin(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload chatUpdatePayload
+	if err := decodeData(msg.Data, &payload); err != nil || payload.MessageID == "" {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+
+	var err error
+	if msg.Type == "pin_message" {
+		err = room.PinChatMessage(p.ID, payload.MessageID)
+	} else {
+		err = room.UnpinChatMessage(p.ID, payload.MessageID)
+	}
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	h.broadcastPins(room, p.ID)
+}
+
+// broadcastPins sends the room's pinned messages to the whole room
+func (h *WebSocketHandler) broadcastPins(room *models.Room, senderID string) {
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:     "pinned_messages",
+		RoomID:   room.ID,
+		SenderID: senderID,
+		Data: map[string]interface{}{
+			"messages": room.GetPinnedMessages(),
+		},
+	}, "")
+}
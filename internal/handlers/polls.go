@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"zeem/internal/models"
+)
+
+// pollCreatePayload is the data of a poll_create message
+type pollCreatePayload struct {
+	models.PollRequest
+	DurationSeconds int `json:"durationSeconds"`
+}
+
+// pollVotePayload is the data of poll_vote and poll_close messages
+type pollVotePayload struct {
+	PollID  string `json:"pollId"`
+	Choices []int  `json:"choices,omitempty"`
+}
+
+// handlePollMessage creates, votes on or closes a poll
+func (h *WebSocketHandler) handlePollMessage(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var err error
+	switch msg.Type {
+	case "poll_create":
+		var payload pollCreatePayload
+		if err := decodeData(msg.Data, &payload); err != nil {
+			log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+			return
+		}
+		payload.Duration = time.Duration(payload.DurationSeconds) * time.Second
+		err = h.createPoll(room, p, payload.PollRequest)
+
+	case "poll_vote", "poll_close":
+		var payload pollVotePayload
+		if err := decodeData(msg.Data, &payload); err != nil || payload.PollID == "" {
+			log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+			return
+		}
+		if msg.Type == "poll_vote" {
+			err = h.votePoll(room, p, payload.PollID, payload.Choices)
+		} else {
+			err = h.closePoll(room, p, payload.PollID)
+		}
+	}
+	if err != nil {
+		h.sendError(p, err)
+	}
+}
+
+// createPoll moderates a poll's question and options like chat, opens the
+// poll, announces it to the room and schedules its timer
+func (h *WebSocketHandler) createPoll(room *models.Room, p *models.Participant, req models.PollRequest) error {
+	parts, err := h.chatModerator.ModerateParts(p.ID, append([]string{req.Question}, req.Options...))
+	if err != nil {
+		return err
+	}
+	req.Question, req.Options = parts[0], parts[1:]
+
+	poll, err := room.CreatePoll(p.ID, req)
+	if err != nil {
+		return err
+	}
+	h.sendPoll(room, "poll_created", poll.ID, room.GetParticipants())
+
+	if req.Duration > 0 {
+		time.AfterFunc(req.Duration, func() {
+			if room.ExpirePoll(poll.ID, time.Now()) {
+				h.sendPoll(room, "poll_closed", poll.ID, room.GetParticipants())
+			}
+		})
+	}
+	return nil
+}
+
+// votePoll records a vote and sends the live results to the moderators and
+// the voter's updated poll to the voter
+func (h *WebSocketHandler) votePoll(room *models.Room, p *models.Participant, pollID string, choices []int) error {
+	if err := room.VotePoll(p.ID, pollID, choices); err != nil {
+		return err
+	}
+
+	recipients := []*models.Participant{p}
+	for _, other := range room.GetParticipants() {
+		if view, ok := room.GetParticipantView(other.ID); ok && view.Role.CanModerate() && other.ID != p.ID {
+			recipients = append(recipients, other)
+		}
+	}
+	h.sendPoll(room, "poll_updated", pollID, recipients)
+	return nil
+}
+
+// closePoll closes a poll and sends the final results to everyone
+func (h *WebSocketHandler) closePoll(room *models.Room, p *models.Participant, pollID string) error {
+	if err := room.ClosePoll(p.ID, pollID); err != nil {
+		return err
+	}
+	h.sendPoll(room, "poll_closed", pollID, room.GetParticipants())
+	return nil
+}
+
+// sendPoll sends each recipient the poll as they may see it
+func (h *WebSocketHandler) sendPoll(room *models.Room, msgType, pollID string, recipients []*models.Participant) {
+	for _, recipient := range recipients {
+		view, ok := room.GetPollView(recipient.ID, pollID)
+		if !ok {
+			return
+		}
+		if err := recipient.Send(SignalingMessage{
+			Type:   msgType,
+			RoomID: room.ID,
+			Data:   view,
+		}); err != nil {
+			log.Printf("Error sending poll to participant %s: %v", recipient.ID, err)
+		}
+	}
+}
+
+// runPollCommand creates a single-choice named poll from
+// "/poll question | option | option"
+func (h *WebSocketHandler) runPollCommand(ctx commandContext) error {
+	parts := strings.Split(ctx.text, "|")
+	if len(parts) < 3 {
+		command, _ := h.commands.lookup("poll")
+		return command.usageError()
+	}
+	return h.createPoll(ctx.room, ctx.participant, models.PollRequest{
+		Question: parts[0],
+		Options:  parts[1:],
+	})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"log"
+
+	"zeem/internal/models"
+)
+
+// qaPayload is the data of qa_* messages
+type qaPayload struct {
+	QuestionID string `json:"questionId,omitempty"`
+	Text       string `json:"text,omitempty"`   // Question text for qa_ask
+	Answer     string `json:"answer,omitempty"` // Text answer for qa_answer; empty when answered live
+}
+
+// qaActions maps moderator Q&A messages to their action
+var qaActions = map[string]models.QuestionAction{
+	"qa_approve": models.QuestionApprove,
+	"qa_answer":  models.QuestionAnswer,
+	"qa_dismiss": models.QuestionDismiss,
+}
+
+// handleQAMessage submits, upvotes or moderates a Q&A question and sends the
+// changed question to the room
+func (h *WebSocketHandler) handleQAMessage(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload qaPayload
+	if err := decodeData(msg.Data, &payload); err != nil {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+
+	var (
+		question models.QuestionView
+		err      error
+	)
+	switch msg.Type {
+	case "qa_ask":
+		var text string
+		if text, err = h.chatModerator.Moderate(p.ID, payload.Text); err == nil {
+			question, err = room.AskQuestion(p.ID, text)
+		}
+	case "qa_upvote":
+		question, err = room.UpvoteQuestion(p.ID, payload.QuestionID)
+	default:
+		answer := payload.Answer
+		if answer != "" {
+			answer, err = h.chatModerator.Moderate(p.ID, answer)
+		}
+		if err == nil {
+			question, err = room.ModerateQuestion(p.ID, payload.QuestionID, qaActions[msg.Type], answer)
+		}
+	}
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	h.broadcastQuestion(room, question.ID, question.Status == models.QuestionDismissed)
+}
+
+// broadcastQuestion sends a changed question to each participant who may see
+// it. Participants who may not see a dismissed question are told to drop it.
+func (h *WebSocketHandler) broadcastQuestion(room *models.Room, questionID string, dismissed bool) {
+	for _, p := range room.GetParticipants() {
+		msg := SignalingMessage{Type: "qa_updated", RoomID: room.ID}
+		if view, ok := room.GetQuestion(p.ID, questionID); ok {
+			msg.Data = map[string]interface{}{"question": view}
+		} else if dismissed {
+			msg.Type = "qa_removed"
+			msg.Data = map[string]string{"questionId": questionID}
+		} else {
+			continue
+		}
+		if err := p.Send(msg); err != nil {
+			log.Printf("Error sending question to participant %s: %v", p.ID, err)
+		}
+	}
+}
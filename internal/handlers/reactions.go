@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log"
+
+	"zeem/internal/models"
+)
+
+// reactionPayload is the data of a reaction message
+type reactionPayload struct {
+	Emoji string `json:"emoji"`
+}
+
+// handleReaction counts a live reaction towards the room's next burst
+func (h *WebSocketHandler) handleReaction(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload reactionPayload
+	if err := decodeData(msg.Data, &payload); err != nil || payload.Emoji == "" {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+	if err := h.reactions.Add(room.ID, p.ID, payload.Emoji); err != nil {
+		h.sendError(p, err)
+	}
+}
+
+// broadcastReactions sends a burst of aggregated reactions to the whole room
+func (h *WebSocketHandler) broadcastReactions(roomID string, counts map[string]int) {
+	room := h.roomManager.GetRoom(roomID)
+	if room == nil {
+		return
+	}
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:   "reactions",
+		RoomID: roomID,
+		Data: map[string]interface{}{
+			"counts": counts,
+		},
+	}, "")
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"zeem/internal/models"
+)
+
+// typingPayload is the data of a typing message. A missing typing field means true.
+type typingPayload struct {
+	Typing *bool `json:"typing"`
+}
+
+// chatReadPayload is the data of a chat_read message
+type chatReadPayload struct {
+	MessageID string `json:"messageId"`
+}
+
+// handleTyping marks the participant as typing or not. The room is only told
+// when the set of typing participants changes, and marks lapse after the
+// room's typing timeout unless the client refreshes them.
+func (h *WebSocketHandler) handleTyping(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload typingPayload
+	if msg.Data != nil {
+		if err := decodeData(msg.Data, &payload); err != nil {
+			log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+			return
+		}
+	}
+	typing := payload.Typing == nil || *payload.Typing
+
+	changed, err := room.SetTyping(p.ID, typing)
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	if typing {
+		h.scheduleTypingExpiry(room)
+	}
+	if changed {
+		h.broadcastTyping(room)
+	}
+}
+
+// scheduleTypingExpiry starts the room's typing expiry timer unless one is
+// already running. The timer re-arms itself for the next mark to lapse, so a
+// room has at most one timer however often its participants send typing.
+func (h *WebSocketHandler) scheduleTypingExpiry(room *models.Room) {
+	h.typingMutex.Lock()
+	defer h.typingMutex.Unlock()
+	if _, ok := h.typingTimers[room]; !ok {
+		h.typingTimers[room] = time.AfterFunc(room.Settings.TypingTimeout, func() { h.expireTyping(room) })
+	}
+}
+
+// expireTyping clears the room's lapsed typing marks, tells the room when
+// that changed who is typing and re-arms the timer while anyone still is
+func (h *WebSocketHandler) expireTyping(room *models.Room) {
+	h.typingMutex.Lock()
+	changed, next := room.ExpireTyping(time.Now())
+	if next.IsZero() {
+		delete(h.typingTimers, room)
+	} else {
+		h.typingTimers[room] = time.AfterFunc(time.Until(next), func() { h.expireTyping(room) })
+	}
+	h.typingMutex.Unlock()
+
+	if changed {
+		h.broadcastTyping(room)
+	}
+}
+
+// stopTyping clears the participant's typing mark, e.g. once their message was sent
+func (h *WebSocketHandler) stopTyping(room *models.Room, participantID string) {
+	if changed, _ := room.SetTyping(participantID, false); changed {
+		h.broadcastTyping(room)
+	}
+}
+
+// broadcastTyping sends the participants currently typing to the whole room
+func (h *WebSocketHandler) broadcastTyping(room *models.Room) {
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:   "typing",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"participants": room.GetTyping(),
+		},
+	}, "")
+}
+
+// handleChatRead moves the participant's read watermark and tells the room when it advanced
+func (h *WebSocketHandler) handleChatRead(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload chatReadPayload
+	if err := decodeData(msg.Data, &payload); err != nil || payload.MessageID == "" {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+
+	receipt, moved, err := room.MarkChatRead(p.ID, payload.MessageID)
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	if !moved {
+		return
+	}
+
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:     "chat_read",
+		RoomID:   room.ID,
+		SenderID: p.ID,
+		Data:     receipt,
+	}, p.ID)
+}
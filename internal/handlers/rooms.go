@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"zeem/internal/models"
+	"zeem/internal/services"
+)
+
+// RoomHandler serves the REST API for rooms
+type RoomHandler struct {
+	roomManager *services.RoomManager
+}
+
+// NewRoomHandler creates a new room REST handler
+func NewRoomHandler(rm *services.RoomManager) *RoomHandler {
+	return &RoomHandler{
+		roomManager: rm,
+	}
+}
+
+// createRoomRequest is the body of a room creation request
+type createRoomRequest struct {
+	RoomID      string                `json:"roomId"`
+	Type        models.ConnectionType `json:"type"`
+	ScheduledAt int64                 `json:"scheduledAt"`
+	Capacity    int                   `json:"capacity"` // Group rooms only; zero uses the default
+}
+
+// CreateRoom creates a room ahead of time, optionally scheduled for a later start
+func (h *RoomHandler) CreateRoom(c *gin.Context) {
+	var req createRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	switch req.Type {
+	case models.OneToOne, models.Broadcasting, models.ScreenSharing, models.Group:
+	case "":
+		req.Type = models.OneToOne
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrInvalidConnectionType.Error()})
+		return
+	}
+	if req.Capacity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid capacity"})
+		return
+	}
+	if req.RoomID == "" {
+		req.RoomID = uuid.New().String()
+	}
+	if h.roomManager.RoomExists(req.RoomID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "room already exists"})
+		return
+	}
+
+	h.roomManager.ScheduleRoom(req.RoomID, req.Type, req.ScheduledAt, req.Capacity)
+	record, err := h.roomManager.GetRoomRecord(req.RoomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save room"})
+		return
+	}
+	c.JSON(http.StatusCreated, record)
+}
+
+// GetRoom returns the public state of a room
+func (h *RoomHandler) GetRoom(c *gin.Context) {
+	room := h.roomManager.GetRoom(c.Param("roomId"))
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+
+	record, _ := h.roomManager.GetRoomRecord(room.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"roomId":       room.ID,
+		"roomType":     room.Type,
+		"scheduledAt":  record.ScheduledAt,
+		"capacity":     room.Capacity,
+		"metadata":     room.GetRoomMetadata(),
+		"participants": room.GetParticipantViews(),
+		"floor":        room.GetFloor(),
+	})
+}
+
+// GetChatHistory returns a page of a room's public chat history. It requires
+// the access token of a current or past participant. The before query
+// parameter is the cursor returned by the previous page.
+func (h *RoomHandler) GetChatHistory(c *gin.Context) {
+	roomID := c.Param("roomId")
+	if _, ok := h.authenticate(c, roomID); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing token"})
+		return
+	}
+	room := h.roomManager.GetRoom(roomID)
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+
+	before, err := strconv.ParseInt(c.DefaultQuery("before", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before cursor"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, getChatPage(room, before, limit))
+}
+
+// transcriptFile describes the response of a transcript export format
+type transcriptFile struct {
+	contentType string
+	extension   string
+}
+
+// transcriptFiles maps export formats to their response content type and file extension
+var transcriptFiles = map[services.TranscriptFormat]transcriptFile{
+	services.TranscriptJSON: {"application/json; charset=utf-8", "json"},
+	services.TranscriptText: {"text/plain; charset=utf-8", "txt"},
+	services.TranscriptHTML: {"text/html; charset=utf-8", "html"},
+}
+
+// ExportChat renders a room's full public chat transcript as JSON, plain text
+// or HTML. It requires the access token of a host or broadcaster.
+func (h *RoomHandler) ExportChat(c *gin.Context) {
+	roomID := c.Param("roomId")
+	role, ok := h.authenticate(c, roomID)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing token"})
+		return
+	}
+	if !role.CanModerate() {
+		c.JSON(http.StatusForbidden, gin.H{"error": models.ErrPermissionDenied.Error()})
+		return
+	}
+
+	format := services.TranscriptFormat(c.DefaultQuery("format", string(services.TranscriptJSON)))
+	file, ok := transcriptFiles[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrUnknownTranscriptFormat.Error()})
+		return
+	}
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+		return
+	}
+
+	messages, err := h.roomManager.GetChatTranscript(roomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load chat"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := services.RenderTranscript(&buf, roomID, messages, format, loc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render transcript"})
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{
+		"filename": "chat-" + roomID + "." + file.extension,
+	})
+	c.Header("Content-Disposition", disposition)
+	c.Data(http.StatusOK, file.contentType, buf.Bytes())
+}
+
+// authenticate resolves the participant token of a request to its role
+func (h *RoomHandler) authenticate(c *gin.Context, roomID string) (models.Role, bool) {
+	return h.roomManager.Authenticate(roomID, requestToken(c))
+}
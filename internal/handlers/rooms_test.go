@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"zeem/internal/models"
+	"zeem/internal/services"
+	"zeem/internal/store"
+)
+
+func setupRoomTestServer() (*gin.Engine, *services.RoomManager) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	roomManager := services.NewRoomManager()
+	roomHandler := NewRoomHandler(roomManager)
+
+	router.GET("/api/rooms/:roomId", roomHandler.GetRoom)
+	return router, roomManager
+}
+
+func TestRoomHandler_GetRoom(t *testing.T) {
+	router, roomManager := setupRoomTestServer()
+
+	room := roomManager.CreateRoom("test-room", models.OneToOne)
+	room.AddParticipant(&models.Participant{
+		ID:             "1",
+		Username:       "user1",
+		ConnectionInfo: &models.ConnectionInfo{Type: models.OneToOne},
+		Media:          models.MediaState{Video: true},
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/test-room", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var body struct {
+		Participants []models.ParticipantView `json:"participants"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(body.Participants) != 1 || !body.Participants[0].Media.Video || body.Participants[0].Media.Audio {
+		t.Errorf("unexpected participants: %+v", body.Participants)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestRoomHandler_GetChatHistory(t *testing.T) {
+	router, roomManager := setupRoomTestServer()
+	router.GET("/api/rooms/:roomId/chat", NewRoomHandler(roomManager).GetChatHistory)
+
+	room := roomManager.CreateRoom("test-room", models.OneToOne)
+	for _, content := range []string{"1", "2", "3"} {
+		room.AddChatMessage(models.ChatMessage{SenderID: "a", Content: content})
+	}
+	roomManager.SaveSession(store.SessionRecord{RoomID: room.ID, ParticipantID: "a", Token: "secret", Role: models.RoleParticipant})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/test-room/chat?limit=2", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", w.Code)
+	}
+
+	var page chatPage
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/test-room/chat?limit=2&token=secret", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(page.Messages) != 2 || page.Messages[0].Content != "2" || page.NextCursor == 0 {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+
+	w = httptest.NewRecorder()
+	url := "/api/rooms/test-room/chat?limit=2&token=secret&before=" + strconv.FormatInt(page.NextCursor, 10)
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	page = chatPage{}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(page.Messages) != 1 || page.Messages[0].Content != "1" || page.NextCursor != 0 {
+		t.Errorf("unexpected second page: %+v", page)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/test-room/chat?before=x&token=secret", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestRoomHandler_ExportChat(t *testing.T) {
+	router, roomManager := setupRoomTestServer()
+	router.GET("/api/rooms/:roomId/chat/export", NewRoomHandler(roomManager).ExportChat)
+
+	room := roomManager.CreateRoom("test-room", models.OneToOne)
+	msg, _ := room.AddChatMessage(models.ChatMessage{SenderName: "Alice", Content: "hello"})
+	roomManager.SaveChatMessage(room.ID, msg)
+	roomManager.SaveSession(store.SessionRecord{RoomID: room.ID, ParticipantID: "h", Token: "host-token", Role: models.RoleHost})
+	roomManager.SaveSession(store.SessionRecord{RoomID: room.ID, ParticipantID: "p", Token: "guest-token", Role: models.RoleParticipant})
+
+	tests := []struct {
+		name   string
+		query  string
+		header string
+		status int
+	}{
+		{"NoToken", "", "", http.StatusUnauthorized},
+		{"NotHost", "?token=guest-token", "", http.StatusForbidden},
+		{"BadFormat", "?format=pdf", "Bearer host-token", http.StatusBadRequest},
+		{"BadTimezone", "?tz=Mars/Olympus", "Bearer host-token", http.StatusBadRequest},
+		{"Text", "?format=text&tz=Asia/Jakarta", "Bearer host-token", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/rooms/test-room/chat/export"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.status == http.StatusOK {
+				if !strings.Contains(w.Body.String(), "Alice: hello") {
+					t.Errorf("Expected transcript line, got %q", w.Body.String())
+				}
+				if !strings.Contains(w.Header().Get("Content-Disposition"), "chat-test-room.txt") {
+					t.Errorf("Unexpected Content-Disposition %q", w.Header().Get("Content-Disposition"))
+				}
+			}
+		})
+	}
+}
+
+func TestFileHandler_UploadDownload(t *testing.T) {
+	router, roomManager := setupRoomTestServer()
+	files, err := store.NewDiskFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskFileStore failed: %v", err)
+	}
+	fileManager := services.NewFileManager(files, 1<<10, []string{"text/plain"}, time.Hour)
+	wsHandler := NewWebSocketHandler(roomManager, services.NewWebRTCManager())
+	wsHandler.SetChatModerator(services.NewChatModerator(services.RateLimitFilter(1, time.Hour)))
+	fileHandler := NewFileHandler(wsHandler, fileManager)
+	router.POST("/api/rooms/:roomId/files", fileHandler.Upload)
+	router.GET("/api/rooms/:roomId/files/:fileId", fileHandler.Download)
+
+	room := roomManager.CreateRoom("test-room", models.OneToOne)
+	room.AddParticipant(&models.Participant{
+		ID:             "1",
+		Username:       "user1",
+		Token:          "secret",
+		ConnectionInfo: &models.ConnectionInfo{Type: models.OneToOne},
+	})
+
+	upload := func(token, name, contents string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", name)
+		part.Write([]byte(contents))
+		form.WriteField("message", "see attached")
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/rooms/test-room/files", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := upload("wrong", "notes.txt", "hello"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a valid token, got %d", w.Code)
+	}
+	if w := upload("secret", "run.exe", "MZ"); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415 for a disallowed type, got %d", w.Code)
+	}
+	if w := upload("secret", "big.txt", strings.Repeat("x", 2<<10)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for a large file, got %d", w.Code)
+	}
+
+	w := upload("secret", "notes.txt", "hello")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var chatMsg models.ChatMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &chatMsg); err != nil || chatMsg.Attachment == nil {
+		t.Fatalf("Expected a chat message with an attachment, got %s (%v)", w.Body.String(), err)
+	}
+	if chatMsg.Content != "see attached" || len(room.GetChatHistory()) != 1 {
+		t.Errorf("Expected the upload to be posted to chat, got %+v", chatMsg)
+	}
+	if w := upload("secret", "other.txt", "again"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 once the rate limit is used up, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, chatMsg.Attachment.URL, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, chatMsg.Attachment.URL+"?token=secret", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Fatalf("Expected file contents, got %d %q", w.Code, w.Body.String())
+	}
+	if disposition := w.Header().Get("Content-Disposition"); disposition != "attachment; filename=notes.txt" {
+		t.Errorf("Unexpected Content-Disposition %q", disposition)
+	}
+}
+
+func TestMetadataHandler(t *testing.T) {
+	router, roomManager := setupRoomTestServer()
+	metadataHandler := NewMetadataHandler(NewWebSocketHandler(roomManager, services.NewWebRTCManager()))
+	router.PATCH("/api/rooms/:roomId/metadata", metadataHandler.SetRoomMetadata)
+	router.PATCH("/api/rooms/:roomId/participants/:participantId/attributes", metadataHandler.SetParticipantAttributes)
+
+	room := roomManager.CreateRoom("test-room", models.OneToOne)
+	room.AddParticipant(&models.Participant{ID: "1", Token: "host", ConnectionInfo: &models.ConnectionInfo{Type: models.OneToOne}})
+	room.AddParticipant(&models.Participant{ID: "2", Token: "guest", ConnectionInfo: &models.ConnectionInfo{Type: models.OneToOne}})
+
+	patch := func(path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := patch("/api/rooms/test-room/metadata", "guest", `{"agenda": "intro"}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a guest, got %d", w.Code)
+	}
+	if w := patch("/api/rooms/test-room/metadata", "host", `{"agenda": "intro"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if metadata := room.GetRoomMetadata(); metadata["agenda"] != "intro" {
+		t.Errorf("Expected the agenda to be set, got %v", metadata)
+	}
+	if w := patch("/api/rooms/test-room/metadata", "host", `{"topic": "<b>Roadmap</b>"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if topic := room.GetTopic(); topic != "Roadmap" {
+		t.Errorf("Expected the topic to be moderated, got %q", topic)
+	}
+	if w := patch("/api/rooms/test-room/metadata", "host", `{"topic": "<b></b>"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a topic the moderator rejects, got %d", w.Code)
+	}
+
+	if w := patch("/api/rooms/test-room/participants/1/attributes", "guest", `{"team": "red"}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another participant, got %d", w.Code)
+	}
+	if w := patch("/api/rooms/test-room/participants/2/attributes", "guest", `{"language": "fr"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := patch("/api/rooms/test-room/participants/2/attributes", "guest", `{"language": null}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if view, _ := room.GetParticipantView("2"); len(view.Attributes) != 0 {
+		t.Errorf("Expected the attribute to be removed, got %v", view.Attributes)
+	}
+}
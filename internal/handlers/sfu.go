@@ -1 +1,90 @@
 package handlers
+
+import (
+	"log"
+
+	"github.com/pion/webrtc/v3"
+
+	"zeem/internal/models"
+	"zeem/internal/services"
+)
+
+// handleSFUSignal negotiates a participant's media with the SFU. The first
+// sfu_offer joins the participant to the SFU; from then on the SFU sends
+// sfu_offer itself whenever the tracks the participant receives change.
+func (h *WebSocketHandler) handleSFUSignal(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	if h.sfuManager == nil {
+		log.Printf("Ignoring %s from participant %s: no SFU is configured", msg.Type, p.ID)
+		return
+	}
+
+	var err error
+	switch msg.Type {
+	case "sfu_offer":
+		var offer webrtc.SessionDescription
+		if err := decodeData(msg.Data, &offer); err != nil || offer.Type != webrtc.SDPTypeOffer {
+			log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+			return
+		}
+		if err = h.sfuManager.AddParticipant(room.ID, p); err == nil {
+			if room.Type == models.ScreenSharing {
+				h.sfuManager.SetFloor(room.ID, room.GetFloor().Presenter)
+			}
+			err = h.sfuManager.HandleOffer(p.ID, offer)
+		}
+
+	case "sfu_answer":
+		var answer webrtc.SessionDescription
+		if err := decodeData(msg.Data, &answer); err != nil || answer.Type != webrtc.SDPTypeAnswer {
+			log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+			return
+		}
+		err = h.sfuManager.HandleAnswer(p.ID, answer)
+
+	case "sfu_ice_candidate":
+		var candidate webrtc.ICECandidateInit
+		if err := decodeData(msg.Data, &candidate); err != nil {
+			log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+			return
+		}
+		err = h.sfuManager.AddICECandidate(p.ID, candidate)
+	}
+	if err != nil {
+		log.Printf("SFU negotiation with participant %s failed: %v", p.ID, err)
+		h.sendError(p, err)
+	}
+}
+
+// sendSFUSignal sends a participant the SFU's answer, offer or one of its ICE candidates
+func (h *WebSocketHandler) sendSFUSignal(roomID string, p *models.Participant, signal services.SFUSignal) {
+	msg := SignalingMessage{Type: "sfu_ice_candidate", RoomID: roomID, Data: signal.Candidate}
+	switch {
+	case signal.Answer != nil:
+		msg.Type, msg.Data = "sfu_answer", signal.Answer
+	case signal.Offer != nil:
+		msg.Type, msg.Data = "sfu_offer", signal.Offer
+	}
+	if err := p.Send(msg); err != nil {
+		log.Printf("Error sending %s to participant %s: %v", msg.Type, p.ID, err)
+	}
+}
+
+// screenSharePayload is the optional data of a screen_share_start message
+type screenSharePayload struct {
+	TrackID string `json:"trackId"` // ID of the screen track sent to the SFU, or of its stream
+}
+
+// setScreenTrack tells the SFU which of the participant's tracks carries the
+// screen share that msg starts, or that it stopped
+func (h *WebSocketHandler) setScreenTrack(p *models.Participant, msg SignalingMessage) {
+	if h.sfuManager == nil {
+		return
+	}
+	var payload screenSharePayload
+	if msg.Type == "screen_share_start" {
+		if err := decodeData(msg.Data, &payload); err != nil {
+			log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		}
+	}
+	h.sfuManager.SetScreenTrack(p.ID, payload.TrackID)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"zeem/internal/services"
+)
+
+// activeSpeakersPayload is the data of active_speakers messages
+type activeSpeakersPayload struct {
+	Speakers []services.ActiveSpeaker `json:"speakers"`
+}
+
+// broadcastActiveSpeakers tells a room who is speaking, dominant speaker first
+func (h *WebSocketHandler) broadcastActiveSpeakers(roomID string, speakers []services.ActiveSpeaker) {
+	room := h.roomManager.GetRoom(roomID)
+	if room == nil {
+		return
+	}
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:   "active_speakers",
+		RoomID: room.ID,
+		Data:   activeSpeakersPayload{Speakers: speakers},
+	}, "")
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"log"
+
+	"zeem/internal/models"
+)
+
+// spotlightPayload is the data of set_spotlight and spotlight messages
+type spotlightPayload struct {
+	Spotlight []models.Spotlight `json:"spotlight"`
+}
+
+// pinnedPayload is the data of set_pinned and pinned messages
+type pinnedPayload struct {
+	Pinned []models.Spotlight `json:"pinned"`
+}
+
+// handleSetSpotlight replaces the room's spotlight and tells the room
+func (h *WebSocketHandler) handleSetSpotlight(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload spotlightPayload
+	if err := decodeData(msg.Data, &payload); err != nil {
+		log.Printf("Invalid spotlight from participant %s: %v", p.ID, err)
+		return
+	}
+	if err := room.SetSpotlight(p.ID, payload.Spotlight); err != nil {
+		h.sendError(p, err)
+		return
+	}
+	h.broadcastSpotlight(room, p.ID)
+}
+
+// broadcastSpotlight sends the room's spotlight to everyone and, with an SFU,
+// has it forward the spotlighted video at the highest quality
+func (h *WebSocketHandler) broadcastSpotlight(room *models.Room, senderID string) {
+	spotlight := room.GetSpotlight()
+	if h.sfuManager != nil {
+		h.sfuManager.SetSpotlight(room.ID, spotlight)
+	}
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:     "spotlight",
+		RoomID:   room.ID,
+		SenderID: senderID,
+		Data:     spotlightPayload{Spotlight: spotlight},
+	}, "")
+}
+
+// handleSetPinned replaces the participants or tracks whose video the sender
+// always receives, even when they are not among the last N speakers
+func (h *WebSocketHandler) handleSetPinned(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var payload pinnedPayload
+	if err := decodeData(msg.Data, &payload); err != nil {
+		log.Printf("Invalid pins from participant %s: %v", p.ID, err)
+		return
+	}
+	pins, err := room.SetPinned(p.ID, payload.Pinned)
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+	if h.sfuManager != nil {
+		h.sfuManager.SetPinned(p.ID, pins)
+	}
+	if err := p.Send(SignalingMessage{
+		Type:   "pinned",
+		RoomID: room.ID,
+		Data:   pinnedPayload{Pinned: pins},
+	}); err != nil {
+		log.Printf("Error sending pins to participant %s: %v", p.ID, err)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"log"
+
+	"zeem/internal/models"
+)
+
+// targetPayload is the data of messages that act on another participant
+type targetPayload struct {
+	ParticipantID string `json:"participantId"`
+}
+
+// handleHand raises or lowers the sender's hand and broadcasts the new queue
+func (h *WebSocketHandler) handleHand(room *models.Room, p *models.Participant, raise bool) {
+	if raise {
+		if _, err := room.RaiseHand(p.ID); err != nil {
+			h.sendError(p, err)
+			return
+		}
+	} else if !room.LowerHand(p.ID) {
+		return
+	}
+	h.broadcastHandQueue(room)
+}
+
+// handleStageChange promotes a viewer to the stage or demotes a stage participant
+func (h *WebSocketHandler) handleStageChange(room *models.Room, p *models.Participant, msg SignalingMessage) {
+	var target targetPayload
+	if err := decodeData(msg.Data, &target); err != nil || target.ParticipantID == "" {
+		log.Printf("Invalid %s from participant %s: %v", msg.Type, p.ID, err)
+		return
+	}
+
+	var err error
+	if msg.Type == "promote_to_stage" {
+		err = room.PromoteToStage(p.ID, target.ParticipantID)
+	} else {
+		err = room.DemoteFromStage(p.ID, target.ParticipantID)
+	}
+	if err != nil {
+		h.sendError(p, err)
+		return
+	}
+
+	h.broadcastRoleChanged(room, target.ParticipantID)
+	if msg.Type == "promote_to_stage" {
+		h.broadcastHandQueue(room)
+	}
+}
+
+// broadcastRoleChanged tells everyone about a participant's new role
+func (h *WebSocketHandler) broadcastRoleChanged(room *models.Room, participantID string) {
+	view, ok := room.GetParticipantView(participantID)
+	if !ok {
+		return
+	}
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:     "role_changed",
+		RoomID:   room.ID,
+		SenderID: participantID,
+		Data:     view,
+	}, "")
+}
+
+// broadcastHandQueue sends the current hand queue to everyone in the room
+func (h *WebSocketHandler) broadcastHandQueue(room *models.Room) {
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:   "hand_queue",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"queue": room.GetHandQueue(),
+		},
+	}, "")
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +13,7 @@ import (
 
 	"zeem/internal/models"
 	"zeem/internal/services"
+	"zeem/internal/store"
 )
 
 var upgrader = websocket.Upgrader{
@@ -24,6 +27,7 @@ type SignalingMessage struct {
 	Type     string      `json:"type"`
 	RoomID   string      `json:"roomId"`
 	SenderID string      `json:"senderId"`
+	TargetID string      `json:"targetId,omitempty"` // Recipient of a relayed offer, answer or ICE candidate
 	Data     interface{} `json:"data"`
 }
 
@@ -31,14 +35,65 @@ type SignalingMessage struct {
 type WebSocketHandler struct {
 	roomManager   *services.RoomManager
 	webrtcManager *services.WebRTCManager
+	chatModerator *services.ChatModerator
+	fileManager   *services.FileManager
+	sfuManager    *services.SFUManager
+	commands      *commandRegistry
+	reactions     *services.ReactionAggregator
+
+	admissionsMutex sync.Mutex
+	admissions      map[string]chan struct{} // Closed when a queued participant is admitted
+
+	typingMutex  sync.Mutex
+	typingTimers map[*models.Room]*time.Timer // One typing expiry timer per room with someone typing
 }
 
 // NewWebSocketHandler creates a new WebSocket handler
 func NewWebSocketHandler(rm *services.RoomManager, wm *services.WebRTCManager) *WebSocketHandler {
-	return &WebSocketHandler{
+	h := &WebSocketHandler{
 		roomManager:   rm,
 		webrtcManager: wm,
+		chatModerator: services.DefaultChatModerator(),
+		commands:      defaultCommands(),
+		admissions:    make(map[string]chan struct{}),
+		typingTimers:  make(map[*models.Room]*time.Timer),
+	}
+	h.SetReactionConfig(services.DefaultReactionConfig())
+	return h
+}
+
+// SetChatModerator replaces the moderation chain applied to chat content
+func (h *WebSocketHandler) SetChatModerator(m *services.ChatModerator) {
+	h.chatModerator = m
+}
+
+// SetReactionConfig replaces the allowed reactions, their rate limit and the burst interval
+func (h *WebSocketHandler) SetReactionConfig(cfg services.ReactionConfig) {
+	h.reactions = services.NewReactionAggregator(cfg, h.broadcastReactions)
+}
+
+// SetFileManager enables file sharing; a room's files are cleaned up after its last participant leaves
+func (h *WebSocketHandler) SetFileManager(fm *services.FileManager) {
+	h.fileManager = fm
+}
+
+// SetSFUManager attaches the SFU that forwards media for participants that
+// negotiate with it over sfu_* messages. It follows the rooms' spotlight and
+// reports their active speakers every speakerInterval.
+func (h *WebSocketHandler) SetSFUManager(sfu *services.SFUManager, speakerInterval time.Duration) {
+	h.sfuManager = sfu
+	sfu.OnSignal(h.sendSFUSignal)
+	sfu.OnActiveSpeakers(speakerInterval, h.broadcastActiveSpeakers)
+}
+
+// rejoinToken returns the token of a joining participant: the one of an
+// earlier session in the room if it is given and nobody in the room holds it,
+// so that a reconnecting broadcaster may resume, or else a new one
+func (h *WebSocketHandler) rejoinToken(room *models.Room, token string) string {
+	if _, ok := h.roomManager.Authenticate(room.ID, token); ok && room.GetParticipantByToken(token) == nil {
+		return token
 	}
+	return uuid.New().String()
 }
 
 // HandleConnection handles incoming WebSocket connections
@@ -56,6 +111,8 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 	username := c.Query("username")
 	isBroadcaster := c.Query("broadcaster") == "true"
 	isScreenShare := c.Query("screenShare") == "true"
+	audioEnabled := c.Query("audio") != "false"
+	videoEnabled := c.Query("video") != "false"
 
 	if roomID == "" {
 		log.Println("Room ID not provided")
@@ -64,128 +121,266 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 
 	// Validate connection type
 	switch roomType {
-	case models.OneToOne, models.Broadcasting, models.ScreenSharing:
+	case models.OneToOne, models.Broadcasting, models.ScreenSharing, models.Group:
 		// Valid types
 	default:
 		roomType = models.OneToOne // Default to one-to-one
 	}
 
-	// Get or create room
-	var room *models.Room
-	if !h.roomManager.RoomExists(roomID) {
-		room = h.roomManager.CreateRoom(roomID, roomType)
-	} else {
-		room = h.roomManager.GetRoom(roomID)
+	if h.roomManager.IsClosed(roomID) {
+		conn.WriteJSON(SignalingMessage{
+			Type:   "error",
+			RoomID: roomID,
+			Data:   ErrorPayload{Code: errorCode(models.ErrBreakoutClosed), Message: models.ErrBreakoutClosed.Error()},
+		})
+		return
 	}
 
+	// Get or create room
+	room := h.roomManager.GetOrCreateRoom(roomID, roomType)
+
 	// Create participant
 	participant := &models.Participant{
 		ID:       participantID,
 		Conn:     conn,
 		Username: username,
+		Token:    h.rejoinToken(room, c.Query("token")),
 		ConnectionInfo: &models.ConnectionInfo{
 			Type:          roomType,
 			IsBroadcaster: isBroadcaster,
 			IsScreenShare: isScreenShare,
 		},
+		Media: models.MediaState{
+			Audio:  audioEnabled,
+			Video:  videoEnabled,
+			Screen: isScreenShare,
+		},
 	}
 
+	done := make(chan struct{})
+	defer close(done)
+	messages := readMessages(conn, done)
+
 	// Try to add participant
-	if err := room.AddParticipant(participant); err != nil {
-		log.Printf("Failed to add participant: %v", err)
-		conn.WriteJSON(SignalingMessage{
-			Type: "error",
-			Data: err.Error(),
-		})
+	wasPaused := room.GetBroadcastStatus() == models.BroadcastPaused
+	if !h.joinRoom(room, participant, messages) {
+		if room.IsEmpty() {
+			h.roomManager.RoomEmptied(roomID)
+		}
 		return
 	}
+	h.roomManager.RoomJoined(roomID)
+	if h.fileManager != nil {
+		h.fileManager.RoomJoined(room)
+	}
+
+	session := store.SessionRecord{
+		RoomID:        roomID,
+		ParticipantID: participantID,
+		DisplayName:   username,
+		Token:         participant.Token,
+		Role:          participant.Role,
+		JoinedAt:      participant.JoinedAt.Unix(),
+	}
+	h.roomManager.SaveSession(session)
 
 	defer func() {
-		room.RemoveParticipant(participantID)
+		view, _ := room.GetParticipantView(participantID)
+		session.Role = view.Role
+		session.LeftAt = time.Now().Unix()
+		h.roomManager.SaveSession(session)
+
+		spotlighted := room.InSpotlight(participantID)
+		h.changeFloor(room, func() error {
+			room.RemoveParticipant(participantID)
+			return nil
+		}, nil)
+		if spotlighted {
+			h.broadcastSpotlight(room, participantID)
+		}
 		h.webrtcManager.RemovePeerConnection(participantID)
+		if h.sfuManager != nil {
+			h.sfuManager.RemoveParticipant(participantID)
+		}
+		h.admitWaiting(room)
 
 		// Notify others about participant leaving
 		h.broadcastToRoom(room, SignalingMessage{
 			Type:     "participant_left",
 			RoomID:   roomID,
 			SenderID: participantID,
+			Data:     view,
 		}, participantID)
+
+		if view.Role == models.RoleBroadcaster {
+			h.handleBroadcasterLeft(room, participantID)
+		}
+		if room.IsEmpty() {
+			h.roomManager.RoomEmptied(roomID)
+			if h.fileManager != nil {
+				h.fileManager.RoomEmptied(room)
+			}
+		}
 	}()
 
 	// Send room info to the new participant
-	conn.WriteJSON(SignalingMessage{
+	recentChat, chatCursor := room.GetChatPage(0, room.Settings.ChatJoinHistory)
+	participant.Send(SignalingMessage{
 		Type: "room_info",
 		Data: map[string]interface{}{
-			"roomId":       roomID,
-			"roomType":     roomType,
-			"participants": room.GetParticipants(),
-			"chatHistory":  room.GetChatHistory(),
+			"roomId":          roomID,
+			"roomType":        room.Type,
+			"capacity":        room.Capacity,
+			"participantId":   participantID,
+			"token":           participant.Token,
+			"participants":    room.GetParticipantViews(),
+			"handQueue":       room.GetHandQueue(),
+			"stageSlots":      room.Settings.StageSlots,
+			"broadcastStatus": room.GetBroadcastStatus(),
+			"floor":           room.GetFloor(),
+			"spotlight":       room.GetSpotlight(),
+			"chatHistory":     recentChat,
+			"chatCursor":      chatCursor,
+			"directMessages":  room.GetDirectMessages(participantID),
+			"typing":          room.GetTyping(),
+			"readReceipts":    room.GetReadReceipts(),
+			"pinnedMessages":  room.GetPinnedMessages(),
+			"topic":           room.GetTopic(),
+			"metadata":        room.GetRoomMetadata(),
+			"polls":           room.GetPollViews(participantID),
+			"questions":       room.GetQuestions(participantID),
+			"parentRoomId":    room.ParentID,
+			"breakouts":       room.GetBreakouts(),
 		},
 	})
 
 	// Notify others about new participant
+	view, _ := room.GetParticipantView(participantID)
 	h.broadcastToRoom(room, SignalingMessage{
 		Type:     "participant_joined",
 		RoomID:   roomID,
 		SenderID: participantID,
-		Data: map[string]interface{}{
-			"username":      username,
-			"isBroadcaster": isBroadcaster,
-			"isScreenShare": isScreenShare,
-		},
+		Data:     view,
 	}, participantID)
 
-	// Handle messages
-	for {
-		var msg SignalingMessage
-		err := conn.ReadJSON(&msg)
-		if err != nil {
-			log.Printf("Error reading message: %v", err)
-			break
-		}
+	if room.Type == models.Group {
+		h.sendMeshPeers(room, participant)
+	}
+
+	if wasPaused && view.Role == models.RoleBroadcaster {
+		h.broadcastToRoom(room, SignalingMessage{
+			Type:     "broadcast_resumed",
+			RoomID:   roomID,
+			SenderID: participantID,
+			Data:     view,
+		}, participantID)
+	}
 
+	// Handle messages
+	for msg := range messages {
 		msg.SenderID = participantID
 		msg.RoomID = roomID
 
 		// Handle different message types
 		switch msg.Type {
-		case "offer":
-			// Forward offer to the target participant
-			h.broadcastToRoom(room, msg, participantID)
-
-		case "answer":
-			// Forward answer to the target participant
-			h.broadcastToRoom(room, msg, participantID)
+		case "offer", "answer", "ice_candidate":
+			// Forward to the target participant, or to everyone else without one
+			h.relaySignal(room, participant, msg)
 
-		case "ice_candidate":
-			// Forward ICE candidate to the target participant
-			h.broadcastToRoom(room, msg, participantID)
+		case "sfu_offer", "sfu_answer", "sfu_ice_candidate":
+			h.handleSFUSignal(room, participant, msg)
 
 		case "chat":
-			if content, ok := msg.Data.(string); ok {
-				chatMsg := models.ChatMessage{
-					SenderID:   participantID,
-					SenderName: participant.Username,
-					Content:    content,
-					Timestamp:  time.Now().Unix(),
-				}
-				room.AddChatMessage(chatMsg)
-				h.broadcastToRoom(room, SignalingMessage{
-					Type:     "chat",
-					RoomID:   roomID,
-					SenderID: participantID,
-					Data:     chatMsg,
-				}, "")
+			h.handleChat(room, participant, msg)
+
+		case "direct_message":
+			h.handleDirectMessage(room, participant, msg)
+
+		case "chat_history_request":
+			h.handleChatHistoryRequest(room, participant, msg)
+
+		case "chat_edit_history":
+			h.handleChatEditHistory(room, participant, msg)
+
+		case "chat_edit", "chat_delete", "chat_react":
+			h.handleChatUpdate(room, participant, msg)
+
+		case "pin_message", "unpin_message":
+			h.handlePin(room, participant, msg)
+
+		case "poll_create", "poll_vote", "poll_close":
+			h.handlePollMessage(room, participant, msg)
+
+		case "qa_ask", "qa_upvote", "qa_approve", "qa_answer", "qa_dismiss":
+			h.handleQAMessage(room, participant, msg)
+
+		case "breakout_open", "breakout_assign", "breakout_broadcast", "breakout_close":
+			h.handleBreakoutMessage(room, participant, msg)
+
+		case "set_room_metadata":
+			h.handleSetRoomMetadata(room, participant, msg)
+
+		case "set_participant_attributes":
+			h.handleSetParticipantAttributes(room, participant, msg)
+
+		case "set_spotlight":
+			h.handleSetSpotlight(room, participant, msg)
+
+		case "set_pinned":
+			h.handleSetPinned(room, participant, msg)
+
+		case "reaction":
+			h.handleReaction(room, participant, msg)
+
+		case "typing":
+			h.handleTyping(room, participant, msg)
+
+		case "chat_read":
+			h.handleChatRead(room, participant, msg)
+
+		case "media_state":
+			var update models.MediaStateUpdate
+			if err := decodeData(msg.Data, &update); err != nil {
+				log.Printf("Invalid media state from participant %s: %v", participantID, err)
+				continue
+			}
+			if err := h.updateMediaState(room, participantID, update); err != nil {
+				h.sendError(participant, err)
 			}
 
 		case "screen_share_start":
-			participant.ConnectionInfo.IsScreenShare = true
+			sharing := true
+			if err := h.updateMediaState(room, participantID, models.MediaStateUpdate{Screen: &sharing}); err != nil {
+				h.sendError(participant, err)
+				continue
+			}
+			h.setScreenTrack(participant, msg)
 			h.broadcastToRoom(room, msg, participantID)
 
 		case "screen_share_stop":
-			participant.ConnectionInfo.IsScreenShare = false
+			sharing := false
+			if err := h.updateMediaState(room, participantID, models.MediaStateUpdate{Screen: &sharing}); err != nil {
+				h.sendError(participant, err)
+				continue
+			}
+			h.setScreenTrack(participant, msg)
 			h.broadcastToRoom(room, msg, participantID)
 
+		case "raise_hand":
+			h.handleHand(room, participant, true)
+
+		case "lower_hand":
+			h.handleHand(room, participant, false)
+
+		case "promote_to_stage", "demote_from_stage":
+			h.handleStageChange(room, participant, msg)
+
+		case "request_floor", "release_floor", "grant_floor":
+			h.handleFloorMessage(room, participant, msg)
+
+		case "transfer_broadcaster":
+			h.handleTransferBroadcaster(room, participant, msg)
+
 		default:
 			// Broadcast other messages to room participants
 			h.broadcastToRoom(room, msg, participantID)
@@ -193,15 +388,78 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 	}
 }
 
+// updateMediaState stores a participant's media state and broadcasts the changed fields
+func (h *WebSocketHandler) updateMediaState(room *models.Room, participantID string, update models.MediaStateUpdate) error {
+	delta, err := room.UpdateMediaState(participantID, update)
+	if err != nil {
+		return err
+	}
+	if delta.IsEmpty() {
+		return nil
+	}
+
+	h.broadcastToRoom(room, SignalingMessage{
+		Type:     "media_state",
+		RoomID:   room.ID,
+		SenderID: participantID,
+		Data:     delta,
+	}, participantID)
+	return nil
+}
+
 // broadcastToRoom sends a message to all participants in a room except the sender
 func (h *WebSocketHandler) broadcastToRoom(room *models.Room, msg SignalingMessage, excludeID string) {
 	participants := room.GetParticipants()
 	for _, p := range participants {
 		if excludeID == "" || p.ID != excludeID {
-			err := p.Conn.WriteJSON(msg)
+			err := p.Send(msg)
 			if err != nil {
 				log.Printf("Error sending message to participant %s: %v", p.ID, err)
 			}
 		}
 	}
 }
+
+// sendToParticipants sends a message to the given participants of a room
+func (h *WebSocketHandler) sendToParticipants(room *models.Room, msg SignalingMessage, participantIDs []string) {
+	for _, id := range participantIDs {
+		p := room.GetParticipant(id)
+		if p == nil {
+			continue
+		}
+		if err := p.Send(msg); err != nil {
+			log.Printf("Error sending message to participant %s: %v", p.ID, err)
+		}
+	}
+}
+
+// readMessages reads signaling messages from a connection until it fails or
+// done is closed. The returned channel is closed when reading stops.
+func readMessages(conn *websocket.Conn, done <-chan struct{}) <-chan SignalingMessage {
+	messages := make(chan SignalingMessage)
+	go func() {
+		defer close(messages)
+		for {
+			var msg SignalingMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				log.Printf("Error reading message: %v", err)
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return messages
+}
+
+// decodeData converts the loosely typed Data of a signaling message into v
+func decodeData(data interface{}, v interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -1,15 +1,22 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"zeem/internal/models"
 	"zeem/internal/services"
+	"zeem/internal/store"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
 )
 
 func setupTestServer() (*gin.Engine, *services.RoomManager, *services.WebRTCManager) {
@@ -199,3 +206,1258 @@ func TestWebSocketHandler_ScreenSharing(t *testing.T) {
 		t.Fatal("did not receive screen share stop message")
 	}
 }
+
+func TestWebSocketHandler_MediaState(t *testing.T) {
+	router, roomManager, _ := setupTestServer()
+
+	ws1 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=user1")
+	defer ws1.Close()
+	waitForMessage(t, ws1, "room_info")
+
+	ws2 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=user2")
+	defer ws2.Close()
+	waitForMessage(t, ws2, "room_info")
+
+	err := ws1.WriteJSON(SignalingMessage{
+		Type: "media_state",
+		Data: map[string]bool{"audio": false},
+	})
+	if err != nil {
+		t.Fatalf("could not send media state: %v", err)
+	}
+
+	msg := waitForMessage(t, ws2, "media_state")
+	delta, ok := msg.Data.(map[string]interface{})
+	if !ok || delta["audio"] != false {
+		t.Fatalf("unexpected media state delta: %v", msg.Data)
+	}
+	if _, ok := delta["video"]; ok {
+		t.Errorf("expected unchanged fields to be omitted, got %v", delta)
+	}
+
+	for _, view := range roomManager.GetRoom("test-room").GetParticipantViews() {
+		if view.ID == msg.SenderID && view.Media.Audio {
+			t.Error("expected server to store muted audio")
+		}
+	}
+}
+
+func TestWebSocketHandler_StagePromotion(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	broadcaster := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=broadcaster&broadcaster=true")
+	defer broadcaster.Close()
+	waitForMessage(t, broadcaster, "room_info")
+
+	viewer := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=viewer")
+	defer viewer.Close()
+	waitForMessage(t, viewer, "room_info")
+
+	if err := viewer.WriteJSON(SignalingMessage{Type: "raise_hand"}); err != nil {
+		t.Fatalf("could not raise hand: %v", err)
+	}
+	queueMsg := waitForMessage(t, broadcaster, "hand_queue")
+	queue := queueMsg.Data.(map[string]interface{})["queue"].([]interface{})
+	if len(queue) != 1 {
+		t.Fatalf("expected one raised hand, got %v", queue)
+	}
+	viewerID := queue[0].(string)
+
+	if err := broadcaster.WriteJSON(SignalingMessage{
+		Type: "promote_to_stage",
+		Data: map[string]string{"participantId": viewerID},
+	}); err != nil {
+		t.Fatalf("could not promote viewer: %v", err)
+	}
+
+	roleMsg := waitForMessage(t, viewer, "role_changed")
+	if role := roleMsg.Data.(map[string]interface{})["role"]; role != "stage" {
+		t.Errorf("expected stage role, got %v", role)
+	}
+
+	// Only moderators can demote others
+	if err := viewer.WriteJSON(SignalingMessage{
+		Type: "demote_from_stage",
+		Data: map[string]string{"participantId": "unknown"},
+	}); err != nil {
+		t.Fatalf("could not send demote: %v", err)
+	}
+	errMsg := waitForMessage(t, viewer, "error")
+	if code := errMsg.Data.(map[string]interface{})["code"]; code != "permission_denied" {
+		t.Errorf("expected permission_denied, got %v", code)
+	}
+}
+
+func TestWebSocketHandler_BroadcasterReconnect(t *testing.T) {
+	router, roomManager, _ := setupTestServer()
+	settings := models.DefaultRoomSettings()
+	settings.BroadcasterGracePeriod = 200 * time.Millisecond
+	roomManager.SetDefaultSettings(settings)
+
+	broadcaster := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=broadcaster&broadcaster=true")
+	info := waitForMessage(t, broadcaster, "room_info")
+	token := info.Data.(map[string]interface{})["token"].(string)
+
+	viewer := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=viewer")
+	defer viewer.Close()
+	waitForMessage(t, viewer, "room_info")
+
+	broadcaster.Close()
+	waitForMessage(t, viewer, "broadcast_paused")
+
+	// Someone else can't take over the paused broadcast
+	stranger := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=stranger&broadcaster=true")
+	defer stranger.Close()
+	errMsg := waitForMessage(t, stranger, "error")
+	if code := errMsg.Data.(map[string]interface{})["code"]; code != "broadcaster_exists" {
+		t.Errorf("expected broadcaster_exists, got %v", code)
+	}
+
+	broadcaster = createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=broadcaster&broadcaster=true&token="+token)
+	waitForMessage(t, broadcaster, "room_info")
+	waitForMessage(t, viewer, "broadcast_resumed")
+
+	broadcaster.Close()
+	waitForMessage(t, viewer, "broadcast_paused")
+	waitForMessage(t, viewer, "broadcast_ended")
+}
+
+func TestWebSocketHandler_FloorControl(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	sharer := createTestWebSocketConnection(t, router, "?roomId=test-room&type=screen_sharing&username=sharer&screenShare=true")
+	defer sharer.Close()
+	waitForMessage(t, sharer, "room_info")
+
+	other := createTestWebSocketConnection(t, router, "?roomId=test-room&type=screen_sharing&username=other&screenShare=true")
+	defer other.Close()
+	info := waitForMessage(t, other, "room_info")
+	floor := info.Data.(map[string]interface{})["floor"].(map[string]interface{})
+	if floor["presenter"] == "" {
+		t.Fatal("expected the first sharer to hold the floor")
+	}
+
+	if err := other.WriteJSON(SignalingMessage{Type: "screen_share_start"}); err != nil {
+		t.Fatalf("could not send screen share start: %v", err)
+	}
+	errMsg := waitForMessage(t, other, "error")
+	if code := errMsg.Data.(map[string]interface{})["code"]; code != "not_presenter" {
+		t.Errorf("expected not_presenter, got %v", code)
+	}
+
+	if err := other.WriteJSON(SignalingMessage{Type: "request_floor"}); err != nil {
+		t.Fatalf("could not request floor: %v", err)
+	}
+	state := waitForMessage(t, sharer, "floor_state")
+	if queue := state.Data.(map[string]interface{})["queue"].([]interface{}); len(queue) != 1 {
+		t.Errorf("expected one queued participant, got %v", queue)
+	}
+	waitForMessage(t, other, "floor_state")
+
+	if err := sharer.WriteJSON(SignalingMessage{Type: "release_floor"}); err != nil {
+		t.Fatalf("could not release floor: %v", err)
+	}
+	state = waitForMessage(t, other, "floor_state")
+	if state.Data.(map[string]interface{})["presenter"] == floor["presenter"] {
+		t.Error("expected the floor to move to the queued participant")
+	}
+}
+
+func TestWebSocketHandler_ChatEdit(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	ws1 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=user1")
+	defer ws1.Close()
+	waitForMessage(t, ws1, "room_info")
+
+	ws2 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=user2")
+	defer ws2.Close()
+	waitForMessage(t, ws2, "room_info")
+
+	if err := ws1.WriteJSON(SignalingMessage{Type: "chat", Data: "helo"}); err != nil {
+		t.Fatalf("could not send chat message: %v", err)
+	}
+	chat := waitForMessage(t, ws2, "chat")
+	messageID, _ := chat.Data.(map[string]interface{})["id"].(string)
+	if messageID == "" {
+		t.Fatal("expected chat message to have an ID")
+	}
+
+	if err := ws2.WriteJSON(SignalingMessage{
+		Type: "chat_edit",
+		Data: map[string]string{"messageId": messageID, "content": "hijacked"},
+	}); err != nil {
+		t.Fatalf("could not send chat edit: %v", err)
+	}
+	errMsg := waitForMessage(t, ws2, "error")
+	if code := errMsg.Data.(map[string]interface{})["code"]; code != "permission_denied" {
+		t.Errorf("expected permission_denied, got %v", code)
+	}
+
+	if err := ws1.WriteJSON(SignalingMessage{
+		Type: "chat_edit",
+		Data: map[string]string{"messageId": messageID, "content": "hello"},
+	}); err != nil {
+		t.Fatalf("could not send chat edit: %v", err)
+	}
+	edit := waitForMessage(t, ws2, "chat_edit")
+	if content := edit.Data.(map[string]interface{})["content"]; content != "hello" {
+		t.Errorf("expected edited content, got %v", content)
+	}
+
+	ws2.WriteJSON(SignalingMessage{
+		Type: "chat_react",
+		Data: map[string]string{"messageId": messageID, "emoji": strings.Repeat("x", 100)},
+	})
+	if code := waitForMessage(t, ws2, "error").Data.(map[string]interface{})["code"]; code != "reaction_not_allowed" {
+		t.Errorf("expected reaction_not_allowed, got %v", code)
+	}
+	ws2.WriteJSON(SignalingMessage{
+		Type: "chat_react",
+		Data: map[string]string{"messageId": messageID, "emoji": "👍"},
+	})
+	react := waitForMessage(t, ws2, "chat_react")
+	if reactions, _ := react.Data.(map[string]interface{})["reactions"].(map[string]interface{}); len(reactions) != 1 || reactions["👍"] == nil {
+		t.Errorf("expected one allowed reaction, got %v", react.Data)
+	}
+}
+
+func TestWebSocketHandler_DirectMessage(t *testing.T) {
+	router, roomManager, _ := setupTestServer()
+
+	ws1 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=screen_sharing&username=user1")
+	defer ws1.Close()
+	info1 := waitForMessage(t, ws1, "room_info")
+	senderID := info1.Data.(map[string]interface{})["participantId"].(string)
+
+	ws2 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=screen_sharing&username=user2")
+	defer ws2.Close()
+	info2 := waitForMessage(t, ws2, "room_info")
+	recipientID := info2.Data.(map[string]interface{})["participantId"].(string)
+
+	ws3 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=screen_sharing&username=user3")
+	defer ws3.Close()
+	waitForMessage(t, ws3, "room_info")
+
+	if err := ws1.WriteJSON(SignalingMessage{
+		Type: "direct_message",
+		Data: map[string]interface{}{"content": "psst", "recipients": []string{recipientID}},
+	}); err != nil {
+		t.Fatalf("could not send direct message: %v", err)
+	}
+
+	dm := waitForMessage(t, ws2, "direct_message")
+	if dm.SenderID != senderID {
+		t.Errorf("expected sender %s, got %s", senderID, dm.SenderID)
+	}
+	waitForMessage(t, ws1, "direct_message")
+
+	// Commands don't run from direct messages
+	if err := ws1.WriteJSON(SignalingMessage{
+		Type: "direct_message",
+		Data: map[string]interface{}{"content": "/topic secret", "recipients": []string{recipientID}},
+	}); err != nil {
+		t.Fatalf("could not send direct message: %v", err)
+	}
+	dm = waitForMessage(t, ws2, "direct_message")
+	if content := dm.Data.(map[string]interface{})["content"]; content != "/topic secret" {
+		t.Errorf("expected the command to be sent as text, got %v", content)
+	}
+	if topic := roomManager.GetRoom("test-room").GetTopic(); topic != "" {
+		t.Errorf("expected the topic to stay unset, got %q", topic)
+	}
+
+	for {
+		msg, err := readMessage(ws3, 200*time.Millisecond)
+		if err != nil {
+			break
+		}
+		if msg.Type == "direct_message" {
+			t.Fatal("expected direct message not to reach other participants")
+		}
+	}
+}
+
+func TestWebSocketHandler_ChatModeration(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	ws := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=user1")
+	defer ws.Close()
+	waitForMessage(t, ws, "room_info")
+
+	if err := ws.WriteJSON(SignalingMessage{Type: "chat", Data: strings.Repeat("a", 2001)}); err != nil {
+		t.Fatalf("could not send chat message: %v", err)
+	}
+	errMsg := waitForMessage(t, ws, "error")
+	if code := errMsg.Data.(map[string]interface{})["code"]; code != "message_too_long" {
+		t.Errorf("expected message_too_long, got %v", code)
+	}
+
+	if err := ws.WriteJSON(SignalingMessage{Type: "chat", Data: "<i>hi</i>"}); err != nil {
+		t.Fatalf("could not send chat message: %v", err)
+	}
+	chat := waitForMessage(t, ws, "chat")
+	if content := chat.Data.(map[string]interface{})["content"]; content != "hi" {
+		t.Errorf("expected sanitized content, got %v", content)
+	}
+}
+
+func TestWebSocketHandler_FileCleanup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	roomManager := services.NewRoomManager()
+	wsHandler := NewWebSocketHandler(roomManager, services.NewWebRTCManager())
+	files, err := store.NewDiskFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskFileStore failed: %v", err)
+	}
+	wsHandler.SetFileManager(services.NewFileManager(files, 1<<10, []string{"text/plain"}, 100*time.Millisecond))
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	waitEmpty := func(room *models.Room) {
+		deadline := time.Now().Add(time.Second)
+		for !room.IsEmpty() {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the room to be empty")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	ws1 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=user1")
+	waitForMessage(t, ws1, "room_info")
+	room := roomManager.GetRoom("test-room")
+	attachment, err := wsHandler.fileManager.Save(room.ID, "notes.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	room.AddChatMessage(models.ChatMessage{SenderID: "user1", Attachment: &attachment})
+
+	// Rejoining before the retention period keeps the files
+	ws1.Close()
+	waitEmpty(room)
+	ws2 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=user2")
+	waitForMessage(t, ws2, "room_info")
+	time.Sleep(200 * time.Millisecond)
+	if _, ok := room.GetAttachment(attachment.ID); !ok {
+		t.Fatal("expected files to be kept after someone rejoined")
+	}
+
+	ws2.Close()
+	waitEmpty(room)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := room.GetAttachment(attachment.ID); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected files to be cleaned up after the room emptied")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocketHandler_TypingAndReceipts(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	ws1 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=user1")
+	defer ws1.Close()
+	waitForMessage(t, ws1, "room_info")
+
+	ws2 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=user2")
+	defer ws2.Close()
+	waitForMessage(t, ws2, "room_info")
+	waitForMessage(t, ws1, "participant_joined")
+
+	if err := ws1.WriteJSON(SignalingMessage{Type: "typing"}); err != nil {
+		t.Fatalf("could not send typing: %v", err)
+	}
+	typing := waitForMessage(t, ws2, "typing")
+	if ids := typing.Data.(map[string]interface{})["participants"].([]interface{}); len(ids) != 1 {
+		t.Errorf("expected one typing participant, got %v", ids)
+	}
+
+	if err := ws1.WriteJSON(SignalingMessage{Type: "chat", Data: "hello"}); err != nil {
+		t.Fatalf("could not send chat message: %v", err)
+	}
+	typing = waitForMessage(t, ws2, "typing")
+	if ids := typing.Data.(map[string]interface{})["participants"].([]interface{}); len(ids) != 0 {
+		t.Errorf("expected sending to clear typing, got %v", ids)
+	}
+	chat := waitForMessage(t, ws2, "chat")
+	messageID := chat.Data.(map[string]interface{})["id"].(string)
+	waitForMessage(t, ws1, "chat")
+
+	if err := ws2.WriteJSON(SignalingMessage{Type: "chat_read", Data: map[string]string{"messageId": messageID}}); err != nil {
+		t.Fatalf("could not send chat_read: %v", err)
+	}
+	read := waitForMessage(t, ws1, "chat_read")
+	if id := read.Data.(map[string]interface{})["messageId"]; id != messageID {
+		t.Errorf("expected watermark at %s, got %v", messageID, id)
+	}
+
+	// Late joiners get the current typing and read state
+	ws1.WriteJSON(SignalingMessage{Type: "chat_read", Data: map[string]string{"messageId": messageID}})
+	waitForMessage(t, ws2, "chat_read")
+	ws1.WriteJSON(SignalingMessage{Type: "typing"})
+	waitForMessage(t, ws2, "typing")
+	ws2.Close()
+	waitForMessage(t, ws1, "participant_left")
+
+	ws3 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=user3")
+	defer ws3.Close()
+	info := waitForMessage(t, ws3, "room_info").Data.(map[string]interface{})
+	if ids := info["typing"].([]interface{}); len(ids) != 1 {
+		t.Errorf("expected one typing participant in room_info, got %v", ids)
+	}
+	receipts := info["readReceipts"].([]interface{})
+	if len(receipts) != 1 || receipts[0].(map[string]interface{})["messageId"] != messageID {
+		t.Errorf("expected the remaining watermark in room_info, got %v", receipts)
+	}
+}
+
+func TestWebSocketHandler_TypingTimer(t *testing.T) {
+	h := NewWebSocketHandler(services.NewRoomManager(), services.NewWebRTCManager())
+	settings := models.DefaultRoomSettings()
+	settings.TypingTimeout = 30 * time.Millisecond
+	room := models.NewRoomWithSettings("typing", models.OneToOne, settings)
+	p := &models.Participant{ID: "typist", ConnectionInfo: &models.ConnectionInfo{Type: models.OneToOne}}
+	room.AddParticipant(p)
+
+	for i := 0; i < 100; i++ {
+		h.handleTyping(room, p, SignalingMessage{Type: "typing"})
+	}
+	h.typingMutex.Lock()
+	timers := len(h.typingTimers)
+	h.typingMutex.Unlock()
+	if timers != 1 {
+		t.Errorf("expected one typing timer for the room, got %d", timers)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if typing := room.GetTyping(); len(typing) != 0 {
+		t.Errorf("expected the typing mark to lapse, got %v", typing)
+	}
+	h.typingMutex.Lock()
+	timers = len(h.typingTimers)
+	h.typingMutex.Unlock()
+	if timers != 0 {
+		t.Errorf("expected the timer to stop once nobody is typing, got %d", timers)
+	}
+}
+
+func TestWebSocketHandler_MentionsAndPins(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	ws1 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=alice")
+	defer ws1.Close()
+	waitForMessage(t, ws1, "room_info")
+
+	ws2 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=bob")
+	defer ws2.Close()
+	waitForMessage(t, ws2, "room_info")
+	waitForMessage(t, ws1, "participant_joined")
+
+	if err := ws2.WriteJSON(SignalingMessage{Type: "chat", Data: "hi @alice"}); err != nil {
+		t.Fatalf("could not send chat message: %v", err)
+	}
+	waitForMessage(t, ws2, "chat")
+	waitForMessage(t, ws1, "chat")
+	mention := waitForMessage(t, ws1, "mention")
+	messageID := mention.Data.(map[string]interface{})["id"].(string)
+
+	if err := ws1.WriteJSON(SignalingMessage{
+		Type: "chat",
+		Data: map[string]string{"content": "hello", "replyTo": messageID},
+	}); err != nil {
+		t.Fatalf("could not send reply: %v", err)
+	}
+	reply := waitForMessage(t, ws2, "chat")
+	if replyTo := reply.Data.(map[string]interface{})["reply_to"]; replyTo != messageID {
+		t.Errorf("expected reply to %s, got %v", messageID, replyTo)
+	}
+
+	if err := ws2.WriteJSON(SignalingMessage{Type: "pin_message", Data: map[string]string{"messageId": messageID}}); err != nil {
+		t.Fatalf("could not send pin: %v", err)
+	}
+	errMsg := waitForMessage(t, ws2, "error")
+	if code := errMsg.Data.(map[string]interface{})["code"]; code != "permission_denied" {
+		t.Errorf("expected permission_denied, got %v", code)
+	}
+
+	if err := ws1.WriteJSON(SignalingMessage{Type: "pin_message", Data: map[string]string{"messageId": messageID}}); err != nil {
+		t.Fatalf("could not send pin: %v", err)
+	}
+	pins := waitForMessage(t, ws2, "pinned_messages")
+	if messages := pins.Data.(map[string]interface{})["messages"].([]interface{}); len(messages) != 1 {
+		t.Errorf("expected one pinned message, got %v", messages)
+	}
+
+	ws2.Close()
+	waitForMessage(t, ws1, "participant_left")
+	ws3 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=carol")
+	defer ws3.Close()
+	info := waitForMessage(t, ws3, "room_info").Data.(map[string]interface{})
+	pinned := info["pinnedMessages"].([]interface{})
+	if len(pinned) != 1 || pinned[0].(map[string]interface{})["id"] != messageID {
+		t.Errorf("expected the pinned message in room_info, got %v", pinned)
+	}
+}
+
+func TestWebSocketHandler_SlashCommands(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	host := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=alice")
+	defer host.Close()
+	waitForMessage(t, host, "room_info")
+
+	guest := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=bob")
+	defer guest.Close()
+	waitForMessage(t, guest, "room_info")
+	waitForMessage(t, host, "participant_joined")
+
+	guest.WriteJSON(SignalingMessage{Type: "chat", Data: "/help"})
+	help := waitForMessage(t, guest, "command_help")
+	for _, c := range help.Data.(map[string]interface{})["commands"].([]interface{}) {
+		if name := c.(map[string]interface{})["name"]; name == "mute" {
+			t.Error("expected /mute to be hidden from participants")
+		}
+	}
+
+	guest.WriteJSON(SignalingMessage{Type: "chat", Data: "/dance"})
+	if code := waitForMessage(t, guest, "error").Data.(map[string]interface{})["code"]; code != "unknown_command" {
+		t.Errorf("expected unknown_command, got %v", code)
+	}
+	guest.WriteJSON(SignalingMessage{Type: "chat", Data: "/mute @alice"})
+	if code := waitForMessage(t, guest, "error").Data.(map[string]interface{})["code"]; code != "permission_denied" {
+		t.Errorf("expected permission_denied, got %v", code)
+	}
+
+	host.WriteJSON(SignalingMessage{Type: "chat", Data: "/mute"})
+	if code := waitForMessage(t, host, "error").Data.(map[string]interface{})["code"]; code != "invalid_command" {
+		t.Errorf("expected invalid_command, got %v", code)
+	}
+	host.WriteJSON(SignalingMessage{Type: "chat", Data: "/mute @Bob"})
+	waitForMessage(t, guest, "muted")
+
+	host.WriteJSON(SignalingMessage{Type: "chat", Data: "/topic Quarterly review"})
+	topic := waitForMessage(t, guest, "topic_changed")
+	if got := topic.Data.(map[string]interface{})["topic"]; got != "Quarterly review" {
+		t.Errorf("expected topic to be set, got %v", got)
+	}
+	waitForMessage(t, host, "topic_changed")
+
+	guest.WriteJSON(SignalingMessage{Type: "chat", Data: "//shrug"})
+	chat := waitForMessage(t, host, "chat")
+	if content := chat.Data.(map[string]interface{})["content"]; content != "/shrug" {
+		t.Errorf("expected escaped slash to be posted, got %v", content)
+	}
+
+	guest.Close()
+	waitForMessage(t, host, "participant_left")
+	late := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=carol")
+	defer late.Close()
+	if topic := waitForMessage(t, late, "room_info").Data.(map[string]interface{})["topic"]; topic != "Quarterly review" {
+		t.Errorf("expected the topic in room_info, got %v", topic)
+	}
+}
+
+func TestWebSocketHandler_Polls(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	host := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=alice")
+	defer host.Close()
+	waitForMessage(t, host, "room_info")
+
+	guest := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=bob")
+	defer guest.Close()
+	waitForMessage(t, guest, "room_info")
+	waitForMessage(t, host, "participant_joined")
+
+	host.WriteJSON(SignalingMessage{Type: "chat", Data: "/poll <b>Lunch?</b> | Pizza | Sushi"})
+	created := waitForMessage(t, guest, "poll_created").Data.(map[string]interface{})
+	if created["results"] != nil {
+		t.Errorf("expected no results for participants, got %v", created["results"])
+	}
+	if created["question"] != "Lunch?" {
+		t.Errorf("expected the question to go through chat moderation, got %v", created["question"])
+	}
+	pollID := created["id"].(string)
+	waitForMessage(t, host, "poll_created")
+
+	options := make([]string, models.MaxPollOptions+1)
+	for i := range options {
+		options[i] = fmt.Sprintf("option %d", i)
+	}
+	host.WriteJSON(SignalingMessage{Type: "poll_create", Data: map[string]interface{}{"question": "Too many?", "options": options}})
+	if code := waitForMessage(t, host, "error").Data.(map[string]interface{})["code"]; code != "too_many_poll_options" {
+		t.Errorf("expected too_many_poll_options, got %v", code)
+	}
+
+	guest.WriteJSON(SignalingMessage{Type: "poll_vote", Data: map[string]interface{}{"pollId": pollID, "choices": []int{1}}})
+	live := waitForMessage(t, host, "poll_updated").Data.(map[string]interface{})
+	if counts := live["results"].(map[string]interface{})["counts"].([]interface{}); counts[1] != float64(1) {
+		t.Errorf("expected live results for the host, got %v", counts)
+	}
+	waitForMessage(t, guest, "poll_updated")
+
+	guest.WriteJSON(SignalingMessage{Type: "poll_vote", Data: map[string]interface{}{"pollId": pollID, "choices": []int{0}}})
+	if code := waitForMessage(t, guest, "error").Data.(map[string]interface{})["code"]; code != "already_voted" {
+		t.Errorf("expected already_voted, got %v", code)
+	}
+
+	host.WriteJSON(SignalingMessage{Type: "poll_close", Data: map[string]string{"pollId": pollID}})
+	closed := waitForMessage(t, guest, "poll_closed").Data.(map[string]interface{})
+	if closed["closed"] != true || closed["results"] == nil {
+		t.Errorf("expected final results for everyone, got %v", closed)
+	}
+	waitForMessage(t, host, "poll_closed")
+
+	guest.Close()
+	waitForMessage(t, host, "participant_left")
+	late := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=carol")
+	defer late.Close()
+	polls := waitForMessage(t, late, "room_info").Data.(map[string]interface{})["polls"].([]interface{})
+	if len(polls) != 1 || polls[0].(map[string]interface{})["id"] != pollID || polls[0].(map[string]interface{})["results"] == nil {
+		t.Errorf("expected the closed poll with its results in room_info, got %v", polls)
+	}
+}
+
+func TestWebSocketHandler_QA(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	broadcaster := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=host&broadcaster=true")
+	defer broadcaster.Close()
+	waitForMessage(t, broadcaster, "room_info")
+
+	viewer := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=viewer")
+	defer viewer.Close()
+	waitForMessage(t, viewer, "room_info")
+	waitForMessage(t, broadcaster, "participant_joined")
+
+	other := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=other")
+	defer other.Close()
+	waitForMessage(t, other, "room_info")
+	waitForMessage(t, broadcaster, "participant_joined")
+	waitForMessage(t, viewer, "participant_joined")
+
+	viewer.WriteJSON(SignalingMessage{Type: "qa_ask", Data: map[string]string{"text": "Will slides be shared?"}})
+	question := waitForMessage(t, broadcaster, "qa_updated").Data.(map[string]interface{})["question"].(map[string]interface{})
+	if question["status"] != "pending" {
+		t.Fatalf("expected the moderator to see the pending question, got %v", question)
+	}
+	questionID := question["id"].(string)
+	waitForMessage(t, viewer, "qa_updated")
+
+	viewer.WriteJSON(SignalingMessage{Type: "qa_approve", Data: map[string]string{"questionId": questionID}})
+	if code := waitForMessage(t, viewer, "error").Data.(map[string]interface{})["code"]; code != "permission_denied" {
+		t.Errorf("expected permission_denied, got %v", code)
+	}
+
+	// Other viewers first hear of the question once it is approved
+	broadcaster.WriteJSON(SignalingMessage{Type: "qa_approve", Data: map[string]string{"questionId": questionID}})
+	msg, err := readMessage(other, time.Second)
+	if err != nil || msg.Type != "qa_updated" {
+		t.Fatalf("expected the approved question to be the next message, got %v (%v)", msg, err)
+	}
+	if status := msg.Data.(map[string]interface{})["question"].(map[string]interface{})["status"]; status != "approved" {
+		t.Errorf("expected the approved question, got %v", status)
+	}
+	waitForMessage(t, viewer, "qa_updated")
+	waitForMessage(t, broadcaster, "qa_updated")
+
+	broadcaster.WriteJSON(SignalingMessage{Type: "qa_answer", Data: map[string]string{"questionId": questionID, "answer": "Yes"}})
+	question = waitForMessage(t, viewer, "qa_updated").Data.(map[string]interface{})["question"].(map[string]interface{})
+	if question["status"] != "answered" || question["answer"] != "Yes" {
+		t.Errorf("expected the question to be answered, got %v", question)
+	}
+	waitForMessage(t, other, "qa_updated")
+
+	broadcaster.WriteJSON(SignalingMessage{Type: "qa_dismiss", Data: map[string]string{"questionId": questionID}})
+	if removed := waitForMessage(t, other, "qa_removed").Data.(map[string]interface{})["questionId"]; removed != questionID {
+		t.Errorf("expected the dismissed question to be removed, got %v", removed)
+	}
+
+	broadcaster.WriteJSON(SignalingMessage{Type: "qa_ask", Data: map[string]string{"text": "Any questions?"}})
+	waitForMessage(t, other, "qa_updated")
+	late := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=late")
+	defer late.Close()
+	questions := waitForMessage(t, late, "room_info").Data.(map[string]interface{})["questions"].([]interface{})
+	if len(questions) != 1 || questions[0].(map[string]interface{})["text"] != "Any questions?" {
+		t.Errorf("expected the open question in room_info, got %v", questions)
+	}
+}
+
+func TestWebSocketHandler_Reactions(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	ws1 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=host&broadcaster=true")
+	defer ws1.Close()
+	waitForMessage(t, ws1, "room_info")
+
+	ws2 := createTestWebSocketConnection(t, router, "?roomId=test-room&type=broadcasting&username=viewer")
+	defer ws2.Close()
+	waitForMessage(t, ws2, "room_info")
+	waitForMessage(t, ws1, "participant_joined")
+
+	ws2.WriteJSON(SignalingMessage{Type: "reaction", Data: map[string]string{"emoji": "🍕"}})
+	if code := waitForMessage(t, ws2, "error").Data.(map[string]interface{})["code"]; code != "reaction_not_allowed" {
+		t.Errorf("expected reaction_not_allowed, got %v", code)
+	}
+
+	ws2.WriteJSON(SignalingMessage{Type: "reaction", Data: map[string]string{"emoji": "👏"}})
+	ws2.WriteJSON(SignalingMessage{Type: "reaction", Data: map[string]string{"emoji": "👏"}})
+	burst := waitForMessage(t, ws1, "reactions")
+	if count := burst.Data.(map[string]interface{})["counts"].(map[string]interface{})["👏"]; count != float64(2) {
+		t.Errorf("expected both reactions in one burst, got %v", burst.Data)
+	}
+}
+
+func TestWebSocketHandler_Breakouts(t *testing.T) {
+	router, roomManager, _ := setupTestServer()
+
+	host := createTestWebSocketConnection(t, router, "?roomId=main&type=screen_sharing&username=host")
+	defer host.Close()
+	waitForMessage(t, host, "room_info")
+
+	guest := createTestWebSocketConnection(t, router, "?roomId=main&type=screen_sharing&username=guest")
+	defer guest.Close()
+	waitForMessage(t, guest, "room_info")
+	waitForMessage(t, host, "participant_joined")
+
+	host.WriteJSON(SignalingMessage{Type: "breakout_open", Data: map[string]int{"count": 2}})
+	state := waitForMessage(t, guest, "breakout_state").Data.(map[string]interface{})
+	if rooms := state["rooms"].([]interface{}); len(rooms) != 2 {
+		t.Fatalf("expected two breakout rooms, got %v", rooms)
+	}
+	if breakout := roomManager.GetRoom("main-breakout-1"); breakout == nil || breakout.ParentID != "main" {
+		t.Fatal("expected the breakout room to be linked to its parent")
+	}
+	waitForMessage(t, host, "breakout_state")
+
+	host.WriteJSON(SignalingMessage{Type: "breakout_assign", Data: map[string]bool{"auto": true}})
+	move := waitForMessage(t, guest, "move_to_room")
+	if roomID := move.Data.(map[string]interface{})["roomId"]; roomID != "main-breakout-1" {
+		t.Fatalf("expected a move to the first breakout, got %v", roomID)
+	}
+
+	// The guest re-joins in the breakout room
+	guest.Close()
+	moved := createTestWebSocketConnection(t, router, "?roomId=main-breakout-1&type=screen_sharing&username=guest")
+	defer moved.Close()
+	info := waitForMessage(t, moved, "room_info").Data.(map[string]interface{})
+	if info["parentRoomId"] != "main" {
+		t.Errorf("expected the breakout to report its parent, got %v", info["parentRoomId"])
+	}
+
+	host.WriteJSON(SignalingMessage{Type: "breakout_broadcast", Data: map[string]string{"text": "5 minutes left"}})
+	if text := waitForMessage(t, moved, "breakout_message").Data.(map[string]interface{})["text"]; text != "5 minutes left" {
+		t.Errorf("expected the host broadcast, got %v", text)
+	}
+
+	host.WriteJSON(SignalingMessage{Type: "breakout_close", Data: map[string]int{"countdownSeconds": 1}})
+	waitForMessage(t, moved, "breakout_closing")
+	back, err := readMessage(moved, 3*time.Second)
+	if err != nil || back.Type != "move_to_room" {
+		t.Fatalf("expected a move back after the countdown, got %v (%v)", back, err)
+	}
+	if roomID := back.Data.(map[string]interface{})["roomId"]; roomID != "main" {
+		t.Errorf("expected a move back to the main room, got %v", roomID)
+	}
+	if !roomManager.RoomExists("main-breakout-1") {
+		t.Error("expected the breakout to be kept until everyone has left")
+	}
+
+	moved.Close()
+	deadline := time.Now().Add(time.Second)
+	for roomManager.RoomExists("main-breakout-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the breakout to be removed once empty")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	again := createTestWebSocketConnection(t, router, "?roomId=main-breakout-1&type=screen_sharing&username=guest")
+	defer again.Close()
+	if code := waitForMessage(t, again, "error").Data.(map[string]interface{})["code"]; code != "breakout_closed" {
+		t.Errorf("expected a closed breakout to reject joins, got %v", code)
+	}
+	if roomManager.RoomExists("main-breakout-1") {
+		t.Error("expected a closed breakout not to be recreated")
+	}
+}
+
+func TestWebSocketHandler_GroupMesh(t *testing.T) {
+	router, roomManager, _ := setupTestServer()
+	roomManager.ScheduleRoom("mesh", models.Group, 0, 2)
+
+	first := createTestWebSocketConnection(t, router, "?roomId=mesh&type=group&username=first")
+	defer first.Close()
+	firstInfo := waitForMessage(t, first, "room_info").Data.(map[string]interface{})
+	firstID := firstInfo["participantId"].(string)
+	if peers := waitForMessage(t, first, "mesh_peers").Data.(map[string]interface{})["peers"].([]interface{}); len(peers) != 0 {
+		t.Errorf("expected nobody to offer to, got %v", peers)
+	}
+
+	// Joiners without a type are told the room's own type
+	second := createTestWebSocketConnection(t, router, "?roomId=mesh&username=second")
+	defer second.Close()
+	if roomType := waitForMessage(t, second, "room_info").Data.(map[string]interface{})["roomType"]; roomType != "group" {
+		t.Errorf("expected roomType group, got %v", roomType)
+	}
+	peers := waitForMessage(t, second, "mesh_peers").Data.(map[string]interface{})["peers"].([]interface{})
+	if len(peers) != 1 || peers[0] != firstID {
+		t.Fatalf("expected the newcomer to offer to the first participant, got %v", peers)
+	}
+	waitForMessage(t, first, "participant_joined")
+
+	second.WriteJSON(SignalingMessage{Type: "offer", TargetID: firstID, Data: map[string]string{"type": "offer", "sdp": "test"}})
+	offer := waitForMessage(t, first, "offer")
+	if offer.TargetID != firstID || offer.SenderID == "" {
+		t.Errorf("expected a targeted offer, got %+v", offer)
+	}
+
+	third := createTestWebSocketConnection(t, router, "?roomId=mesh&type=group&username=third")
+	defer third.Close()
+	rejected := waitForMessage(t, third, "error").Data.(map[string]interface{})
+	if rejected["code"] != "room_full" || rejected["participants"] != float64(2) || rejected["capacity"] != float64(2) {
+		t.Errorf("expected a room_full error with the count, got %v", rejected)
+	}
+}
+
+func TestWebSocketHandler_WaitQueue(t *testing.T) {
+	router, roomManager, _ := setupTestServer()
+	settings := models.DefaultRoomSettings()
+	settings.Overflow = models.OverflowQueue
+	roomManager.SetDefaultSettings(settings)
+
+	first := createTestWebSocketConnection(t, router, "?roomId=queue&type=one_to_one&username=first")
+	defer first.Close()
+	waitForMessage(t, first, "room_info")
+	second := createTestWebSocketConnection(t, router, "?roomId=queue&type=one_to_one&username=second")
+	waitForMessage(t, second, "room_info")
+
+	waiting := createTestWebSocketConnection(t, router, "?roomId=queue&type=one_to_one&username=waiting")
+	defer waiting.Close()
+	position := waitForMessage(t, waiting, "queue_position").Data.(map[string]interface{})
+	if position["position"] != float64(1) {
+		t.Fatalf("expected to be first in line, got %v", position)
+	}
+
+	second.Close()
+	info := waitForMessage(t, waiting, "room_info").Data.(map[string]interface{})
+	if participants := info["participants"].([]interface{}); len(participants) != 2 {
+		t.Errorf("expected to be admitted next to the first participant, got %v", participants)
+	}
+}
+
+func TestWebSocketHandler_Metadata(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	host := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=alice")
+	defer host.Close()
+	waitForMessage(t, host, "room_info")
+
+	guest := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=bob")
+	defer guest.Close()
+	guestID := waitForMessage(t, guest, "room_info").Data.(map[string]interface{})["participantId"]
+	waitForMessage(t, host, "participant_joined")
+
+	guest.WriteJSON(SignalingMessage{Type: "set_room_metadata", Data: map[string]interface{}{"metadata": map[string]string{"agenda": "intro"}}})
+	if code := waitForMessage(t, guest, "error").Data.(map[string]interface{})["code"]; code != "permission_denied" {
+		t.Errorf("expected guests not to edit room metadata, got %v", code)
+	}
+
+	host.WriteJSON(SignalingMessage{Type: "set_room_metadata", Data: map[string]interface{}{"metadata": map[string]string{"agenda": "intro"}}})
+	changed := waitForMessage(t, guest, "room_metadata_changed").Data.(map[string]interface{})["metadata"].(map[string]interface{})
+	if len(changed) != 1 || changed["agenda"] != "intro" {
+		t.Errorf("expected the metadata diff, got %v", changed)
+	}
+
+	host.WriteJSON(SignalingMessage{Type: "set_room_metadata", Data: map[string]interface{}{"metadata": map[string]string{"topic": "<script>x</script>Roadmap"}}})
+	if topic := waitForMessage(t, guest, "topic_changed").Data.(map[string]interface{})["topic"]; topic != "xRoadmap" {
+		t.Errorf("expected the topic to go through chat moderation, got %v", topic)
+	}
+	waitForMessage(t, host, "topic_changed")
+
+	guest.WriteJSON(SignalingMessage{Type: "set_participant_attributes", Data: map[string]interface{}{"attributes": map[string]string{"language": "en"}}})
+	attributes := waitForMessage(t, host, "participant_attributes_changed").Data.(map[string]interface{})
+	if attributes["participantId"] != guestID || attributes["attributes"].(map[string]interface{})["language"] != "en" {
+		t.Errorf("expected the attribute diff, got %v", attributes)
+	}
+}
+
+func TestWebSocketHandler_Spotlight(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	host := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=alice")
+	defer host.Close()
+	waitForMessage(t, host, "room_info")
+
+	guest := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=bob")
+	guestID := waitForMessage(t, guest, "room_info").Data.(map[string]interface{})["participantId"]
+	waitForMessage(t, host, "participant_joined")
+
+	host.WriteJSON(SignalingMessage{Type: "set_spotlight", Data: map[string]interface{}{
+		"spotlight": []map[string]interface{}{{"participantId": guestID}},
+	}})
+	spotlight := waitForMessage(t, guest, "spotlight").Data.(map[string]interface{})["spotlight"].([]interface{})
+	if len(spotlight) != 1 || spotlight[0].(map[string]interface{})["participantId"] != guestID {
+		t.Fatalf("expected the guest in the spotlight, got %v", spotlight)
+	}
+	waitForMessage(t, host, "spotlight")
+
+	guest.Close()
+	cleared := waitForMessage(t, host, "spotlight").Data.(map[string]interface{})["spotlight"].([]interface{})
+	if len(cleared) != 0 {
+		t.Errorf("expected the spotlight cleared when the guest left, got %v", cleared)
+	}
+}
+
+func TestWebSocketHandler_Pinned(t *testing.T) {
+	router, _, _ := setupTestServer()
+
+	host := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=alice")
+	defer host.Close()
+	hostID := waitForMessage(t, host, "room_info").Data.(map[string]interface{})["participantId"]
+
+	guest := createTestWebSocketConnection(t, router, "?roomId=test-room&type=one_to_one&username=bob")
+	defer guest.Close()
+	guestID := waitForMessage(t, guest, "room_info").Data.(map[string]interface{})["participantId"]
+	waitForMessage(t, host, "participant_joined")
+
+	guest.WriteJSON(SignalingMessage{Type: "set_pinned", Data: map[string]interface{}{
+		"pinned": []map[string]interface{}{{"participantId": guestID}},
+	}})
+	if code := waitForMessage(t, guest, "error").Data.(map[string]interface{})["code"]; code != "invalid_pin" {
+		t.Errorf("expected invalid_pin when pinning oneself, got %v", code)
+	}
+
+	guest.WriteJSON(SignalingMessage{Type: "set_pinned", Data: map[string]interface{}{
+		"pinned": []map[string]interface{}{{"participantId": hostID, "trackId": "camera"}},
+	}})
+	pinned := waitForMessage(t, guest, "pinned").Data.(map[string]interface{})["pinned"].([]interface{})
+	pin := pinned[0].(map[string]interface{})
+	if len(pinned) != 1 || pin["participantId"] != hostID || pin["trackId"] != "camera" {
+		t.Errorf("expected the host's camera pinned, got %v", pinned)
+	}
+}
+
+func setupSFUTestServer(lastN int) (*gin.Engine, *services.SFUManager) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	wsHandler := NewWebSocketHandler(services.NewRoomManager(), services.NewWebRTCManager())
+	sfu := services.NewSFUManager()
+	sfu.SetLastN(lastN)
+	wsHandler.SetSFUManager(sfu, 50*time.Millisecond)
+
+	router.GET("/ws", wsHandler.HandleConnection)
+	return router, sfu
+}
+
+// sfuClient is a test participant that sends and receives media through the
+// SFU. SFU negotiation is answered in the background; every other message
+// goes to messages.
+type sfuClient struct {
+	t        *testing.T
+	ws       *websocket.Conn
+	pc       *webrtc.PeerConnection
+	id       string
+	messages chan *SignalingMessage
+	tracks   chan *webrtc.TrackRemote
+	done     chan struct{}
+	writeMu  sync.Mutex
+}
+
+// connectSFUClient joins a room and negotiates with the SFU, publishing one
+// track of each given kind, or receiving only without any
+func connectSFUClient(t *testing.T, router *gin.Engine, query string, kinds ...webrtc.RTPCodecType) (*sfuClient, []*webrtc.TrackLocalStaticRTP) {
+	me := &webrtc.MediaEngine{}
+	if err := me.RegisterDefaultCodecs(); err != nil {
+		t.Fatal(err)
+	}
+	if err := me.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: sdp.AudioLevelURI}, webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &sfuClient{
+		t:        t,
+		ws:       createTestWebSocketConnection(t, router, query),
+		pc:       pc,
+		messages: make(chan *SignalingMessage, 100),
+		tracks:   make(chan *webrtc.TrackRemote, 10),
+		done:     make(chan struct{}),
+	}
+	t.Cleanup(func() {
+		c.ws.Close()
+		c.pc.Close()
+	})
+	info, err := readMessage(c.ws, time.Second)
+	if err != nil || info.Type != "room_info" {
+		t.Fatalf("expected room_info, got %v (%v)", info, err)
+	}
+	c.id = info.Data.(map[string]interface{})["participantId"].(string)
+	// The read loop waits for as long as the test needs
+	c.ws.SetReadDeadline(time.Time{})
+
+	var tracks []*webrtc.TrackLocalStaticRTP
+	for _, kind := range kinds {
+		codec := webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}
+		if kind == webrtc.RTPCodecTypeAudio {
+			codec = webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}
+		}
+		track, err := webrtc.NewTrackLocalStaticRTP(codec, kind.String(), c.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pc.AddTrack(track); err != nil {
+			t.Fatal(err)
+		}
+		tracks = append(tracks, track)
+	}
+	if len(kinds) == 0 {
+		if _, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		c.tracks <- track
+		for {
+			if _, _, err := track.ReadRTP(); err != nil {
+				return
+			}
+		}
+	})
+	pc.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate != nil {
+			c.send("sfu_ice_candidate", candidate.ToJSON())
+		}
+	})
+	go c.readLoop()
+	// Stop answering the SFU before the peer connection closes
+	t.Cleanup(func() {
+		c.ws.Close()
+		<-c.done
+	})
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pc.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	c.send("sfu_offer", offer)
+	return c, tracks
+}
+
+func (c *sfuClient) send(msgType string, data interface{}) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.WriteJSON(SignalingMessage{Type: msgType, Data: data})
+}
+
+// readLoop answers the SFU and passes on everything else. Offers from the
+// SFU that cross one of the client's own are ignored; the SFU gives way.
+func (c *sfuClient) readLoop() {
+	defer close(c.done)
+	defer close(c.messages)
+	for {
+		var msg SignalingMessage
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			return
+		}
+		var err error
+		switch msg.Type {
+		case "sfu_offer":
+			var offer webrtc.SessionDescription
+			decodeData(msg.Data, &offer)
+			if c.pc.SignalingState() != webrtc.SignalingStateStable {
+				continue
+			}
+			if err = c.pc.SetRemoteDescription(offer); err == nil {
+				var answer webrtc.SessionDescription
+				if answer, err = c.pc.CreateAnswer(nil); err == nil {
+					if err = c.pc.SetLocalDescription(answer); err == nil {
+						c.send("sfu_answer", answer)
+					}
+				}
+			}
+		case "sfu_answer":
+			var answer webrtc.SessionDescription
+			decodeData(msg.Data, &answer)
+			err = c.pc.SetRemoteDescription(answer)
+		case "sfu_ice_candidate":
+			var candidate webrtc.ICECandidateInit
+			decodeData(msg.Data, &candidate)
+			err = c.pc.AddICECandidate(candidate)
+		default:
+			select {
+			case c.messages <- &msg:
+			default:
+			}
+		}
+		if err != nil {
+			c.t.Errorf("SFU negotiation failed on %s: %v", msg.Type, err)
+		}
+	}
+}
+
+// waitFor returns the next message of a type, skipping others
+func (c *sfuClient) waitFor(msgType string, timeout time.Duration) *SignalingMessage {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("connection closed while waiting for %s", msgType)
+			}
+			if msg.Type == msgType {
+				return msg
+			}
+		case <-deadline:
+			c.t.Fatalf("did not receive expected message type: %s", msgType)
+		}
+	}
+}
+
+// publish writes packets to a track every 20ms until the test ends. Audio
+// packets carry the given audio level.
+func (c *sfuClient) publish(track *webrtc.TrackLocalStaticRTP, level uint8) {
+	done := make(chan struct{})
+	c.t.Cleanup(func() { close(done) })
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for seq := uint16(0); ; seq++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			packet := &rtp.Packet{
+				Header:  rtp.Header{Version: 2, SequenceNumber: seq, Timestamp: uint32(seq) * 960},
+				Payload: []byte{0x10, 0x00, 0x00, 0x00},
+			}
+			if track.Kind() == webrtc.RTPCodecTypeAudio {
+				if id := c.audioLevelID(); id != 0 {
+					ext, _ := rtp.AudioLevelExtension{Level: level, Voice: true}.Marshal()
+					packet.SetExtension(uint8(id), ext)
+				}
+			}
+			track.WriteRTP(packet)
+		}
+	}()
+}
+
+// audioLevelID returns the negotiated ID of the audio level header extension
+func (c *sfuClient) audioLevelID() int {
+	for _, sender := range c.pc.GetSenders() {
+		for _, ext := range sender.GetParameters().HeaderExtensions {
+			if ext.URI == sdp.AudioLevelURI {
+				return ext.ID
+			}
+		}
+	}
+	return 0
+}
+
+// receivedTracks collects the tracks that start arriving until want of them
+// did or timeout passes, then keeps collecting for a second to catch extras
+func (c *sfuClient) receivedTracks(want int, timeout time.Duration) []*webrtc.TrackRemote {
+	var tracks []*webrtc.TrackRemote
+	deadline := time.After(timeout)
+	for len(tracks) < want {
+		select {
+		case track := <-c.tracks:
+			tracks = append(tracks, track)
+		case <-deadline:
+			return tracks
+		}
+	}
+	settle := time.After(time.Second)
+	for {
+		select {
+		case track := <-c.tracks:
+			tracks = append(tracks, track)
+		case <-settle:
+			return tracks
+		}
+	}
+}
+
+func TestWebSocketHandler_SFU(t *testing.T) {
+	router, sfu := setupSFUTestServer(0)
+
+	publisher, tracks := connectSFUClient(t, router, "?roomId=sfu-room&type=group&username=publisher", webrtc.RTPCodecTypeVideo)
+	publisher.publish(tracks[0], 0)
+	subscriber, _ := connectSFUClient(t, router, "?roomId=sfu-room&type=group&username=subscriber")
+
+	received := subscriber.receivedTracks(1, 10*time.Second)
+	if len(received) != 1 || received[0].Kind() != webrtc.RTPCodecTypeVideo || received[0].StreamID() != publisher.id {
+		t.Fatalf("expected the publisher's video through the SFU, got %d tracks", len(received))
+	}
+	if !sfu.HasParticipant(subscriber.id) {
+		t.Error("expected the subscriber to have joined the SFU")
+	}
+
+	subscriber.ws.Close()
+	deadline := time.Now().Add(time.Second)
+	for sfu.HasParticipant(subscriber.id) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected leaving the room to remove the participant from the SFU")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocketHandler_ActiveSpeakers(t *testing.T) {
+	router, _ := setupSFUTestServer(0)
+
+	speaker, tracks := connectSFUClient(t, router, "?roomId=speakers-room&type=group&username=speaker", webrtc.RTPCodecTypeAudio)
+	listener, _ := connectSFUClient(t, router, "?roomId=speakers-room&type=group&username=listener")
+	speaker.publish(tracks[0], 10)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		msg := listener.waitFor("active_speakers", 5*time.Second)
+		speakers := msg.Data.(map[string]interface{})["speakers"].([]interface{})
+		if len(speakers) == 0 {
+			continue
+		}
+		first := speakers[0].(map[string]interface{})
+		if first["participantId"] != speaker.id {
+			t.Fatalf("expected %s as the dominant speaker, got %v", speaker.id, first["participantId"])
+		}
+		if level := first["level"].(float64); level <= 0 {
+			t.Errorf("expected a positive level, got %v", level)
+		}
+		return
+	}
+	t.Fatal("expected active_speakers naming the speaker")
+}
+
+func TestWebSocketHandler_LastN(t *testing.T) {
+	router, _ := setupSFUTestServer(1)
+
+	subscriber, _ := connectSFUClient(t, router, "?roomId=last-n-room&type=group&username=subscriber")
+	first, tracks := connectSFUClient(t, router, "?roomId=last-n-room&type=group&username=first", webrtc.RTPCodecTypeVideo)
+	first.publish(tracks[0], 0)
+	second, tracks := connectSFUClient(t, router, "?roomId=last-n-room&type=group&username=second", webrtc.RTPCodecTypeVideo)
+	second.publish(tracks[0], 0)
+
+	// Before anyone speaks the earliest joiner is shown; the other video is
+	// paused and never starts
+	received := subscriber.receivedTracks(1, 10*time.Second)
+	if len(received) != 1 {
+		t.Fatalf("expected 1 video track with LAST_N=1, got %d", len(received))
+	}
+	if received[0].StreamID() != first.id {
+		t.Errorf("expected the video of %s, got %s", first.id, received[0].StreamID())
+	}
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// MaxBreakoutRooms is the largest number of breakout rooms a room may open at once
+const MaxBreakoutRooms = 50
+
+// BreakoutRoom is a sub-room opened from a parent room
+type BreakoutRoom struct {
+	ID   string `json:"roomId"`
+	Name string `json:"name"`
+}
+
+// BreakoutState describes the breakout rooms of a parent room
+type BreakoutState struct {
+	Rooms       []BreakoutRoom    `json:"rooms"`
+	Assignments map[string]string `json:"assignments"`        // Participant ID -> breakout room ID
+	ClosesAt    int64             `json:"closesAt,omitempty"` // Set while the rooms are counting down to close
+}
+
+// OpenBreakouts links new breakout rooms to the room. Only moderators may
+// open breakouts, and only while none are open.
+func (r *Room) OpenBreakouts(actorID string, rooms []BreakoutRoom) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.canModerate(actorID) {
+		return ErrPermissionDenied
+	}
+	if len(r.breakouts) > 0 {
+		return ErrBreakoutsOpen
+	}
+	if len(rooms) == 0 || len(rooms) > MaxBreakoutRooms {
+		return ErrInvalidBreakout
+	}
+	r.breakouts = append([]BreakoutRoom(nil), rooms...)
+	r.breakoutAssignments = make(map[string]string)
+	r.breakoutClosesAt = time.Time{}
+	return nil
+}
+
+// AssignBreakouts assigns participants to breakout rooms and returns the
+// assignments that were made. Only moderators may assign.
+func (r *Room) AssignBreakouts(actorID string, assignments map[string]string) (map[string]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkBreakoutsOpen(actorID); err != nil {
+		return nil, err
+	}
+	for participantID, roomID := range assignments {
+		if _, ok := r.Participants[participantID]; !ok {
+			return nil, ErrParticipantNotFound
+		}
+		if !r.hasBreakout(roomID) {
+			return nil, ErrInvalidBreakout
+		}
+	}
+
+	made := make(map[string]string, len(assignments))
+	for participantID, roomID := range assignments {
+		r.breakoutAssignments[participantID] = roomID
+		made[participantID] = roomID
+	}
+	return made, nil
+}
+
+// AutoAssignBreakouts spreads the participants that are not moderators
+// evenly over the breakout rooms, in join order, and returns the assignments.
+// Only moderators may assign.
+func (r *Room) AutoAssignBreakouts(actorID string) (map[string]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkBreakoutsOpen(actorID); err != nil {
+		return nil, err
+	}
+
+	participants := make([]*Participant, 0, len(r.Participants))
+	for _, p := range r.Participants {
+		if !p.Role.CanModerate() {
+			participants = append(participants, p)
+		}
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		if !participants[i].JoinedAt.Equal(participants[j].JoinedAt) {
+			return participants[i].JoinedAt.Before(participants[j].JoinedAt)
+		}
+		return participants[i].ID < participants[j].ID
+	})
+
+	made := make(map[string]string, len(participants))
+	for i, p := range participants {
+		roomID := r.breakouts[i%len(r.breakouts)].ID
+		r.breakoutAssignments[p.ID] = roomID
+		made[p.ID] = roomID
+	}
+	return made, nil
+}
+
+// StartClosingBreakouts starts the countdown after which the breakout rooms
+// close. Only moderators may close breakouts.
+func (r *Room) StartClosingBreakouts(actorID string, closesAt time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkBreakoutsOpen(actorID); err != nil {
+		return err
+	}
+	r.breakoutClosesAt = closesAt
+	return nil
+}
+
+// EndBreakouts unlinks the breakout rooms and returns them, if they are still
+// the ones whose countdown ends at closesAt
+func (r *Room) EndBreakouts(closesAt time.Time) []BreakoutRoom {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.breakouts) == 0 || !r.breakoutClosesAt.Equal(closesAt) {
+		return nil
+	}
+	rooms := r.breakouts
+	r.breakouts = nil
+	r.breakoutAssignments = nil
+	r.breakoutClosesAt = time.Time{}
+	return rooms
+}
+
+// GetBreakouts returns the breakout rooms of the room and their assignments
+func (r *Room) GetBreakouts() BreakoutState {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	state := BreakoutState{
+		Rooms:       append([]BreakoutRoom{}, r.breakouts...),
+		Assignments: make(map[string]string, len(r.breakoutAssignments)),
+	}
+	for participantID, roomID := range r.breakoutAssignments {
+		state.Assignments[participantID] = roomID
+	}
+	if !r.breakoutClosesAt.IsZero() {
+		state.ClosesAt = r.breakoutClosesAt.Unix()
+	}
+	return state
+}
+
+// ManagedBreakouts returns the open breakout rooms for a moderator acting on them
+func (r *Room) ManagedBreakouts(actorID string) ([]BreakoutRoom, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if err := r.checkBreakoutsOpen(actorID); err != nil {
+		return nil, err
+	}
+	return append([]BreakoutRoom(nil), r.breakouts...), nil
+}
+
+// checkBreakoutsOpen checks that the actor may manage breakouts and that
+// some are open. Callers must hold the lock.
+func (r *Room) checkBreakoutsOpen(actorID string) error {
+	if !r.canModerate(actorID) {
+		return ErrPermissionDenied
+	}
+	if len(r.breakouts) == 0 {
+		return ErrNoBreakouts
+	}
+	return nil
+}
+
+// hasBreakout reports whether a room is one of the open breakout rooms.
+// Callers must hold the lock.
+func (r *Room) hasBreakout(roomID string) bool {
+	for _, b := range r.breakouts {
+		if b.ID == roomID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakoutAssignment(t *testing.T) {
+	room := NewRoom("main", ScreenSharing)
+	base := time.Now()
+	for i, id := range []string{"host", "p1", "p2", "p3"} {
+		room.AddParticipant(&Participant{ID: id, JoinedAt: base.Add(time.Duration(i) * time.Second), ConnectionInfo: &ConnectionInfo{Type: ScreenSharing}})
+	}
+	rooms := []BreakoutRoom{{ID: "main-breakout-1"}, {ID: "main-breakout-2"}}
+
+	if _, err := room.AutoAssignBreakouts("host"); err != ErrNoBreakouts {
+		t.Errorf("Expected %v, got %v", ErrNoBreakouts, err)
+	}
+	if err := room.OpenBreakouts("p1", rooms); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	if err := room.OpenBreakouts("host", rooms); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := room.OpenBreakouts("host", rooms); err != ErrBreakoutsOpen {
+		t.Errorf("Expected %v, got %v", ErrBreakoutsOpen, err)
+	}
+
+	assignments, err := room.AutoAssignBreakouts("host")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(assignments) != 3 || assignments["p1"] != "main-breakout-1" || assignments["p2"] != "main-breakout-2" || assignments["p3"] != "main-breakout-1" {
+		t.Errorf("Expected participants spread round-robin, got %v", assignments)
+	}
+
+	if _, err := room.AssignBreakouts("host", map[string]string{"p3": "elsewhere"}); err != ErrInvalidBreakout {
+		t.Errorf("Expected %v, got %v", ErrInvalidBreakout, err)
+	}
+	room.AssignBreakouts("host", map[string]string{"p3": "main-breakout-2"})
+	if state := room.GetBreakouts(); state.Assignments["p3"] != "main-breakout-2" {
+		t.Errorf("Expected manual reassignment, got %v", state.Assignments)
+	}
+}
+
+func TestCloseBreakouts(t *testing.T) {
+	room := newChatRoom(t)
+	room.OpenBreakouts("host", []BreakoutRoom{{ID: "b1"}})
+
+	closesAt := time.Now().Add(time.Minute)
+	if err := room.StartClosingBreakouts("host", closesAt); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if state := room.GetBreakouts(); state.ClosesAt != closesAt.Unix() {
+		t.Errorf("Expected a countdown, got %+v", state)
+	}
+	if ended := room.EndBreakouts(closesAt.Add(time.Second)); ended != nil {
+		t.Errorf("Expected a stale countdown to be ignored, got %v", ended)
+	}
+	if ended := room.EndBreakouts(closesAt); len(ended) != 1 {
+		t.Errorf("Expected the breakouts to end, got %v", ended)
+	}
+	if state := room.GetBreakouts(); len(state.Rooms) != 0 {
+		t.Errorf("Expected no breakouts after closing, got %+v", state)
+	}
+}
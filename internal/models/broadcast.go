@@ -0,0 +1,133 @@
+package models
+
+import "time"
+
+// BroadcastStatus describes the state of the broadcast in a broadcasting room
+type BroadcastStatus string
+
+const (
+	// BroadcastIdle means no broadcaster has joined yet
+	BroadcastIdle BroadcastStatus = "idle"
+	// BroadcastLive means a broadcaster is publishing
+	BroadcastLive BroadcastStatus = "live"
+	// BroadcastPaused means the broadcaster left and may still reconnect
+	BroadcastPaused BroadcastStatus = "paused"
+	// BroadcastEnded means the broadcaster did not return in time
+	BroadcastEnded BroadcastStatus = "ended"
+)
+
+// GetBroadcastStatus returns the current broadcast status
+func (r *Room) GetBroadcastStatus() BroadcastStatus {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.BroadcastStatus
+}
+
+// GetBroadcasterID returns the ID of the current broadcaster, or "" if there is none
+func (r *Room) GetBroadcasterID() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.Broadcaster == nil {
+		return ""
+	}
+	return r.Broadcaster.ID
+}
+
+// GetPausedAt returns when the broadcast was paused; it is zero unless the broadcast is paused
+func (r *Room) GetPausedAt() time.Time {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.pausedAt
+}
+
+// TransferBroadcaster hands the broadcaster role to another participant.
+// Only the current broadcaster may transfer; it returns to the audience.
+func (r *Room) TransferBroadcaster(actorID, targetID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.Broadcaster == nil || r.Broadcaster.ID != actorID {
+		return ErrPermissionDenied
+	}
+	target, ok := r.Participants[targetID]
+	if !ok || targetID == actorID {
+		return ErrParticipantNotFound
+	}
+
+	actor := r.Broadcaster
+	actor.Role = RoleViewer
+	actor.Media = MediaState{}
+	actor.ConnectionInfo.IsBroadcaster = false
+	actor.ConnectionInfo.IsScreenShare = false
+
+	r.makeBroadcaster(target)
+	return nil
+}
+
+// EndPausedBroadcast ends a broadcast that has stayed paused since pausedAt.
+// It reports false if the broadcaster came back or the broadcast was paused again later.
+func (r *Room) EndPausedBroadcast(pausedAt time.Time) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.BroadcastStatus != BroadcastPaused || !r.pausedAt.Equal(pausedAt) {
+		return false
+	}
+	r.BroadcastStatus = BroadcastEnded
+	r.pausedAt = time.Time{}
+	r.pausedToken = ""
+	return true
+}
+
+// checkBroadcasterJoin returns why a participant may not join as the
+// broadcaster, if anything stops them: someone else broadcasts, the broadcast
+// is paused for another broadcaster to reconnect, or it has ended.
+// Callers must hold the lock.
+func (r *Room) checkBroadcasterJoin(p *Participant) error {
+	switch {
+	case r.Broadcaster != nil:
+		return ErrBroadcasterExists
+	case r.BroadcastStatus == BroadcastPaused && (p.Token == "" || p.Token != r.pausedToken):
+		return ErrBroadcasterExists
+	case r.BroadcastStatus == BroadcastEnded:
+		return ErrBroadcastEnded
+	}
+	return nil
+}
+
+// makeBroadcaster gives a participant the broadcaster role. Callers must hold the lock.
+func (r *Room) makeBroadcaster(p *Participant) {
+	r.lowerHand(p.ID)
+	p.Role = RoleBroadcaster
+	p.ConnectionInfo.IsBroadcaster = true
+	r.Broadcaster = p
+	r.BroadcastStatus = BroadcastLive
+	r.pausedAt = time.Time{}
+	r.pausedToken = ""
+}
+
+// handleBroadcasterLeft hands the broadcast to the longest-present stage
+// participant, or pauses it if there is none. Only the broadcaster who left
+// may resume a paused broadcast. Callers must hold the lock.
+func (r *Room) handleBroadcasterLeft() {
+	former := r.Broadcaster
+	r.Broadcaster = nil
+
+	var next *Participant
+	for _, p := range r.Participants {
+		if p.Role != RoleStage {
+			continue
+		}
+		if next == nil || p.JoinedAt.Before(next.JoinedAt) {
+			next = p
+		}
+	}
+	if next != nil {
+		r.makeBroadcaster(next)
+		return
+	}
+
+	r.BroadcastStatus = BroadcastPaused
+	r.pausedAt = time.Now()
+	r.pausedToken = former.Token
+}
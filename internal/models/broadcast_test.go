@@ -0,0 +1,86 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestBroadcasterHandoverToStage(t *testing.T) {
+	room := newBroadcastRoom(t, 2, "v1", "v2")
+	if room.GetBroadcastStatus() != BroadcastLive {
+		t.Fatalf("Expected live broadcast, got %s", room.GetBroadcastStatus())
+	}
+
+	room.PromoteToStage("broadcaster", "v2")
+	room.RemoveParticipant("broadcaster")
+
+	if room.GetBroadcasterID() != "v2" {
+		t.Errorf("Expected stage participant to take over, got %q", room.GetBroadcasterID())
+	}
+	if room.GetParticipant("v2").Role != RoleBroadcaster || room.GetBroadcastStatus() != BroadcastLive {
+		t.Error("Expected broadcast to stay live under the new broadcaster")
+	}
+}
+
+func TestBroadcastPauseResumeAndEnd(t *testing.T) {
+	room := newBroadcastRoom(t, 1, "v1")
+	room.RemoveParticipant("broadcaster")
+
+	if room.GetBroadcastStatus() != BroadcastPaused {
+		t.Fatalf("Expected paused broadcast, got %s", room.GetBroadcastStatus())
+	}
+	pausedAt := room.GetPausedAt()
+
+	// Only the broadcaster who left may take the broadcast back
+	err := room.AddParticipant(&Participant{
+		ID:             "stranger",
+		ConnectionInfo: &ConnectionInfo{Type: Broadcasting, IsBroadcaster: true},
+	})
+	if err != ErrBroadcasterExists {
+		t.Errorf("Expected %v, got %v", ErrBroadcasterExists, err)
+	}
+	err = room.AddParticipant(&Participant{
+		ID:             "broadcaster-2",
+		Token:          "broadcaster-token",
+		ConnectionInfo: &ConnectionInfo{Type: Broadcasting, IsBroadcaster: true},
+	})
+	if err != nil {
+		t.Fatalf("Expected broadcaster to rejoin, got %v", err)
+	}
+	if room.GetBroadcastStatus() != BroadcastLive {
+		t.Errorf("Expected resumed broadcast, got %s", room.GetBroadcastStatus())
+	}
+	if room.EndPausedBroadcast(pausedAt) {
+		t.Error("Expected a resumed broadcast not to end")
+	}
+
+	room.RemoveParticipant("broadcaster-2")
+	if !room.EndPausedBroadcast(room.GetPausedAt()) {
+		t.Fatal("Expected paused broadcast to end")
+	}
+	if room.GetBroadcastStatus() != BroadcastEnded {
+		t.Errorf("Expected ended broadcast, got %s", room.GetBroadcastStatus())
+	}
+
+	err = room.AddParticipant(&Participant{
+		ID:             "broadcaster-3",
+		Token:          "broadcaster-token",
+		ConnectionInfo: &ConnectionInfo{Type: Broadcasting, IsBroadcaster: true},
+	})
+	if err != ErrBroadcastEnded || room.GetBroadcastStatus() != BroadcastEnded {
+		t.Errorf("Expected an ended broadcast to stay ended, got %v, %s", err, room.GetBroadcastStatus())
+	}
+}
+
+func TestTransferBroadcaster(t *testing.T) {
+	room := newBroadcastRoom(t, 1, "v1")
+
+	if err := room.TransferBroadcaster("v1", "broadcaster"); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	if err := room.TransferBroadcaster("broadcaster", "v1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if room.GetBroadcasterID() != "v1" || room.GetParticipant("broadcaster").Role != RoleViewer {
+		t.Error("Expected roles to be swapped")
+	}
+}
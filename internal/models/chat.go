@@ -0,0 +1,453 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatMessage represents a chat message in the room
+type ChatMessage struct {
+	ID         string              `json:"id"`
+	Seq        int64               `json:"seq"` // Position in the room's chat, used as pagination cursor
+	SenderID   string              `json:"sender_id"`
+	SenderName string              `json:"sender_name"`
+	Content    string              `json:"content"`
+	Timestamp  int64               `json:"timestamp"`
+	EditedAt   int64               `json:"edited_at,omitempty"`
+	EditCount  int                 `json:"edit_count,omitempty"`
+	Deleted    bool                `json:"deleted,omitempty"`
+	Edits      []ChatEdit          `json:"-"`                    // Previous versions, shown only to moderators
+	Reactions  map[string][]string `json:"reactions,omitempty"`  // emoji -> IDs of participants who reacted
+	Recipients []string            `json:"recipients,omitempty"` // Set only on direct messages
+	Attachment *Attachment         `json:"attachment,omitempty"`
+	ReplyTo    string              `json:"reply_to,omitempty"` // ID of the message this one replies to
+	Mentions   []string            `json:"mentions,omitempty"` // IDs of participants mentioned with @name
+}
+
+// Attachment describes a file shared in a chat message
+type Attachment struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// IsDirect reports whether the message is a direct message
+func (m ChatMessage) IsDirect() bool {
+	return len(m.Recipients) > 0
+}
+
+// involves reports whether a participant sent or received the message
+func (m ChatMessage) involves(participantID string) bool {
+	if m.SenderID == participantID {
+		return true
+	}
+	for _, id := range m.Recipients {
+		if id == participantID {
+			return true
+		}
+	}
+	return false
+}
+
+// ChatEdit is a previous version of an edited chat message
+type ChatEdit struct {
+	Content  string `json:"content"`
+	EditedAt int64  `json:"edited_at"`
+}
+
+// copy returns a deep copy of the message so callers can't mutate room state
+func (m ChatMessage) copy() ChatMessage {
+	if m.Edits != nil {
+		m.Edits = append([]ChatEdit(nil), m.Edits...)
+	}
+	if m.Recipients != nil {
+		m.Recipients = append([]string(nil), m.Recipients...)
+	}
+	if m.Mentions != nil {
+		m.Mentions = append([]string(nil), m.Mentions...)
+	}
+	if m.Attachment != nil {
+		attachment := *m.Attachment
+		m.Attachment = &attachment
+	}
+	if m.Reactions != nil {
+		reactions := make(map[string][]string, len(m.Reactions))
+		for emoji, ids := range m.Reactions {
+			reactions[emoji] = append([]string(nil), ids...)
+		}
+		m.Reactions = reactions
+	}
+	return m
+}
+
+// AddChatMessage adds a new chat message to the room history and returns it
+// with its server-assigned ID and the participants it @mentions. A reply must
+// refer to a message in the retained history.
+func (r *Room) AddChatMessage(message ChatMessage) (ChatMessage, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if message.ReplyTo != "" && r.chat.find(message.ReplyTo) == nil {
+		return ChatMessage{}, ErrMessageNotFound
+	}
+	if message.ID == "" {
+		message.ID = uuid.New().String()
+	}
+	message.Mentions = r.findMentions(message.SenderID, message.Content)
+	r.chatSeq++
+	message.Seq = r.chatSeq
+	r.addAttachment(message)
+	r.chat.push(message)
+	return message.copy(), nil
+}
+
+// LoadChatMessages restores previously stored public and direct messages,
+// ordered by sequence number, into an empty room
+func (r *Room) LoadChatMessages(messages []ChatMessage) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, m := range messages {
+		if m.IsDirect() {
+			r.pushDirectMessage(m)
+		} else {
+			r.chat.push(m)
+		}
+		if m.Seq > r.chatSeq {
+			r.chatSeq = m.Seq
+		}
+		r.addAttachment(m)
+	}
+}
+
+// GetChatHistory returns the retained chat history
+func (r *Room) GetChatHistory() []ChatMessage {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	history := make([]ChatMessage, r.chat.len())
+	for i := range history {
+		history[i] = r.chat.at(i).copy()
+	}
+	return history
+}
+
+// GetChatPage returns up to limit messages older than the before cursor, oldest
+// first. A zero before returns the most recent messages, and a limit of zero
+// or less returns none. The returned cursor fetches the preceding page and is
+// zero when there are no older messages.
+func (r *Room) GetChatPage(before int64, limit int) ([]ChatMessage, int64) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	end := r.chat.len()
+	if before > 0 {
+		end = sort.Search(r.chat.len(), func(i int) bool {
+			return r.chat.at(i).Seq >= before
+		})
+	}
+	start := end - limit
+	if limit <= 0 {
+		start = end
+	} else if start < 0 {
+		start = 0
+	}
+
+	page := make([]ChatMessage, 0, end-start)
+	for i := start; i < end; i++ {
+		page = append(page, r.chat.at(i).copy())
+	}
+
+	var cursor int64
+	switch {
+	case start == 0:
+	case start < r.chat.len():
+		cursor = r.chat.at(start).Seq
+	default:
+		cursor = r.chat.at(start-1).Seq + 1
+	}
+	return page, cursor
+}
+
+// AddDirectMessage stores a direct message from its sender to the given
+// recipients, apart from the public chat history, and returns it with its ID
+func (r *Room) AddDirectMessage(message ChatMessage, recipients []string) (ChatMessage, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	seen := map[string]bool{message.SenderID: true}
+	message.Recipients = make([]string, 0, len(recipients))
+	for _, id := range recipients {
+		if seen[id] {
+			continue
+		}
+		if _, ok := r.Participants[id]; !ok {
+			return ChatMessage{}, ErrParticipantNotFound
+		}
+		seen[id] = true
+		message.Recipients = append(message.Recipients, id)
+	}
+	if len(message.Recipients) == 0 {
+		return ChatMessage{}, ErrParticipantNotFound
+	}
+
+	if message.ID == "" {
+		message.ID = uuid.New().String()
+	}
+	r.chatSeq++
+	message.Seq = r.chatSeq
+	r.addAttachment(message)
+	r.pushDirectMessage(message)
+	return message.copy(), nil
+}
+
+// pushDirectMessage retains a direct message in the ring of its conversation,
+// so that one busy conversation can't push out the others. Callers must hold the lock.
+func (r *Room) pushDirectMessage(message ChatMessage) {
+	key := conversationKey(message)
+	ring, ok := r.directMessages[key]
+	if !ok {
+		ring = &chatRing{size: r.Settings.ChatHistorySize}
+		r.directMessages[key] = ring
+	}
+	ring.push(message)
+}
+
+// conversationKey identifies the conversation of a direct message by the
+// sorted IDs of everyone in it
+func conversationKey(message ChatMessage) string {
+	ids := append([]string{message.SenderID}, message.Recipients...)
+	sort.Strings(ids)
+	return strings.Join(ids, "\x00")
+}
+
+// GetDirectMessages returns the direct messages a participant sent or received, oldest first
+func (r *Room) GetDirectMessages(participantID string) []ChatMessage {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	messages := make([]ChatMessage, 0)
+	for _, ring := range r.directMessages {
+		if ring.len() == 0 || !ring.at(0).involves(participantID) {
+			continue
+		}
+		for i := 0; i < ring.len(); i++ {
+			messages = append(messages, ring.at(i).copy())
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Seq < messages[j].Seq })
+	return messages
+}
+
+// EditChatMessage replaces the content of a message, keeping the previous
+// version in its edit history. Only the author may edit.
+func (r *Room) EditChatMessage(actorID, messageID, content string) (ChatMessage, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	m := r.findChatMessage(actorID, messageID)
+	if m == nil {
+		return ChatMessage{}, ErrMessageNotFound
+	}
+	if m.SenderID != actorID {
+		return ChatMessage{}, ErrPermissionDenied
+	}
+
+	now := time.Now().Unix()
+	m.Edits = append(m.Edits, ChatEdit{Content: m.Content, EditedAt: now})
+	m.EditCount++
+	m.Content = content
+	m.EditedAt = now
+	return m.copy(), nil
+}
+
+// GetChatEdits returns the previous versions of a message, oldest first.
+// Only moderators may see them.
+func (r *Room) GetChatEdits(actorID, messageID string) ([]ChatEdit, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if !r.canModerate(actorID) {
+		return nil, ErrPermissionDenied
+	}
+	m := r.findChatMessage(actorID, messageID)
+	if m == nil {
+		return nil, ErrMessageNotFound
+	}
+	return append([]ChatEdit{}, m.Edits...), nil
+}
+
+// DeleteChatMessage removes the content of a message but keeps a tombstone so
+// that references to it stay valid. The author and moderators may delete.
+func (r *Room) DeleteChatMessage(actorID, messageID string) (ChatMessage, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	m := r.findChatMessage(actorID, messageID)
+	if m == nil {
+		return ChatMessage{}, ErrMessageNotFound
+	}
+	if m.SenderID != actorID && !r.canModerate(actorID) {
+		return ChatMessage{}, ErrPermissionDenied
+	}
+
+	r.unpin(m.ID)
+	m.Deleted = true
+	m.Content = ""
+	m.Edits = nil
+	m.Reactions = nil
+	if m.Attachment != nil {
+		delete(r.attachments, m.Attachment.ID)
+		m.Attachment = nil
+	}
+	return m.copy(), nil
+}
+
+// ReactToChatMessage toggles a participant's emoji reaction on a message
+func (r *Room) ReactToChatMessage(actorID, messageID, emoji string) (ChatMessage, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.Participants[actorID]; !ok {
+		return ChatMessage{}, ErrParticipantNotFound
+	}
+	m := r.findChatMessage(actorID, messageID)
+	if m == nil {
+		return ChatMessage{}, ErrMessageNotFound
+	}
+
+	if m.Reactions == nil {
+		m.Reactions = make(map[string][]string)
+	}
+	reactors := m.Reactions[emoji]
+	for i, id := range reactors {
+		if id == actorID {
+			reactors = append(reactors[:i], reactors[i+1:]...)
+			if len(reactors) == 0 {
+				delete(m.Reactions, emoji)
+			} else {
+				m.Reactions[emoji] = reactors
+			}
+			return m.copy(), nil
+		}
+	}
+	m.Reactions[emoji] = append(reactors, actorID)
+	return m.copy(), nil
+}
+
+// GetAttachment returns a file shared in the room's chat
+func (r *Room) GetAttachment(fileID string) (Attachment, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	attachment, ok := r.attachments[fileID]
+	return attachment, ok
+}
+
+// ClearAttachments forgets every shared file, after their contents were deleted
+func (r *Room) ClearAttachments() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.attachments = make(map[string]Attachment)
+}
+
+// addAttachment indexes the attachment of a message. Callers must hold the lock.
+func (r *Room) addAttachment(message ChatMessage) {
+	if message.Attachment != nil && !message.Deleted {
+		r.attachments[message.Attachment.ID] = *message.Attachment
+	}
+}
+
+// findChatMessage returns the live, non-deleted message with the given ID
+// that is visible to the actor. Callers must hold the lock.
+func (r *Room) findChatMessage(actorID, messageID string) *ChatMessage {
+	if m := r.chat.find(messageID); m != nil {
+		return m
+	}
+	for _, ring := range r.directMessages {
+		if m := ring.find(messageID); m != nil {
+			if m.involves(actorID) {
+				return m
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// mentionPattern matches @name mentions in chat content
+var mentionPattern = regexp.MustCompile(`@([\p{L}\p{N}_.-]+)`)
+
+// findMentions returns the IDs of participants mentioned in content by
+// display name or ID, leaving out the sender. Callers must hold the lock.
+func (r *Room) findMentions(senderID, content string) []string {
+	var mentions []string
+	seen := map[string]bool{senderID: true}
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := match[1]
+		for _, p := range r.Participants {
+			if seen[p.ID] || (p.ID != name && !strings.EqualFold(p.Username, name)) {
+				continue
+			}
+			seen[p.ID] = true
+			mentions = append(mentions, p.ID)
+		}
+	}
+	return mentions
+}
+
+// canModerate reports whether a participant may moderate the room. Callers must hold the lock.
+func (r *Room) canModerate(participantID string) bool {
+	p, ok := r.Participants[participantID]
+	return ok && p.Role.CanModerate()
+}
+
+// chatRing holds the retained public messages or those of one direct
+// conversation, oldest first.
+// Once it holds size messages, each new message overwrites the oldest in place.
+type chatRing struct {
+	messages []ChatMessage
+	head     int // Index of the oldest message
+	size     int // Most messages kept; zero or less keeps every message
+}
+
+// len returns the number of retained messages
+func (c *chatRing) len() int {
+	return len(c.messages)
+}
+
+// at returns the i-th oldest retained message
+func (c *chatRing) at(i int) *ChatMessage {
+	return &c.messages[(c.head+i)%len(c.messages)]
+}
+
+// push adds a message, dropping the oldest one when the ring is full
+func (c *chatRing) push(message ChatMessage) {
+	if c.size > 0 && len(c.messages) >= c.size {
+		c.messages[c.head] = message
+		c.head = (c.head + 1) % len(c.messages)
+		return
+	}
+	c.messages = append(c.messages, message)
+}
+
+// index returns the position of the message with the given ID, deleted or
+// not, or -1 if it is no longer retained
+func (c *chatRing) index(messageID string) int {
+	for i := c.len() - 1; i >= 0; i-- {
+		if c.at(i).ID == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+// find returns the live, non-deleted message with the given ID
+func (c *chatRing) find(messageID string) *ChatMessage {
+	if i := c.index(messageID); i >= 0 && !c.at(i).Deleted {
+		return c.at(i)
+	}
+	return nil
+}
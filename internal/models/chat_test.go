@@ -0,0 +1,254 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func newChatRoom(t *testing.T) *Room {
+	t.Helper()
+	room := NewRoom("test-room", OneToOne)
+	room.AddParticipant(&Participant{ID: "host", ConnectionInfo: &ConnectionInfo{Type: OneToOne}})
+	room.AddParticipant(&Participant{ID: "guest", ConnectionInfo: &ConnectionInfo{Type: OneToOne}})
+	return room
+}
+
+func TestChatMessageIDs(t *testing.T) {
+	room := newChatRoom(t)
+
+	first, _ := room.AddChatMessage(ChatMessage{SenderID: "guest", Content: "one"})
+	second, _ := room.AddChatMessage(ChatMessage{SenderID: "guest", Content: "two"})
+	if first.ID == "" || first.ID == second.ID {
+		t.Errorf("Expected unique IDs, got %q and %q", first.ID, second.ID)
+	}
+}
+
+func TestEditChatMessage(t *testing.T) {
+	room := newChatRoom(t)
+	msg, _ := room.AddChatMessage(ChatMessage{SenderID: "guest", Content: "helo"})
+
+	if _, err := room.EditChatMessage("host", msg.ID, "hijacked"); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+
+	edited, err := room.EditChatMessage("guest", msg.ID, "hello")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if edited.Content != "hello" || edited.EditCount != 1 || len(edited.Edits) != 1 || edited.Edits[0].Content != "helo" {
+		t.Errorf("Unexpected edited message %+v", edited)
+	}
+	if data, _ := json.Marshal(edited); strings.Contains(string(data), "helo") {
+		t.Errorf("Expected previous versions to be left out of the message, got %s", data)
+	}
+
+	if _, err := room.GetChatEdits("guest", msg.ID); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	if edits, err := room.GetChatEdits("host", msg.ID); err != nil || len(edits) != 1 || edits[0].Content != "helo" {
+		t.Errorf("Expected moderators to see the previous version, got %+v (%v)", edits, err)
+	}
+
+	if _, err := room.EditChatMessage("guest", "missing", "x"); err != ErrMessageNotFound {
+		t.Errorf("Expected %v, got %v", ErrMessageNotFound, err)
+	}
+}
+
+func TestDeleteChatMessage(t *testing.T) {
+	room := newChatRoom(t)
+	own, _ := room.AddChatMessage(ChatMessage{SenderID: "host", Content: "mine"})
+	other, _ := room.AddChatMessage(ChatMessage{SenderID: "guest", Content: "spam"})
+
+	if _, err := room.DeleteChatMessage("guest", own.ID); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+
+	deleted, err := room.DeleteChatMessage("host", other.ID)
+	if err != nil {
+		t.Fatalf("Expected moderator to delete, got %v", err)
+	}
+	if !deleted.Deleted || deleted.Content != "" {
+		t.Errorf("Expected tombstone, got %+v", deleted)
+	}
+	if _, err := room.EditChatMessage("guest", other.ID, "again"); err != ErrMessageNotFound {
+		t.Errorf("Expected deleted message to be immutable, got %v", err)
+	}
+	if history := room.GetChatHistory(); len(history) != 2 || !history[1].Deleted {
+		t.Errorf("Expected tombstone to stay in history, got %+v", history)
+	}
+}
+
+func TestReactToChatMessage(t *testing.T) {
+	room := newChatRoom(t)
+	msg, _ := room.AddChatMessage(ChatMessage{SenderID: "guest", Content: "hi"})
+
+	room.ReactToChatMessage("host", msg.ID, "👍")
+	reacted, _ := room.ReactToChatMessage("guest", msg.ID, "👍")
+	if len(reacted.Reactions["👍"]) != 2 {
+		t.Errorf("Expected 2 reactions, got %v", reacted.Reactions)
+	}
+
+	reacted.Reactions["👍"][0] = "tampered"
+	if room.GetChatHistory()[0].Reactions["👍"][0] == "tampered" {
+		t.Error("Expected returned message to be a copy")
+	}
+
+	toggled, _ := room.ReactToChatMessage("host", msg.ID, "👍")
+	if len(toggled.Reactions["👍"]) != 1 {
+		t.Errorf("Expected reaction to be toggled off, got %v", toggled.Reactions)
+	}
+
+	if _, err := room.ReactToChatMessage("stranger", msg.ID, "👍"); err != ErrParticipantNotFound {
+		t.Errorf("Expected %v, got %v", ErrParticipantNotFound, err)
+	}
+}
+
+func TestDirectMessages(t *testing.T) {
+	room := newChatRoom(t)
+	room.Type = ScreenSharing
+	room.AddParticipant(&Participant{ID: "other", ConnectionInfo: &ConnectionInfo{Type: ScreenSharing}})
+
+	if _, err := room.AddDirectMessage(ChatMessage{SenderID: "guest", Content: "psst"}, []string{"missing"}); err != ErrParticipantNotFound {
+		t.Errorf("Expected %v, got %v", ErrParticipantNotFound, err)
+	}
+	if _, err := room.AddDirectMessage(ChatMessage{SenderID: "guest", Content: "psst"}, []string{"guest"}); err != ErrParticipantNotFound {
+		t.Errorf("Expected messaging only yourself to fail, got %v", err)
+	}
+
+	dm, err := room.AddDirectMessage(ChatMessage{SenderID: "guest", Content: "psst"}, []string{"host", "host"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if dm.ID == "" || len(dm.Recipients) != 1 {
+		t.Errorf("Unexpected direct message %+v", dm)
+	}
+
+	if len(room.GetChatHistory()) != 0 {
+		t.Error("Expected direct messages to stay out of the public history")
+	}
+	if len(room.GetDirectMessages("guest")) != 1 || len(room.GetDirectMessages("host")) != 1 {
+		t.Error("Expected sender and recipient to see the direct message")
+	}
+	if len(room.GetDirectMessages("other")) != 0 {
+		t.Error("Expected other participants not to see the direct message")
+	}
+
+	if _, err := room.DeleteChatMessage("other", dm.ID); err != ErrMessageNotFound {
+		t.Errorf("Expected outsiders not to find the message, got %v", err)
+	}
+	if _, err := room.ReactToChatMessage("host", dm.ID, "👍"); err != nil {
+		t.Errorf("Expected recipient to react, got %v", err)
+	}
+}
+
+func TestChatHistoryBound(t *testing.T) {
+	settings := DefaultRoomSettings()
+	settings.ChatHistorySize = 3
+	room := NewRoomWithSettings("test-room", OneToOne, settings)
+
+	for _, content := range []string{"1", "2", "3", "4", "5"} {
+		room.AddChatMessage(ChatMessage{SenderID: "a", Content: content})
+	}
+
+	history := room.GetChatHistory()
+	if len(history) != 3 || history[0].Content != "3" || history[2].Content != "5" {
+		t.Errorf("Expected the last 3 messages, got %+v", history)
+	}
+
+	// Paging works across the wrap-around point of the ring
+	page, cursor := room.GetChatPage(0, 2)
+	if len(page) != 2 || page[0].Content != "4" || page[1].Content != "5" {
+		t.Fatalf("Expected messages 4-5, got %+v", page)
+	}
+	if page, cursor = room.GetChatPage(cursor, 2); len(page) != 1 || page[0].Content != "3" || cursor != 0 {
+		t.Errorf("Expected message 3 and no older page, got %+v (cursor %d)", page, cursor)
+	}
+
+	// Direct messages are bounded the same way
+	room.AddParticipant(&Participant{ID: "a", ConnectionInfo: &ConnectionInfo{Type: OneToOne}})
+	room.AddParticipant(&Participant{ID: "b", ConnectionInfo: &ConnectionInfo{Type: OneToOne}})
+	for _, content := range []string{"1", "2", "3", "4", "5"} {
+		room.AddDirectMessage(ChatMessage{SenderID: "a", Content: content}, []string{"b"})
+	}
+	dms := room.GetDirectMessages("b")
+	if len(dms) != 3 || dms[0].Content != "3" || dms[2].Content != "5" {
+		t.Errorf("Expected the last 3 direct messages, got %+v", dms)
+	}
+}
+
+func TestDirectMessagesBoundPerConversation(t *testing.T) {
+	settings := DefaultRoomSettings()
+	settings.ChatHistorySize = 2
+	room := NewRoomWithSettings("test-room", Group, settings)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		room.AddParticipant(&Participant{ID: id, ConnectionInfo: &ConnectionInfo{Type: Group}})
+	}
+
+	quiet, _ := room.AddDirectMessage(ChatMessage{SenderID: "c", Content: "hi"}, []string{"d"})
+	for _, content := range []string{"1", "2", "3", "4", "5"} {
+		room.AddDirectMessage(ChatMessage{SenderID: "a", Content: content}, []string{"b"})
+	}
+
+	if dms := room.GetDirectMessages("d"); len(dms) != 1 || dms[0].ID != quiet.ID {
+		t.Errorf("Expected another conversation not to evict the message, got %+v", dms)
+	}
+	if dms := room.GetDirectMessages("a"); len(dms) != 2 || dms[0].Content != "4" {
+		t.Errorf("Expected the last 2 messages of the busy conversation, got %+v", dms)
+	}
+
+	// A participant in several conversations sees them all, oldest first
+	room.AddDirectMessage(ChatMessage{SenderID: "d", Content: "and you?"}, []string{"a"})
+	if dms := room.GetDirectMessages("a"); len(dms) != 3 || dms[2].Content != "and you?" {
+		t.Errorf("Expected messages of both conversations in order, got %+v", dms)
+	}
+}
+
+func TestGetChatPage(t *testing.T) {
+	room := NewRoom("test-room", OneToOne)
+	for _, content := range []string{"1", "2", "3", "4", "5"} {
+		room.AddChatMessage(ChatMessage{SenderID: "a", Content: content})
+	}
+
+	page, cursor := room.GetChatPage(0, 2)
+	if len(page) != 2 || page[0].Content != "4" || page[1].Content != "5" {
+		t.Fatalf("Expected latest two messages, got %+v", page)
+	}
+
+	page, cursor = room.GetChatPage(cursor, 2)
+	if len(page) != 2 || page[0].Content != "2" || page[1].Content != "3" {
+		t.Fatalf("Expected messages 2-3, got %+v", page)
+	}
+
+	page, cursor = room.GetChatPage(cursor, 2)
+	if len(page) != 1 || page[0].Content != "1" || cursor != 0 {
+		t.Errorf("Expected last page with message 1, got %+v (cursor %d)", page, cursor)
+	}
+	// A zero limit returns nothing, with a cursor for the whole history
+	page, cursor = room.GetChatPage(0, 0)
+	if len(page) != 0 || cursor == 0 {
+		t.Fatalf("Expected an empty page with a cursor, got %+v (cursor %d)", page, cursor)
+	}
+	if page, _ = room.GetChatPage(cursor, 5); len(page) != 5 {
+		t.Errorf("Expected the cursor to reach every message, got %+v", page)
+	}
+}
+
+func TestChatRepliesAndMentions(t *testing.T) {
+	room := NewRoom("test-room", Broadcasting)
+	room.AddParticipant(&Participant{ID: "b", Username: "Alice", ConnectionInfo: &ConnectionInfo{Type: Broadcasting, IsBroadcaster: true}})
+	room.AddParticipant(&Participant{ID: "v", Username: "bob", ConnectionInfo: &ConnectionInfo{Type: Broadcasting}})
+
+	question, _ := room.AddChatMessage(ChatMessage{SenderID: "v", Content: "@alice @Bob @nobody any slides?"})
+	if len(question.Mentions) != 1 || question.Mentions[0] != "b" {
+		t.Errorf("Expected only Alice to be mentioned, got %v", question.Mentions)
+	}
+
+	answer, err := room.AddChatMessage(ChatMessage{SenderID: "b", Content: "yes", ReplyTo: question.ID})
+	if err != nil || answer.ReplyTo != question.ID {
+		t.Errorf("Expected a reply, got %+v, %v", answer, err)
+	}
+	if _, err := room.AddChatMessage(ChatMessage{SenderID: "b", ReplyTo: "missing"}); err != ErrMessageNotFound {
+		t.Errorf("Expected %v, got %v", ErrMessageNotFound, err)
+	}
+}
@@ -10,6 +10,8 @@ const (
 	Broadcasting ConnectionType = "broadcasting"
 	// ScreenSharing represents a screen sharing connection
 	ScreenSharing ConnectionType = "screen_sharing"
+	// Group represents a mesh where every pair of participants connects directly
+	Group ConnectionType = "group"
 )
 
 // ConnectionInfo stores information about the connection
@@ -7,6 +7,52 @@ var (
 	ErrRoomFull = errors.New("room is full")
 	// ErrBroadcasterExists is returned when trying to set a broadcaster in a room that already has one
 	ErrBroadcasterExists = errors.New("broadcaster already exists in this room")
+	// ErrBroadcastEnded is returned when trying to broadcast in a room whose broadcast has ended
+	ErrBroadcastEnded = errors.New("broadcast has ended")
 	// ErrInvalidConnectionType is returned when an invalid connection type is provided
 	ErrInvalidConnectionType = errors.New("invalid connection type")
+	// ErrParticipantNotFound is returned when a participant is not in the room
+	ErrParticipantNotFound = errors.New("participant not found")
+	// ErrAmbiguousParticipant is returned when a display name matches several participants
+	ErrAmbiguousParticipant = errors.New("several participants have this name, use the participant ID")
+	// ErrPermissionDenied is returned when a participant's role does not allow an action
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrInvalidRole is returned when the target of an action has the wrong role
+	ErrInvalidRole = errors.New("invalid role for this action")
+	// ErrStageFull is returned when all stage slots are taken
+	ErrStageFull = errors.New("stage is full")
+	// ErrNotPresenter is returned when someone other than the floor holder tries to share a screen
+	ErrNotPresenter = errors.New("only the presenter can share a screen")
+	// ErrMessageNotFound is returned when a chat message does not exist or was deleted
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrPollNotFound is returned when a poll does not exist
+	ErrPollNotFound = errors.New("poll not found")
+	// ErrPollClosed is returned when voting on or closing a poll that is already closed
+	ErrPollClosed = errors.New("poll is closed")
+	// ErrAlreadyVoted is returned when a participant votes twice on a poll
+	ErrAlreadyVoted = errors.New("already voted on this poll")
+	// ErrInvalidPoll is returned for a malformed poll or vote
+	ErrInvalidPoll = errors.New("invalid poll or vote")
+	// ErrTooManyPollOptions is returned when a poll offers more than MaxPollOptions options
+	ErrTooManyPollOptions = errors.New("poll has too many options")
+	// ErrPollTextTooLong is returned when a poll question or option is over its length limit
+	ErrPollTextTooLong = errors.New("poll question or option is too long")
+	// ErrQuestionNotFound is returned when a Q&A question does not exist or is not visible
+	ErrQuestionNotFound = errors.New("question not found")
+	// ErrBreakoutsOpen is returned when opening breakout rooms while others are still open
+	ErrBreakoutsOpen = errors.New("breakout rooms are already open")
+	// ErrNoBreakouts is returned when managing breakout rooms while none are open
+	ErrNoBreakouts = errors.New("no breakout rooms are open")
+	// ErrInvalidBreakout is returned for a bad breakout room count or an unknown breakout room
+	ErrInvalidBreakout = errors.New("invalid breakout room")
+	// ErrBreakoutClosed is returned when joining a breakout room that has been closed
+	ErrBreakoutClosed = errors.New("breakout room is closed")
+	// ErrInvalidMetadata is returned for metadata or attributes with a bad key or beyond the size limits
+	ErrInvalidMetadata = errors.New("invalid or oversized metadata")
+	// ErrInvalidSpotlight is returned when spotlighting more than MaxSpotlight participants or tracks
+	ErrInvalidSpotlight = errors.New("too many spotlighted participants")
+	// ErrInvalidPin is returned when pinning more than MaxPinned participants or tracks, or oneself
+	ErrInvalidPin = errors.New("invalid pin")
+	// ErrInvalidQuestionState is returned when a Q&A action does not fit the question's status
+	ErrInvalidQuestionState = errors.New("action not allowed in the question's current state")
 )
@@ -0,0 +1,117 @@
+package models
+
+// FloorState describes who holds the screen-share floor and who is waiting for it
+type FloorState struct {
+	Presenter string   `json:"presenter"`
+	Queue     []string `json:"queue"`
+}
+
+// RequestFloor asks for the screen-share floor of a screen sharing room.
+// It returns 0 if the floor was granted, or the 1-based queue position otherwise.
+func (r *Room) RequestFloor(participantID string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.Type != ScreenSharing {
+		return 0, ErrInvalidConnectionType
+	}
+	if _, ok := r.Participants[participantID]; !ok {
+		return 0, ErrParticipantNotFound
+	}
+
+	if r.Presenter == participantID {
+		return 0, nil
+	}
+	if r.Presenter == "" {
+		r.setPresenter(participantID)
+		return 0, nil
+	}
+
+	for i, id := range r.FloorQueue {
+		if id == participantID {
+			return i + 1, nil
+		}
+	}
+	r.FloorQueue = append(r.FloorQueue, participantID)
+	return len(r.FloorQueue), nil
+}
+
+// ReleaseFloor gives up the floor, or leaves the floor queue, and reports
+// whether the floor state changed. The next queued participant becomes presenter.
+func (r *Room) ReleaseFloor(participantID string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.releaseFloor(participantID)
+}
+
+// GrantFloor lets a moderator hand the floor to a participant, preempting the current presenter
+func (r *Room) GrantFloor(actorID, targetID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.Type != ScreenSharing {
+		return ErrInvalidConnectionType
+	}
+	actor, ok := r.Participants[actorID]
+	if !ok {
+		return ErrParticipantNotFound
+	}
+	if !actor.Role.CanModerate() {
+		return ErrPermissionDenied
+	}
+	if _, ok := r.Participants[targetID]; !ok {
+		return ErrParticipantNotFound
+	}
+
+	r.setPresenter(targetID)
+	return nil
+}
+
+// GetFloor returns the current floor state
+func (r *Room) GetFloor() FloorState {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	queue := make([]string, len(r.FloorQueue))
+	copy(queue, r.FloorQueue)
+	return FloorState{
+		Presenter: r.Presenter,
+		Queue:     queue,
+	}
+}
+
+// releaseFloor implements ReleaseFloor. Callers must hold the lock.
+func (r *Room) releaseFloor(participantID string) bool {
+	if r.Presenter != participantID {
+		for i, id := range r.FloorQueue {
+			if id == participantID {
+				r.FloorQueue = append(r.FloorQueue[:i], r.FloorQueue[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(r.FloorQueue) == 0 {
+		r.setPresenter("")
+		return true
+	}
+	r.setPresenter(r.FloorQueue[0])
+	return true
+}
+
+// setPresenter moves the floor to participantID, stopping the previous
+// presenter's screen share. Callers must hold the lock.
+func (r *Room) setPresenter(participantID string) {
+	if previous, ok := r.Participants[r.Presenter]; ok && r.Presenter != participantID {
+		previous.Media.Screen = false
+		previous.ConnectionInfo.IsScreenShare = false
+	}
+
+	for i, id := range r.FloorQueue {
+		if id == participantID {
+			r.FloorQueue = append(r.FloorQueue[:i], r.FloorQueue[i+1:]...)
+			break
+		}
+	}
+	r.Presenter = participantID
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+)
+
+func newScreenSharingRoom(t *testing.T, ids ...string) *Room {
+	t.Helper()
+	room := NewRoom("test-room", ScreenSharing)
+	for _, id := range ids {
+		room.AddParticipant(&Participant{ID: id, ConnectionInfo: &ConnectionInfo{Type: ScreenSharing}})
+	}
+	return room
+}
+
+func TestFloorQueue(t *testing.T) {
+	room := newScreenSharingRoom(t, "host", "p1", "p2")
+
+	if pos, err := room.RequestFloor("p1"); err != nil || pos != 0 {
+		t.Fatalf("Expected floor to be granted, got %d (%v)", pos, err)
+	}
+	if pos, _ := room.RequestFloor("p2"); pos != 1 {
+		t.Errorf("Expected queue position 1, got %d", pos)
+	}
+
+	on := true
+	if _, err := room.UpdateMediaState("p2", MediaStateUpdate{Screen: &on}); err != ErrNotPresenter {
+		t.Errorf("Expected %v, got %v", ErrNotPresenter, err)
+	}
+	if _, err := room.UpdateMediaState("p1", MediaStateUpdate{Screen: &on}); err != nil {
+		t.Errorf("Expected presenter to share, got %v", err)
+	}
+
+	room.RemoveParticipant("p1")
+	floor := room.GetFloor()
+	if floor.Presenter != "p2" || len(floor.Queue) != 0 {
+		t.Errorf("Expected p2 to get the floor, got %+v", floor)
+	}
+
+	if !room.ReleaseFloor("p2") || room.GetFloor().Presenter != "" {
+		t.Error("Expected floor to be released")
+	}
+}
+
+func TestGrantFloor(t *testing.T) {
+	room := newScreenSharingRoom(t, "host", "p1")
+
+	room.RequestFloor("p1")
+	on := true
+	room.UpdateMediaState("p1", MediaStateUpdate{Screen: &on})
+
+	if err := room.GrantFloor("p1", "p1"); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	if err := room.GrantFloor("host", "host"); err != nil {
+		t.Fatalf("Expected host to preempt, got %v", err)
+	}
+	if room.GetFloor().Presenter != "host" {
+		t.Error("Expected host to hold the floor")
+	}
+	if room.GetParticipant("p1").Media.Screen {
+		t.Error("Expected preempted presenter to stop sharing")
+	}
+
+	other := NewRoom("other", OneToOne)
+	other.AddParticipant(&Participant{ID: "p", ConnectionInfo: &ConnectionInfo{Type: OneToOne}})
+	if _, err := other.RequestFloor("p"); err != ErrInvalidConnectionType {
+		t.Errorf("Expected %v, got %v", ErrInvalidConnectionType, err)
+	}
+}
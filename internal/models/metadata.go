@@ -0,0 +1,128 @@
+package models
+
+import "unicode/utf8"
+
+const (
+	// MaxMetadataKeys is the most keys a room's metadata or a participant's attributes may hold
+	MaxMetadataKeys = 32
+	// MaxMetadataKeyLength is the longest metadata key, in characters
+	MaxMetadataKeyLength = 64
+	// MaxMetadataValueLength is the longest metadata value, in characters
+	MaxMetadataValueLength = 1024
+)
+
+// TopicKey is the room metadata key holding the room topic
+const TopicKey = "topic"
+
+// MetadataUpdate changes key-value metadata: keys mapped to a value are set,
+// keys mapped to nil are removed. It is also the diff broadcast to the room.
+type MetadataUpdate map[string]*string
+
+// applyMetadata applies an update to metadata and returns the resulting map
+// along with the keys that actually changed. metadata is left untouched if
+// the result would break the size limits.
+func applyMetadata(metadata map[string]string, update MetadataUpdate) (map[string]string, MetadataUpdate, error) {
+	result := make(map[string]string, len(metadata)+len(update))
+	for k, v := range metadata {
+		result[k] = v
+	}
+
+	diff := make(MetadataUpdate)
+	for k, v := range update {
+		if k == "" || utf8.RuneCountInString(k) > MaxMetadataKeyLength {
+			return nil, nil, ErrInvalidMetadata
+		}
+		current, ok := result[k]
+		if v == nil {
+			if ok {
+				delete(result, k)
+				diff[k] = nil
+			}
+			continue
+		}
+		if utf8.RuneCountInString(*v) > MaxMetadataValueLength {
+			return nil, nil, ErrInvalidMetadata
+		}
+		if !ok || current != *v {
+			value := *v
+			result[k] = value
+			diff[k] = &value
+		}
+	}
+	if len(result) > MaxMetadataKeys {
+		return nil, nil, ErrInvalidMetadata
+	}
+	return result, diff, nil
+}
+
+// copyMetadata returns a copy of metadata that is never nil
+func copyMetadata(metadata map[string]string) map[string]string {
+	c := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		c[k] = v
+	}
+	return c
+}
+
+// SetRoomMetadata updates the room's metadata and returns the keys that
+// changed. Only moderators may change it.
+func (r *Room) SetRoomMetadata(actorID string, update MetadataUpdate) (MetadataUpdate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.canModerate(actorID) {
+		return nil, ErrPermissionDenied
+	}
+	metadata, diff, err := applyMetadata(r.metadata, update)
+	if err != nil {
+		return nil, err
+	}
+	r.metadata = metadata
+	return diff, nil
+}
+
+// GetRoomMetadata returns a copy of the room's metadata
+func (r *Room) GetRoomMetadata() map[string]string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return copyMetadata(r.metadata)
+}
+
+// SetParticipantAttributes updates a participant's attributes and returns
+// the keys that changed. Participants may change their own attributes;
+// moderators may change anyone's.
+func (r *Room) SetParticipantAttributes(actorID, targetID string, update MetadataUpdate) (MetadataUpdate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if actorID != targetID && !r.canModerate(actorID) {
+		return nil, ErrPermissionDenied
+	}
+	target, ok := r.Participants[targetID]
+	if !ok {
+		return nil, ErrParticipantNotFound
+	}
+	attributes, diff, err := applyMetadata(target.Attributes, update)
+	if err != nil {
+		return nil, err
+	}
+	target.Attributes = attributes
+	return diff, nil
+}
+
+// SetTopic changes the room topic; an empty topic clears it. Only moderators
+// may set it. The topic is kept in the room metadata under TopicKey.
+func (r *Room) SetTopic(actorID, topic string) (MetadataUpdate, error) {
+	update := MetadataUpdate{TopicKey: nil}
+	if topic != "" {
+		update[TopicKey] = &topic
+	}
+	return r.SetRoomMetadata(actorID, update)
+}
+
+// GetTopic returns the room topic
+func (r *Room) GetTopic() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.metadata[TopicKey]
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestRoomMetadata(t *testing.T) {
+	room := newChatRoom(t)
+
+	if _, err := room.SetRoomMetadata("guest", MetadataUpdate{"agenda": strPtr("intro")}); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	diff, err := room.SetRoomMetadata("host", MetadataUpdate{"agenda": strPtr("intro"), "topic": strPtr("Planning")})
+	if err != nil || len(diff) != 2 {
+		t.Fatalf("Expected both keys to change, got %v (%v)", diff, err)
+	}
+	if room.GetTopic() != "Planning" {
+		t.Errorf("Expected the topic key to set the topic, got %q", room.GetTopic())
+	}
+
+	diff, _ = room.SetRoomMetadata("host", MetadataUpdate{"agenda": strPtr("intro"), "topic": nil, "missing": nil})
+	if len(diff) != 1 || diff["topic"] != nil {
+		t.Errorf("Expected only the removed topic in the diff, got %v", diff)
+	}
+	if metadata := room.GetRoomMetadata(); len(metadata) != 1 || metadata["agenda"] != "intro" {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+
+	if _, err := room.SetRoomMetadata("host", MetadataUpdate{"notes": strPtr(strings.Repeat("x", MaxMetadataValueLength+1))}); err != ErrInvalidMetadata {
+		t.Errorf("Expected %v for a long value, got %v", ErrInvalidMetadata, err)
+	}
+	tooMany := make(MetadataUpdate)
+	for i := 0; i < MaxMetadataKeys; i++ {
+		tooMany[strings.Repeat("k", i+1)] = strPtr("v")
+	}
+	if _, err := room.SetRoomMetadata("host", tooMany); err != ErrInvalidMetadata {
+		t.Errorf("Expected %v for too many keys, got %v", ErrInvalidMetadata, err)
+	}
+	if metadata := room.GetRoomMetadata(); len(metadata) != 1 {
+		t.Errorf("Expected a rejected update to leave metadata alone, got %v", metadata)
+	}
+}
+
+func TestParticipantAttributes(t *testing.T) {
+	room := newChatRoom(t)
+
+	if _, err := room.SetParticipantAttributes("guest", "host", MetadataUpdate{"language": strPtr("en")}); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	if _, err := room.SetParticipantAttributes("guest", "guest", MetadataUpdate{"language": strPtr("de")}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := room.SetParticipantAttributes("host", "guest", MetadataUpdate{"team": strPtr("blue")}); err != nil {
+		t.Fatalf("Expected moderators to edit others, got %v", err)
+	}
+	if view, _ := room.GetParticipantView("guest"); view.Attributes["language"] != "de" || view.Attributes["team"] != "blue" {
+		t.Errorf("Unexpected attributes %v", view.Attributes)
+	}
+}
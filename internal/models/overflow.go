@@ -0,0 +1,74 @@
+package models
+
+// OverflowPolicy decides what happens to participants joining a full room
+type OverflowPolicy string
+
+const (
+	// OverflowReject turns participants away from a full room
+	OverflowReject OverflowPolicy = "reject"
+	// OverflowQueue makes participants wait for a free slot, in order
+	OverflowQueue OverflowPolicy = "queue"
+	// OverflowViewer admits participants as receive-only viewers
+	OverflowViewer OverflowPolicy = "viewer"
+)
+
+// AddOverflowViewer admits a participant to a full room as a receive-only
+// viewer. Viewers don't take a slot, may not publish and can't become host.
+func (r *Room) AddOverflowViewer(p *Participant) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.addParticipant(p, RoleViewer)
+}
+
+// JoinWaitQueue admits a participant right away if the room has a free slot
+// and nobody is waiting before them; otherwise the participant joins the end
+// of the wait queue. It reports whether the participant was admitted.
+func (r *Room) JoinWaitQueue(p *Participant) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.waitQueue) == 0 && !r.isFull() {
+		r.addParticipant(p, r.initialRole(p))
+		return true
+	}
+	r.waitQueue = append(r.waitQueue, p)
+	return false
+}
+
+// LeaveWaitQueue removes a participant from the wait queue and reports
+// whether they were waiting
+func (r *Room) LeaveWaitQueue(participantID string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, p := range r.waitQueue {
+		if p.ID == participantID {
+			r.waitQueue = append(r.waitQueue[:i], r.waitQueue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// AdmitWaiting admits waiting participants, in order, while the room has
+// free slots and returns the ones admitted
+func (r *Room) AdmitWaiting() []*Participant {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var admitted []*Participant
+	for len(r.waitQueue) > 0 && !r.isFull() {
+		p := r.waitQueue[0]
+		r.waitQueue = r.waitQueue[1:]
+		r.addParticipant(p, r.initialRole(p))
+		admitted = append(admitted, p)
+	}
+	return admitted
+}
+
+// GetWaitQueue returns the participants waiting to join, first in line first
+func (r *Room) GetWaitQueue() []*Participant {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]*Participant(nil), r.waitQueue...)
+}
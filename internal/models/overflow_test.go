@@ -0,0 +1,62 @@
+package models
+
+import "testing"
+
+func newOverflowParticipant(id string) *Participant {
+	return &Participant{ID: id, ConnectionInfo: &ConnectionInfo{Type: OneToOne}, Media: MediaState{Audio: true, Video: true}}
+}
+
+func TestWaitQueue(t *testing.T) {
+	room := NewRoom("full", OneToOne)
+	room.AddParticipant(newOverflowParticipant("a"))
+
+	if !room.JoinWaitQueue(newOverflowParticipant("b")) {
+		t.Fatal("Expected a free slot to admit right away")
+	}
+	if room.JoinWaitQueue(newOverflowParticipant("c")) || room.JoinWaitQueue(newOverflowParticipant("d")) {
+		t.Fatal("Expected a full room to queue")
+	}
+	if err := room.AddParticipant(newOverflowParticipant("e")); err != ErrRoomFull {
+		t.Errorf("Expected joiners not to skip the queue, got %v", err)
+	}
+	if admitted := room.AdmitWaiting(); len(admitted) != 0 {
+		t.Errorf("Expected nobody admitted while full, got %d", len(admitted))
+	}
+
+	room.RemoveParticipant("a")
+	admitted := room.AdmitWaiting()
+	if len(admitted) != 1 || admitted[0].ID != "c" {
+		t.Fatalf("Expected the first in line to be admitted, got %v", admitted)
+	}
+	if admitted[0].Role != RoleParticipant {
+		t.Errorf("Expected the admitted participant to join as a participant, got %s", admitted[0].Role)
+	}
+
+	if !room.LeaveWaitQueue("d") || room.LeaveWaitQueue("d") {
+		t.Error("Expected d to leave the queue once")
+	}
+	if waiting := room.GetWaitQueue(); len(waiting) != 0 {
+		t.Errorf("Expected an empty queue, got %d waiting", len(waiting))
+	}
+}
+
+func TestOverflowViewer(t *testing.T) {
+	room := NewRoom("full", OneToOne)
+	room.AddParticipant(newOverflowParticipant("a"))
+	room.AddParticipant(newOverflowParticipant("b"))
+
+	viewer := newOverflowParticipant("c")
+	room.AddOverflowViewer(viewer)
+	if viewer.Role != RoleViewer || viewer.Media.Audio || viewer.Media.Video {
+		t.Errorf("Expected a receive-only viewer, got %s %+v", viewer.Role, viewer.Media)
+	}
+	on := true
+	if _, err := room.UpdateMediaState("c", MediaStateUpdate{Video: &on}); err != ErrPermissionDenied {
+		t.Errorf("Expected viewers not to publish, got %v", err)
+	}
+
+	room.RemoveParticipant("b")
+	if err := room.AddParticipant(newOverflowParticipant("d")); err != nil {
+		t.Errorf("Expected viewers not to take a slot, got %v", err)
+	}
+}
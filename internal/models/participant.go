@@ -0,0 +1,146 @@
+package models
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Role defines what a participant is allowed to do in a room
+type Role string
+
+const (
+	// RoleHost is the owner of a non-broadcasting room
+	RoleHost Role = "host"
+	// RoleParticipant is a regular member of a non-broadcasting room
+	RoleParticipant Role = "participant"
+	// RoleBroadcaster is the publisher of a broadcasting room
+	RoleBroadcaster Role = "broadcaster"
+	// RoleViewer is a receive-only member of a broadcasting room
+	RoleViewer Role = "viewer"
+	// RoleStage is a viewer promoted to publish alongside the broadcaster
+	RoleStage Role = "stage"
+)
+
+// CanModerate reports whether the role may manage other participants
+func (r Role) CanModerate() bool {
+	return r == RoleHost || r == RoleBroadcaster
+}
+
+// CanPublish reports whether the role may send audio, video or screen
+func (r Role) CanPublish() bool {
+	return r != RoleViewer
+}
+
+// MediaState describes which media a participant is currently sending
+type MediaState struct {
+	Audio  bool `json:"audio"`
+	Video  bool `json:"video"`
+	Screen bool `json:"screen"`
+}
+
+// Participant represents a user in a room.
+// Participant holds server-side state and must never be serialized to
+// clients directly; use View to build the public projection instead.
+type Participant struct {
+	ID             string            `json:"-"`
+	Conn           *websocket.Conn   `json:"-"`
+	Username       string            `json:"-"`
+	ConnectionInfo *ConnectionInfo   `json:"-"`
+	Role           Role              `json:"-"`
+	Media          MediaState        `json:"-"`
+	JoinedAt       time.Time         `json:"-"`
+	Attributes     map[string]string `json:"-"`
+	Token          string            `json:"-"` // Secret for REST calls, only ever sent to the participant itself
+
+	writeMutex sync.Mutex
+}
+
+// Send writes a JSON message to the participant's connection.
+// Writes are serialized so that messages from different goroutines don't interleave.
+func (p *Participant) Send(v interface{}) error {
+	if p.Conn == nil {
+		return nil
+	}
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+	return p.Conn.WriteJSON(v)
+}
+
+// Close closes the participant's connection, which ends their session
+func (p *Participant) Close() error {
+	if p.Conn == nil {
+		return nil
+	}
+	return p.Conn.Close()
+}
+
+// ParticipantView is the public projection of a Participant.
+// It is the only participant representation sent over signaling, so every
+// field added here becomes visible to all members of the room.
+type ParticipantView struct {
+	ID          string            `json:"id"`
+	DisplayName string            `json:"displayName"`
+	Role        Role              `json:"role"`
+	Media       MediaState        `json:"media"`
+	JoinedAt    int64             `json:"joinedAt"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+// View returns the public projection of the participant.
+// Callers must hold the owning room's lock or own the participant exclusively.
+func (p *Participant) View() ParticipantView {
+	var attributes map[string]string
+	if len(p.Attributes) > 0 {
+		attributes = make(map[string]string, len(p.Attributes))
+		for k, v := range p.Attributes {
+			attributes[k] = v
+		}
+	}
+
+	return ParticipantView{
+		ID:          p.ID,
+		DisplayName: p.Username,
+		Role:        p.Role,
+		Media:       p.Media,
+		JoinedAt:    p.JoinedAt.Unix(),
+		Attributes:  attributes,
+	}
+}
+
+// MediaStateUpdate is a partial MediaState; nil fields are left unchanged.
+// It is also used as the delta broadcast to other participants.
+type MediaStateUpdate struct {
+	Audio  *bool `json:"audio,omitempty"`
+	Video  *bool `json:"video,omitempty"`
+	Screen *bool `json:"screen,omitempty"`
+}
+
+// IsEmpty reports whether the update changes nothing
+func (u MediaStateUpdate) IsEmpty() bool {
+	return u.Audio == nil && u.Video == nil && u.Screen == nil
+}
+
+// enables reports whether the update turns on any media
+func (u MediaStateUpdate) enables() bool {
+	return (u.Audio != nil && *u.Audio) || (u.Video != nil && *u.Video) || (u.Screen != nil && *u.Screen)
+}
+
+// apply applies the update to the media state and returns only the fields that changed
+func (m *MediaState) apply(u MediaStateUpdate) MediaStateUpdate {
+	var delta MediaStateUpdate
+	if u.Audio != nil && *u.Audio != m.Audio {
+		m.Audio = *u.Audio
+		delta.Audio = u.Audio
+	}
+	if u.Video != nil && *u.Video != m.Video {
+		m.Video = *u.Video
+		delta.Video = u.Video
+	}
+	if u.Screen != nil && *u.Screen != m.Screen {
+		m.Screen = *u.Screen
+		delta.Screen = u.Screen
+	}
+	return delta
+}
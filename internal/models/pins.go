@@ -0,0 +1,63 @@
+package models
+
+// PinChatMessage pins a public chat message for everyone in the room.
+// Only moderators may pin; pinning an already pinned message is a no-op.
+func (r *Room) PinChatMessage(actorID, messageID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.canModerate(actorID) {
+		return ErrPermissionDenied
+	}
+	if r.chat.find(messageID) == nil {
+		return ErrMessageNotFound
+	}
+	for _, id := range r.pinned {
+		if id == messageID {
+			return nil
+		}
+	}
+	r.pinned = append(r.pinned, messageID)
+	return nil
+}
+
+// UnpinChatMessage removes a pinned message. Only moderators may unpin.
+func (r *Room) UnpinChatMessage(actorID, messageID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.canModerate(actorID) {
+		return ErrPermissionDenied
+	}
+	if !r.unpin(messageID) {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+// GetPinnedMessages returns the pinned messages in the order they were pinned.
+// Messages that have since left the retained history are skipped.
+func (r *Room) GetPinnedMessages() []ChatMessage {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	pinned := make([]ChatMessage, 0, len(r.pinned))
+	for _, id := range r.pinned {
+		if m := r.chat.find(id); m != nil {
+			pinned = append(pinned, m.copy())
+		}
+	}
+	return pinned
+}
+
+// unpin removes a message from the pins and reports whether it was pinned.
+// Callers must hold the lock.
+func (r *Room) unpin(messageID string) bool {
+	for i, id := range r.pinned {
+		if id == messageID {
+			r.pinned = append(r.pinned[:i], r.pinned[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestPinChatMessage(t *testing.T) {
+	room := newChatRoom(t)
+	first, _ := room.AddChatMessage(ChatMessage{SenderID: "guest", Content: "one"})
+	second, _ := room.AddChatMessage(ChatMessage{SenderID: "guest", Content: "two"})
+
+	if err := room.PinChatMessage("guest", first.ID); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	if err := room.PinChatMessage("host", "missing"); err != ErrMessageNotFound {
+		t.Errorf("Expected %v, got %v", ErrMessageNotFound, err)
+	}
+	room.PinChatMessage("host", second.ID)
+	room.PinChatMessage("host", first.ID)
+	room.PinChatMessage("host", first.ID)
+	pinned := room.GetPinnedMessages()
+	if len(pinned) != 2 || pinned[0].ID != second.ID || pinned[1].ID != first.ID {
+		t.Fatalf("Expected pins in pin order, got %+v", pinned)
+	}
+
+	if err := room.UnpinChatMessage("host", second.ID); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := room.UnpinChatMessage("host", second.ID); err != ErrMessageNotFound {
+		t.Errorf("Expected %v, got %v", ErrMessageNotFound, err)
+	}
+
+	room.DeleteChatMessage("guest", first.ID)
+	if pinned := room.GetPinnedMessages(); len(pinned) != 0 {
+		t.Errorf("Expected deleting to unpin, got %+v", pinned)
+	}
+}
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxPollOptions is the largest number of options a poll may offer
+	MaxPollOptions = 10
+	// MaxPollQuestionLength is the longest question a poll may ask, in characters
+	MaxPollQuestionLength = 300
+	// MaxPollOptionLength is the longest option a poll may offer, in characters
+	MaxPollOptionLength = 100
+)
+
+// PollRequest describes a poll to create
+type PollRequest struct {
+	Question    string        `json:"question"`
+	Options     []string      `json:"options"`
+	MultiChoice bool          `json:"multiChoice"`
+	Anonymous   bool          `json:"anonymous"`
+	Duration    time.Duration `json:"-"` // Zero keeps the poll open until it is closed by hand
+}
+
+// Poll is a question put to the room by a moderator
+type Poll struct {
+	ID          string
+	Question    string
+	Options     []string
+	MultiChoice bool
+	Anonymous   bool
+	CreatedBy   string
+	CreatedAt   time.Time
+	ClosesAt    time.Time // Zero when the poll has no timer
+	Closed      bool
+
+	votes map[string]pollVote // Participant ID -> vote
+}
+
+// pollVote is one participant's vote and the display name they cast it under
+type pollVote struct {
+	name    string
+	choices []int
+}
+
+// PollView is the public projection of a Poll for one participant
+type PollView struct {
+	ID          string       `json:"id"`
+	Question    string       `json:"question"`
+	Options     []string     `json:"options"`
+	MultiChoice bool         `json:"multiChoice"`
+	Anonymous   bool         `json:"anonymous"`
+	CreatedBy   string       `json:"createdBy"`
+	CreatedAt   int64        `json:"createdAt"`
+	ClosesAt    int64        `json:"closesAt,omitempty"`
+	Closed      bool         `json:"closed"`
+	MyChoices   []int        `json:"myChoices,omitempty"` // The viewer's own vote
+	Results     *PollResults `json:"results,omitempty"`   // Only for moderators, or once the poll is closed
+}
+
+// PollResults is the tally of a poll
+type PollResults struct {
+	Counts []int      `json:"counts"`          // Votes per option
+	Voters int        `json:"voters"`          // Number of participants who voted
+	Names  [][]string `json:"names,omitempty"` // Display names of voters per option, for named polls
+}
+
+// view returns the poll as seen by a participant.
+// Results are included when withResults is set or the poll is closed.
+func (p *Poll) view(viewerID string, withResults bool) PollView {
+	view := PollView{
+		ID:          p.ID,
+		Question:    p.Question,
+		Options:     append([]string(nil), p.Options...),
+		MultiChoice: p.MultiChoice,
+		Anonymous:   p.Anonymous,
+		CreatedBy:   p.CreatedBy,
+		CreatedAt:   p.CreatedAt.Unix(),
+		Closed:      p.Closed,
+	}
+	if !p.ClosesAt.IsZero() {
+		view.ClosesAt = p.ClosesAt.Unix()
+	}
+	if vote, ok := p.votes[viewerID]; ok {
+		view.MyChoices = append([]int(nil), vote.choices...)
+	}
+	if withResults || p.Closed {
+		view.Results = p.results()
+	}
+	return view
+}
+
+// results tallies the votes of the poll
+func (p *Poll) results() *PollResults {
+	results := &PollResults{
+		Counts: make([]int, len(p.Options)),
+		Voters: len(p.votes),
+	}
+	if !p.Anonymous {
+		results.Names = make([][]string, len(p.Options))
+		for i := range results.Names {
+			results.Names[i] = make([]string, 0)
+		}
+	}
+	for _, vote := range p.votes {
+		for _, choice := range vote.choices {
+			results.Counts[choice]++
+			if results.Names != nil {
+				results.Names[choice] = append(results.Names[choice], vote.name)
+			}
+		}
+	}
+	for _, names := range results.Names {
+		sort.Strings(names)
+	}
+	return results
+}
+
+// validChoices reports whether a set of option indexes is a valid vote
+func (p *Poll) validChoices(choices []int) bool {
+	if len(choices) == 0 || (!p.MultiChoice && len(choices) != 1) {
+		return false
+	}
+	seen := make(map[int]bool, len(choices))
+	for _, choice := range choices {
+		if choice < 0 || choice >= len(p.Options) || seen[choice] {
+			return false
+		}
+		seen[choice] = true
+	}
+	return true
+}
+
+// CreatePoll opens a new poll and returns it as seen by its creator.
+// Only moderators may create polls.
+func (r *Room) CreatePoll(actorID string, req PollRequest) (PollView, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.canModerate(actorID) {
+		return PollView{}, ErrPermissionDenied
+	}
+	question := strings.TrimSpace(req.Question)
+	if utf8.RuneCountInString(question) > MaxPollQuestionLength {
+		return PollView{}, ErrPollTextTooLong
+	}
+	options := make([]string, 0, len(req.Options))
+	for _, option := range req.Options {
+		if option = strings.TrimSpace(option); option == "" {
+			continue
+		}
+		if utf8.RuneCountInString(option) > MaxPollOptionLength {
+			return PollView{}, ErrPollTextTooLong
+		}
+		options = append(options, option)
+	}
+	if len(options) > MaxPollOptions {
+		return PollView{}, ErrTooManyPollOptions
+	}
+	if question == "" || len(options) < 2 {
+		return PollView{}, ErrInvalidPoll
+	}
+
+	poll := &Poll{
+		ID:          uuid.New().String(),
+		Question:    question,
+		Options:     options,
+		MultiChoice: req.MultiChoice,
+		Anonymous:   req.Anonymous,
+		CreatedBy:   actorID,
+		CreatedAt:   time.Now(),
+		votes:       make(map[string]pollVote),
+	}
+	if req.Duration > 0 {
+		poll.ClosesAt = poll.CreatedAt.Add(req.Duration)
+	}
+	r.polls = append(r.polls, poll)
+	return poll.view(actorID, true), nil
+}
+
+// VotePoll records a participant's vote. Everyone in the room may vote once per poll.
+func (r *Room) VotePoll(participantID, pollID string, choices []int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	voter, ok := r.Participants[participantID]
+	if !ok {
+		return ErrParticipantNotFound
+	}
+	poll := r.findPoll(pollID)
+	if poll == nil {
+		return ErrPollNotFound
+	}
+	if poll.Closed {
+		return ErrPollClosed
+	}
+	if _, voted := poll.votes[participantID]; voted {
+		return ErrAlreadyVoted
+	}
+	if !poll.validChoices(choices) {
+		return ErrInvalidPoll
+	}
+	name := voter.Username
+	if name == "" {
+		name = participantID
+	}
+	poll.votes[participantID] = pollVote{name: name, choices: append([]int(nil), choices...)}
+	return nil
+}
+
+// ClosePoll closes a poll to further votes. Only moderators may close polls.
+func (r *Room) ClosePoll(actorID, pollID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.canModerate(actorID) {
+		return ErrPermissionDenied
+	}
+	poll := r.findPoll(pollID)
+	if poll == nil {
+		return ErrPollNotFound
+	}
+	if poll.Closed {
+		return ErrPollClosed
+	}
+	poll.Closed = true
+	return nil
+}
+
+// ExpirePoll closes a timed poll once its timer ran out and reports whether
+// it was closed by this call
+func (r *Room) ExpirePoll(pollID string, now time.Time) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	poll := r.findPoll(pollID)
+	if poll == nil || poll.Closed || poll.ClosesAt.IsZero() || now.Before(poll.ClosesAt) {
+		return false
+	}
+	poll.Closed = true
+	return true
+}
+
+// GetPollView returns a poll as seen by a participant. Moderators see the
+// live results; everyone else sees them once the poll is closed.
+func (r *Room) GetPollView(viewerID, pollID string) (PollView, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	poll := r.findPoll(pollID)
+	if poll == nil {
+		return PollView{}, false
+	}
+	return poll.view(viewerID, r.canModerate(viewerID)), true
+}
+
+// GetPollViews returns every poll of the room as seen by a participant, oldest first
+func (r *Room) GetPollViews(viewerID string) []PollView {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	moderator := r.canModerate(viewerID)
+	views := make([]PollView, 0, len(r.polls))
+	for _, poll := range r.polls {
+		views = append(views, poll.view(viewerID, moderator))
+	}
+	return views
+}
+
+// findPoll returns the poll with the given ID. Callers must hold the lock.
+func (r *Room) findPoll(pollID string) *Poll {
+	for _, poll := range r.polls {
+		if poll.ID == pollID {
+			return poll
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPollVoting(t *testing.T) {
+	room := newChatRoom(t)
+	room.GetParticipant("guest").Username = "Guest"
+
+	if _, err := room.CreatePoll("guest", PollRequest{Question: "?", Options: []string{"a", "b"}}); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	if _, err := room.CreatePoll("host", PollRequest{Question: "?", Options: []string{"a", " "}}); err != ErrInvalidPoll {
+		t.Errorf("Expected %v, got %v", ErrInvalidPoll, err)
+	}
+	if _, err := room.CreatePoll("host", PollRequest{Question: "?", Options: strings.Split(strings.Repeat("a,", MaxPollOptions)+"a", ",")}); err != ErrTooManyPollOptions {
+		t.Errorf("Expected %v, got %v", ErrTooManyPollOptions, err)
+	}
+	if _, err := room.CreatePoll("host", PollRequest{Question: "?", Options: []string{"a", strings.Repeat("é", MaxPollOptionLength+1)}}); err != ErrPollTextTooLong {
+		t.Errorf("Expected %v, got %v", ErrPollTextTooLong, err)
+	}
+	if _, err := room.CreatePoll("host", PollRequest{Question: strings.Repeat("?", MaxPollQuestionLength+1), Options: []string{"a", "b"}}); err != ErrPollTextTooLong {
+		t.Errorf("Expected %v, got %v", ErrPollTextTooLong, err)
+	}
+	poll, err := room.CreatePoll("host", PollRequest{Question: "Lunch?", Options: []string{"Pizza", "Sushi", "Salad"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := room.VotePoll("guest", poll.ID, []int{0, 1}); err != ErrInvalidPoll {
+		t.Errorf("Expected single choice to reject two options, got %v", err)
+	}
+	if err := room.VotePoll("guest", poll.ID, []int{1}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := room.VotePoll("guest", poll.ID, []int{2}); err != ErrAlreadyVoted {
+		t.Errorf("Expected %v, got %v", ErrAlreadyVoted, err)
+	}
+
+	guestView, _ := room.GetPollView("guest", poll.ID)
+	if guestView.Results != nil || len(guestView.MyChoices) != 1 {
+		t.Errorf("Expected participants to see only their own vote while open, got %+v", guestView)
+	}
+	hostView, _ := room.GetPollView("host", poll.ID)
+	if hostView.Results == nil || hostView.Results.Counts[1] != 1 || hostView.Results.Names[1][0] != "Guest" {
+		t.Errorf("Expected hosts to see live named results, got %+v", hostView.Results)
+	}
+
+	// Someone else joining under the same name neither sees nor blocks the vote
+	room.RemoveParticipant("guest")
+	room.AddParticipant(&Participant{ID: "guest-2", Username: "guest", ConnectionInfo: &ConnectionInfo{Type: OneToOne}})
+	if view, _ := room.GetPollView("guest-2", poll.ID); len(view.MyChoices) != 0 {
+		t.Errorf("Expected no choices for another participant with the same name, got %+v", view)
+	}
+	if err := room.VotePoll("guest-2", poll.ID, []int{0}); err != nil {
+		t.Errorf("Expected another participant with the same name to vote, got %v", err)
+	}
+
+	if err := room.ClosePoll("guest-2", poll.ID); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	room.ClosePoll("host", poll.ID)
+	if err := room.VotePoll("host", poll.ID, []int{0}); err != ErrPollClosed {
+		t.Errorf("Expected %v, got %v", ErrPollClosed, err)
+	}
+	if views := room.GetPollViews("guest-2"); len(views) != 1 || views[0].Results == nil {
+		t.Errorf("Expected results for everyone once closed, got %+v", views)
+	}
+}
+
+func TestPollAnonymousAndTimed(t *testing.T) {
+	room := newChatRoom(t)
+	poll, _ := room.CreatePoll("host", PollRequest{
+		Question:    "Topics?",
+		Options:     []string{"a", "b", "c"},
+		MultiChoice: true,
+		Anonymous:   true,
+		Duration:    time.Minute,
+	})
+	if poll.ClosesAt == 0 {
+		t.Error("Expected a timed poll to have a closing time")
+	}
+
+	room.VotePoll("guest", poll.ID, []int{0, 2})
+	view, _ := room.GetPollView("host", poll.ID)
+	if view.Results.Names != nil || view.Results.Counts[0] != 1 || view.Results.Counts[2] != 1 {
+		t.Errorf("Expected anonymous multi-choice counts, got %+v", view.Results)
+	}
+
+	if room.ExpirePoll(poll.ID, time.Now()) {
+		t.Error("Expected the poll to stay open before its timer")
+	}
+	if !room.ExpirePoll(poll.ID, time.Now().Add(time.Minute)) {
+		t.Error("Expected the poll to close after its timer")
+	}
+}
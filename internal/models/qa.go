@@ -0,0 +1,226 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QuestionStatus is where a Q&A question is in moderation
+type QuestionStatus string
+
+const (
+	// QuestionPending is a question waiting for a moderator
+	QuestionPending QuestionStatus = "pending"
+	// QuestionApproved is a question open to everyone for upvotes
+	QuestionApproved QuestionStatus = "approved"
+	// QuestionAnswered is a question a moderator has answered
+	QuestionAnswered QuestionStatus = "answered"
+	// QuestionDismissed is a question a moderator has rejected
+	QuestionDismissed QuestionStatus = "dismissed"
+)
+
+// QuestionAction is what a moderator does with a question
+type QuestionAction string
+
+const (
+	// QuestionApprove makes a pending question visible to everyone
+	QuestionApprove QuestionAction = "approve"
+	// QuestionAnswer marks a question as answered, live or in text
+	QuestionAnswer QuestionAction = "answer"
+	// QuestionDismiss rejects a question
+	QuestionDismiss QuestionAction = "dismiss"
+)
+
+// Question is a question submitted to a room's Q&A
+type Question struct {
+	ID           string
+	AskerID      string
+	AskerName    string
+	Text         string
+	Status       QuestionStatus
+	AskedAt      time.Time
+	Answer       string // Text answer, empty for live answers
+	AnsweredLive bool
+	upvotes      map[string]bool // IDs of the participants who upvoted
+}
+
+// QuestionView is the public projection of a Question for one participant
+type QuestionView struct {
+	ID           string         `json:"id"`
+	AskerID      string         `json:"askerId"`
+	AskerName    string         `json:"askerName"`
+	Text         string         `json:"text"`
+	Status       QuestionStatus `json:"status"`
+	AskedAt      int64          `json:"askedAt"`
+	Upvotes      int            `json:"upvotes"`
+	Upvoted      bool           `json:"upvoted"` // Whether the viewer upvoted
+	Answer       string         `json:"answer,omitempty"`
+	AnsweredLive bool           `json:"answeredLive,omitempty"`
+}
+
+// view returns the question as seen by a participant
+func (q *Question) view(viewerID string) QuestionView {
+	return QuestionView{
+		ID:           q.ID,
+		AskerID:      q.AskerID,
+		AskerName:    q.AskerName,
+		Text:         q.Text,
+		Status:       q.Status,
+		AskedAt:      q.AskedAt.Unix(),
+		Upvotes:      len(q.upvotes),
+		Upvoted:      q.upvotes[viewerID],
+		Answer:       q.Answer,
+		AnsweredLive: q.AnsweredLive,
+	}
+}
+
+// visibleTo reports whether a participant may see the question. Moderators
+// see every question; others see approved and answered ones and their own.
+func (q *Question) visibleTo(viewerID string, moderator bool) bool {
+	switch {
+	case moderator, q.AskerID == viewerID:
+		return true
+	default:
+		return q.Status == QuestionApproved || q.Status == QuestionAnswered
+	}
+}
+
+// questionRank orders statuses in the Q&A list: open questions first
+var questionRank = map[QuestionStatus]int{
+	QuestionApproved:  0,
+	QuestionPending:   1,
+	QuestionAnswered:  2,
+	QuestionDismissed: 3,
+}
+
+// AskQuestion submits a question to the room's Q&A. Questions from
+// moderators are approved right away; others wait for a moderator.
+func (r *Room) AskQuestion(askerID, text string) (QuestionView, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	asker, ok := r.Participants[askerID]
+	if !ok {
+		return QuestionView{}, ErrParticipantNotFound
+	}
+	q := &Question{
+		ID:        uuid.New().String(),
+		AskerID:   askerID,
+		AskerName: asker.Username,
+		Text:      text,
+		Status:    QuestionPending,
+		AskedAt:   time.Now(),
+		upvotes:   make(map[string]bool),
+	}
+	if asker.Role.CanModerate() {
+		q.Status = QuestionApproved
+	}
+	r.questions = append(r.questions, q)
+	return q.view(askerID), nil
+}
+
+// UpvoteQuestion toggles a participant's upvote on an approved question
+func (r *Room) UpvoteQuestion(voterID, questionID string) (QuestionView, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.Participants[voterID]; !ok {
+		return QuestionView{}, ErrParticipantNotFound
+	}
+	q := r.findQuestion(questionID)
+	if q == nil || !q.visibleTo(voterID, r.canModerate(voterID)) {
+		return QuestionView{}, ErrQuestionNotFound
+	}
+	if q.Status != QuestionApproved {
+		return QuestionView{}, ErrInvalidQuestionState
+	}
+	if q.upvotes[voterID] {
+		delete(q.upvotes, voterID)
+	} else {
+		q.upvotes[voterID] = true
+	}
+	return q.view(voterID), nil
+}
+
+// ModerateQuestion approves, answers or dismisses a question. Only
+// moderators may do so. An empty answer means the question was answered live.
+func (r *Room) ModerateQuestion(actorID, questionID string, action QuestionAction, answer string) (QuestionView, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.canModerate(actorID) {
+		return QuestionView{}, ErrPermissionDenied
+	}
+	q := r.findQuestion(questionID)
+	if q == nil {
+		return QuestionView{}, ErrQuestionNotFound
+	}
+
+	switch action {
+	case QuestionApprove:
+		if q.Status != QuestionPending {
+			return QuestionView{}, ErrInvalidQuestionState
+		}
+		q.Status = QuestionApproved
+	case QuestionAnswer:
+		if q.Status == QuestionDismissed {
+			return QuestionView{}, ErrInvalidQuestionState
+		}
+		q.Status = QuestionAnswered
+		q.Answer = answer
+		q.AnsweredLive = answer == ""
+	case QuestionDismiss:
+		q.Status = QuestionDismissed
+	default:
+		return QuestionView{}, ErrInvalidQuestionState
+	}
+	return q.view(actorID), nil
+}
+
+// GetQuestion returns a question as seen by a participant, if they may see it
+func (r *Room) GetQuestion(viewerID, questionID string) (QuestionView, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	q := r.findQuestion(questionID)
+	if q == nil || !q.visibleTo(viewerID, r.canModerate(viewerID)) {
+		return QuestionView{}, false
+	}
+	return q.view(viewerID), true
+}
+
+// GetQuestions returns the Q&A questions a participant may see: open
+// questions by upvotes, then pending, answered and dismissed ones, each
+// oldest first within the same number of upvotes
+func (r *Room) GetQuestions(viewerID string) []QuestionView {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	moderator := r.canModerate(viewerID)
+	views := make([]QuestionView, 0, len(r.questions))
+	for _, q := range r.questions {
+		if q.visibleTo(viewerID, moderator) {
+			views = append(views, q.view(viewerID))
+		}
+	}
+	sort.SliceStable(views, func(i, j int) bool {
+		a, b := views[i], views[j]
+		if questionRank[a.Status] != questionRank[b.Status] {
+			return questionRank[a.Status] < questionRank[b.Status]
+		}
+		return a.Upvotes > b.Upvotes
+	})
+	return views
+}
+
+// findQuestion returns the question with the given ID. Callers must hold the lock.
+func (r *Room) findQuestion(questionID string) *Question {
+	for _, q := range r.questions {
+		if q.ID == questionID {
+			return q
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import "testing"
+
+func TestQuestionModeration(t *testing.T) {
+	room := newBroadcastRoom(t, 0, "v1", "v2")
+
+	q, err := room.AskQuestion("v1", "When is the next session?")
+	if err != nil || q.Status != QuestionPending {
+		t.Fatalf("Expected a pending question, got %+v, %v", q, err)
+	}
+	if questions := room.GetQuestions("v2"); len(questions) != 0 {
+		t.Errorf("Expected pending questions to be hidden from others, got %+v", questions)
+	}
+	if _, ok := room.GetQuestion("v2", q.ID); ok {
+		t.Error("Expected a pending question to be hidden from others")
+	}
+	if questions := room.GetQuestions("v1"); len(questions) != 1 {
+		t.Errorf("Expected askers to see their own question, got %+v", questions)
+	}
+	if _, err := room.UpvoteQuestion("v2", q.ID); err != ErrQuestionNotFound {
+		t.Errorf("Expected %v, got %v", ErrQuestionNotFound, err)
+	}
+	if _, err := room.ModerateQuestion("v2", q.ID, QuestionApprove, ""); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+
+	if _, err := room.ModerateQuestion("broadcaster", q.ID, QuestionApprove, ""); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	upvoted, err := room.UpvoteQuestion("v2", q.ID)
+	if err != nil || upvoted.Upvotes != 1 || !upvoted.Upvoted {
+		t.Errorf("Expected an upvote, got %+v, %v", upvoted, err)
+	}
+	if toggled, _ := room.UpvoteQuestion("v2", q.ID); toggled.Upvotes != 0 {
+		t.Errorf("Expected a second upvote to toggle, got %+v", toggled)
+	}
+
+	// Upvotes belong to the participant, not to their display name
+	room.GetParticipant("v2").Username = "Viewer"
+	room.UpvoteQuestion("v2", q.ID)
+	room.AddParticipant(&Participant{ID: "v3", Username: "viewer", ConnectionInfo: &ConnectionInfo{Type: Broadcasting}})
+	if view, _ := room.GetQuestion("v3", q.ID); view.Upvoted {
+		t.Errorf("Expected another participant with the same name not to see the upvote, got %+v", view)
+	}
+	if upvoted, _ := room.UpvoteQuestion("v3", q.ID); upvoted.Upvotes != 2 {
+		t.Errorf("Expected a second upvote from another participant, got %+v", upvoted)
+	}
+
+	answered, err := room.ModerateQuestion("broadcaster", q.ID, QuestionAnswer, "")
+	if err != nil || answered.Status != QuestionAnswered || !answered.AnsweredLive {
+		t.Errorf("Expected a live answer, got %+v, %v", answered, err)
+	}
+	if _, err := room.UpvoteQuestion("v3", q.ID); err != ErrInvalidQuestionState {
+		t.Errorf("Expected %v, got %v", ErrInvalidQuestionState, err)
+	}
+}
+
+func TestQuestionOrder(t *testing.T) {
+	room := newBroadcastRoom(t, 0, "v1", "v2")
+
+	first, _ := room.AskQuestion("broadcaster", "first")
+	second, _ := room.AskQuestion("broadcaster", "second")
+	third, _ := room.AskQuestion("broadcaster", "third")
+	dismissed, _ := room.AskQuestion("v1", "spam")
+	room.ModerateQuestion("broadcaster", dismissed.ID, QuestionDismiss, "")
+	room.UpvoteQuestion("v1", third.ID)
+	room.UpvoteQuestion("v2", third.ID)
+	room.UpvoteQuestion("v1", second.ID)
+	room.ModerateQuestion("broadcaster", first.ID, QuestionAnswer, "In the docs")
+
+	questions := room.GetQuestions("broadcaster")
+	order := []string{third.ID, second.ID, first.ID, dismissed.ID}
+	if len(questions) != len(order) {
+		t.Fatalf("Expected %d questions, got %+v", len(order), questions)
+	}
+	for i, id := range order {
+		if questions[i].ID != id {
+			t.Errorf("Expected %q at position %d, got %q", id, i, questions[i].Text)
+		}
+	}
+	if questions[2].Answer != "In the docs" {
+		t.Errorf("Expected a text answer, got %+v", questions[2])
+	}
+	if viewer := room.GetQuestions("v2"); len(viewer) != 3 {
+		t.Errorf("Expected dismissed questions to be hidden from viewers, got %+v", viewer)
+	}
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// ReadReceipt is how far a participant has read the public chat
+type ReadReceipt struct {
+	ParticipantID string `json:"participantId"`
+	MessageID     string `json:"messageId"`
+	Seq           int64  `json:"seq"`
+}
+
+// SetTyping marks a participant as typing until the room's typing timeout
+// passes, or clears the mark. It reports whether the set of typing
+// participants changed; refreshing an existing mark is not a change, so
+// repeated typing events are coalesced.
+func (r *Room) SetTyping(participantID string, typing bool) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.Participants[participantID]; !ok {
+		return false, ErrParticipantNotFound
+	}
+	if !typing {
+		return r.clearTyping(participantID), nil
+	}
+	_, wasTyping := r.typing[participantID]
+	r.typing[participantID] = typingMark{
+		since:     r.typingSince(participantID),
+		expiresAt: time.Now().Add(r.Settings.TypingTimeout),
+	}
+	return !wasTyping, nil
+}
+
+// ExpireTyping clears typing marks that have not been refreshed in time and
+// reports whether any were cleared, along with when the next remaining mark
+// lapses (zero when nobody is typing anymore)
+func (r *Room) ExpireTyping(now time.Time) (bool, time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	changed := false
+	var next time.Time
+	for id, mark := range r.typing {
+		if !now.Before(mark.expiresAt) {
+			delete(r.typing, id)
+			changed = true
+		} else if next.IsZero() || mark.expiresAt.Before(next) {
+			next = mark.expiresAt
+		}
+	}
+	return changed, next
+}
+
+// GetTyping returns the IDs of participants currently typing, in the order they started
+func (r *Room) GetTyping() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ids := make([]string, 0, len(r.typing))
+	for id := range r.typing {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := r.typing[ids[i]].since, r.typing[ids[j]].since
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// MarkChatRead moves a participant's read watermark up to a public chat
+// message. Watermarks only move forward; the returned bool reports whether it moved.
+func (r *Room) MarkChatRead(participantID, messageID string) (ReadReceipt, bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.Participants[participantID]; !ok {
+		return ReadReceipt{}, false, ErrParticipantNotFound
+	}
+	i := r.chat.index(messageID)
+	if i < 0 {
+		return ReadReceipt{}, false, ErrMessageNotFound
+	}
+	message := r.chat.at(i)
+
+	current, ok := r.readReceipts[participantID]
+	if ok && current.Seq >= message.Seq {
+		return current, false, nil
+	}
+	receipt := ReadReceipt{
+		ParticipantID: participantID,
+		MessageID:     message.ID,
+		Seq:           message.Seq,
+	}
+	r.readReceipts[participantID] = receipt
+	return receipt, true, nil
+}
+
+// GetReadReceipts returns the read watermarks of the participants in the room
+func (r *Room) GetReadReceipts() []ReadReceipt {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	receipts := make([]ReadReceipt, 0, len(r.readReceipts))
+	for _, receipt := range r.readReceipts {
+		receipts = append(receipts, receipt)
+	}
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].ParticipantID < receipts[j].ParticipantID
+	})
+	return receipts
+}
+
+// typingMark records when a participant started typing and when the mark lapses
+type typingMark struct {
+	since     time.Time
+	expiresAt time.Time
+}
+
+// typingSince returns when a participant started typing, keeping the start
+// of a mark that is being refreshed. Callers must hold the lock.
+func (r *Room) typingSince(participantID string) time.Time {
+	if mark, ok := r.typing[participantID]; ok {
+		return mark.since
+	}
+	return time.Now()
+}
+
+// clearTyping removes a participant's typing mark and reports whether it had one.
+// Callers must hold the lock.
+func (r *Room) clearTyping(participantID string) bool {
+	if _, ok := r.typing[participantID]; !ok {
+		return false
+	}
+	delete(r.typing, participantID)
+	return true
+}
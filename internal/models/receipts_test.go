@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTyping(t *testing.T) {
+	room := newChatRoom(t)
+
+	if changed, err := room.SetTyping("guest", true); err != nil || !changed {
+		t.Fatalf("Expected typing to start, got %v, %v", changed, err)
+	}
+	if changed, _ := room.SetTyping("guest", true); changed {
+		t.Error("Expected a refresh to be coalesced")
+	}
+	room.SetTyping("host", true)
+	if typing := room.GetTyping(); len(typing) != 2 || typing[0] != "guest" {
+		t.Errorf("Expected typing in start order, got %v", typing)
+	}
+	if _, err := room.SetTyping("missing", true); err != ErrParticipantNotFound {
+		t.Errorf("Expected %v, got %v", ErrParticipantNotFound, err)
+	}
+
+	if changed, next := room.ExpireTyping(time.Now()); changed || next.IsZero() {
+		t.Errorf("Expected fresh marks to survive until they lapse, got %v, %v", changed, next)
+	}
+	if changed, next := room.ExpireTyping(time.Now().Add(room.Settings.TypingTimeout)); !changed || !next.IsZero() || len(room.GetTyping()) != 0 {
+		t.Errorf("Expected marks to expire, got %v, %v, %v", changed, next, room.GetTyping())
+	}
+
+	room.SetTyping("guest", true)
+	room.RemoveParticipant("guest")
+	if typing := room.GetTyping(); len(typing) != 0 {
+		t.Errorf("Expected leaving to clear typing, got %v", typing)
+	}
+}
+
+func TestMarkChatRead(t *testing.T) {
+	room := newChatRoom(t)
+	first, _ := room.AddChatMessage(ChatMessage{SenderID: "host", Content: "one"})
+	second, _ := room.AddChatMessage(ChatMessage{SenderID: "host", Content: "two"})
+
+	receipt, moved, err := room.MarkChatRead("guest", second.ID)
+	if err != nil || !moved || receipt.MessageID != second.ID {
+		t.Fatalf("Expected watermark at second message, got %+v, %v, %v", receipt, moved, err)
+	}
+	if receipt, moved, _ := room.MarkChatRead("guest", first.ID); moved || receipt.MessageID != second.ID {
+		t.Errorf("Expected watermark not to move back, got %+v", receipt)
+	}
+	if _, _, err := room.MarkChatRead("guest", "missing"); err != ErrMessageNotFound {
+		t.Errorf("Expected %v, got %v", ErrMessageNotFound, err)
+	}
+
+	receipts := room.GetReadReceipts()
+	if len(receipts) != 1 || receipts[0].ParticipantID != "guest" {
+		t.Errorf("Unexpected receipts %+v", receipts)
+	}
+	room.RemoveParticipant("guest")
+	if receipts := room.GetReadReceipts(); len(receipts) != 0 {
+		t.Errorf("Expected leaving to drop the watermark, got %+v", receipts)
+	}
+}
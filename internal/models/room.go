@@ -1,45 +1,79 @@
 package models
 
 import (
+	"sort"
+	"strings"
 	"sync"
-
-	"github.com/gorilla/websocket"
+	"time"
 )
 
-// Participant represents a user in a room
-type Participant struct {
-	ID             string
-	Conn           *websocket.Conn
-	Username       string
-	ConnectionInfo *ConnectionInfo
-}
-
 // Room represents a video conference room
 type Room struct {
 	ID           string
 	Type         ConnectionType
 	Participants map[string]*Participant
 	Broadcaster  *Participant // For broadcasting mode
+	Capacity     int          // Most participants a group room holds
+	HandQueue    []string     // Viewers waiting to be promoted, in order
+	Settings     RoomSettings
 	mutex        sync.RWMutex
-	ChatHistory  []ChatMessage
-}
+	chat         chatRing // Public messages, bounded by Settings.ChatHistorySize
+	chatSeq      int64
+	pinned       []string // IDs of pinned public messages, in pin order
+
+	directMessages map[string]*chatRing // By conversation, each bounded like public chat
+	attachments    map[string]Attachment
+	typing         map[string]typingMark  // Participants currently typing
+	readReceipts   map[string]ReadReceipt // Read watermarks by participant
+
+	BroadcastStatus BroadcastStatus // For broadcasting mode
+	pausedAt        time.Time
+	pausedToken     string // Token of the broadcaster who may resume a paused broadcast
+
+	Presenter  string   // Screen-share floor holder, for screen sharing mode
+	FloorQueue []string // Participants waiting for the floor, in order
+	spotlight  []Spotlight
+	pins       map[string][]Spotlight // Participant ID -> participants or tracks they pinned
 
-// ChatMessage represents a chat message in the room
-type ChatMessage struct {
-	SenderID   string `json:"sender_id"`
-	SenderName string `json:"sender_name"`
-	Content    string `json:"content"`
-	Timestamp  int64  `json:"timestamp"`
+	waitQueue []*Participant // Participants waiting for a free slot, in order
+
+	metadata  map[string]string // Key-value metadata such as the topic or agenda
+	polls     []*Poll           // In creation order
+	questions []*Question       // Q&A, in the order asked
+
+	ParentID            string         // Set on breakout rooms
+	breakouts           []BreakoutRoom // Open breakout rooms of this room
+	breakoutAssignments map[string]string
+	breakoutClosesAt    time.Time
 }
 
-// NewRoom creates a new room instance
+// NewRoom creates a new room instance with the default settings
 func NewRoom(id string, roomType ConnectionType) *Room {
-	return &Room{
+	return NewRoomWithSettings(id, roomType, DefaultRoomSettings())
+}
+
+// NewRoomWithSettings creates a new room instance with the given settings
+func NewRoomWithSettings(id string, roomType ConnectionType, settings RoomSettings) *Room {
+	room := &Room{
 		ID:           id,
 		Type:         roomType,
 		Participants: make(map[string]*Participant),
-		ChatHistory:  make([]ChatMessage, 0),
+		HandQueue:    make([]string, 0),
+		Settings:     settings,
+		chat:         chatRing{size: settings.ChatHistorySize},
+
+		directMessages: make(map[string]*chatRing),
+		attachments:    make(map[string]Attachment),
+		typing:         make(map[string]typingMark),
+		readReceipts:   make(map[string]ReadReceipt),
+
+		BroadcastStatus: BroadcastIdle,
+		FloorQueue:      make([]string, 0),
 	}
+	if roomType == Group {
+		room.Capacity = settings.GroupCapacity
+	}
+	return room
 }
 
 // AddParticipant adds a new participant to the room
@@ -47,22 +81,78 @@ func (r *Room) AddParticipant(p *Participant) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	if r.isFull() || len(r.waitQueue) > 0 {
+		return ErrRoomFull
+	}
+	if r.Type == Broadcasting && p.ConnectionInfo.IsBroadcaster {
+		if err := r.checkBroadcasterJoin(p); err != nil {
+			return err
+		}
+	}
+	r.addParticipant(p, r.initialRole(p))
+	return nil
+}
+
+// isFull reports whether every publishing slot of the room is taken.
+// Overflow viewers don't take a slot. Callers must hold the lock.
+func (r *Room) isFull() bool {
+	limit := 0
 	switch r.Type {
 	case OneToOne:
-		if len(r.Participants) >= 2 {
-			return ErrRoomFull
-		}
-	case Broadcasting:
-		if p.ConnectionInfo.IsBroadcaster {
-			if r.Broadcaster != nil {
-				return ErrBroadcasterExists
-			}
-			r.Broadcaster = p
+		limit = 2
+	case Group:
+		limit = r.Capacity
+	}
+	if limit <= 0 {
+		return false
+	}
+	members := 0
+	for _, p := range r.Participants {
+		if p.Role != RoleViewer {
+			members++
 		}
 	}
+	return members >= limit
+}
 
+// addParticipant adds a participant with the given role. Callers must hold the lock.
+func (r *Room) addParticipant(p *Participant, role Role) {
+	p.Role = role
+	if p.Role == RoleBroadcaster {
+		r.makeBroadcaster(p)
+	}
+	if !p.Role.CanPublish() {
+		p.Media = MediaState{}
+	}
+	if r.Type == ScreenSharing && p.Media.Screen {
+		// Joining as a sharer claims the floor only if it is free
+		if r.Presenter == "" {
+			r.Presenter = p.ID
+		} else {
+			p.Media.Screen = false
+			p.ConnectionInfo.IsScreenShare = false
+		}
+	}
+	if p.JoinedAt.IsZero() {
+		p.JoinedAt = time.Now()
+	}
 	r.Participants[p.ID] = p
-	return nil
+}
+
+// initialRole picks the role of a joining participant. Callers must hold the lock.
+func (r *Room) initialRole(p *Participant) Role {
+	if r.Type == Broadcasting {
+		if p.ConnectionInfo.IsBroadcaster {
+			return RoleBroadcaster
+		}
+		return RoleViewer
+	}
+	for _, other := range r.Participants {
+		if other.Role == RoleHost {
+			return RoleParticipant
+		}
+	}
+	return RoleHost
 }
 
 // RemoveParticipant removes a participant from the room
@@ -71,10 +161,36 @@ func (r *Room) RemoveParticipant(participantID string) {
 	defer r.mutex.Unlock()
 
 	if p := r.Participants[participantID]; p != nil {
+		delete(r.Participants, participantID)
 		if r.Broadcaster != nil && r.Broadcaster.ID == participantID {
-			r.Broadcaster = nil
+			r.handleBroadcasterLeft()
+		}
+		r.lowerHand(participantID)
+		r.releaseFloor(participantID)
+		r.removeFromSpotlight(participantID)
+		r.removePins(participantID)
+		r.clearTyping(participantID)
+		delete(r.readReceipts, participantID)
+		if p.Role == RoleHost {
+			r.promoteNextHost()
+		}
+	}
+}
+
+// promoteNextHost hands the host role to the longest-present participant.
+// Callers must hold the lock.
+func (r *Room) promoteNextHost() {
+	var next *Participant
+	for _, p := range r.Participants {
+		if p.Role != RoleParticipant {
+			continue
+		}
+		if next == nil || p.JoinedAt.Before(next.JoinedAt) {
+			next = p
 		}
-		delete(r.Participants, participantID)
+	}
+	if next != nil {
+		next.Role = RoleHost
 	}
 }
 
@@ -85,6 +201,48 @@ func (r *Room) GetParticipant(participantID string) *Participant {
 	return r.Participants[participantID]
 }
 
+// GetParticipantByToken gets a participant by its access token
+func (r *Room) GetParticipantByToken(token string) *Participant {
+	if token == "" {
+		return nil
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, p := range r.Participants {
+		if p.Token == token {
+			return p
+		}
+	}
+	return nil
+}
+
+// IsEmpty reports whether nobody is in the room
+func (r *Room) IsEmpty() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.Participants) == 0
+}
+
+// ParticipantCount returns the number of participants in the room
+func (r *Room) ParticipantCount() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.Participants)
+}
+
+// MeshPeers returns the IDs of everyone in the room but the given
+// participant, in join order. In a group room a newcomer offers to each of
+// them, so that every pair negotiates exactly once.
+func (r *Room) MeshPeers(participantID string) []string {
+	peers := make([]string, 0)
+	for _, view := range r.GetParticipantViews() {
+		if view.ID != participantID {
+			peers = append(peers, view.ID)
+		}
+	}
+	return peers
+}
+
 // GetParticipants returns all participants in the room
 func (r *Room) GetParticipants() []*Participant {
 	r.mutex.RLock()
@@ -96,16 +254,95 @@ func (r *Room) GetParticipants() []*Participant {
 	return participants
 }
 
-// AddChatMessage adds a new chat message to the room history
-func (r *Room) AddChatMessage(message ChatMessage) {
+// UpdateMediaState applies a media state update to a participant and
+// returns the fields that actually changed
+func (r *Room) UpdateMediaState(participantID string, update MediaStateUpdate) (MediaStateUpdate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	p, ok := r.Participants[participantID]
+	if !ok {
+		return MediaStateUpdate{}, ErrParticipantNotFound
+	}
+	if !p.Role.CanPublish() && update.enables() {
+		return MediaStateUpdate{}, ErrPermissionDenied
+	}
+	if r.Type == ScreenSharing && update.Screen != nil && *update.Screen && r.Presenter != participantID {
+		return MediaStateUpdate{}, ErrNotPresenter
+	}
+
+	delta := p.Media.apply(update)
+	if p.ConnectionInfo != nil {
+		p.ConnectionInfo.IsScreenShare = p.Media.Screen
+	}
+	return delta, nil
+}
+
+// MuteParticipant turns off another participant's microphone and returns the
+// fields that changed. Only moderators may mute others.
+func (r *Room) MuteParticipant(actorID, targetID string) (MediaStateUpdate, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	r.ChatHistory = append(r.ChatHistory, message)
+
+	if !r.canModerate(actorID) {
+		return MediaStateUpdate{}, ErrPermissionDenied
+	}
+	target, ok := r.Participants[targetID]
+	if !ok {
+		return MediaStateUpdate{}, ErrParticipantNotFound
+	}
+	muted := false
+	return target.Media.apply(MediaStateUpdate{Audio: &muted}), nil
 }
 
-// GetChatHistory returns the chat history
-func (r *Room) GetChatHistory() []ChatMessage {
+// FindParticipant looks up a participant by ID or, case-insensitively, by
+// display name. A name shared by several participants is ambiguous.
+func (r *Room) FindParticipant(ref string) (*Participant, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	return r.ChatHistory
+	if p, ok := r.Participants[ref]; ok {
+		return p, nil
+	}
+	var found *Participant
+	for _, p := range r.Participants {
+		if !strings.EqualFold(p.Username, ref) {
+			continue
+		}
+		if found != nil {
+			return nil, ErrAmbiguousParticipant
+		}
+		found = p
+	}
+	if found == nil {
+		return nil, ErrParticipantNotFound
+	}
+	return found, nil
+}
+
+// GetParticipantView returns the public view of a participant
+func (r *Room) GetParticipantView(participantID string) (ParticipantView, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	p, ok := r.Participants[participantID]
+	if !ok {
+		return ParticipantView{}, false
+	}
+	return p.View(), true
+}
+
+// GetParticipantViews returns the public views of all participants ordered by join time
+func (r *Room) GetParticipantViews() []ParticipantView {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	views := make([]ParticipantView, 0, len(r.Participants))
+	for _, p := range r.Participants {
+		views = append(views, p.View())
+	}
+	sort.SliceStable(views, func(i, j int) bool {
+		if views[i].JoinedAt != views[j].JoinedAt {
+			return views[i].JoinedAt < views[j].JoinedAt
+		}
+		return views[i].ID < views[j].ID
+	})
+	return views
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -98,6 +100,32 @@ func TestAddParticipant(t *testing.T) {
 	}
 }
 
+func TestGroupRoomCapacity(t *testing.T) {
+	settings := DefaultRoomSettings()
+	settings.GroupCapacity = 3
+	room := NewRoomWithSettings("group", Group, settings)
+	if room.Capacity != 3 {
+		t.Fatalf("Expected the default capacity, got %d", room.Capacity)
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := room.AddParticipant(&Participant{ID: id, ConnectionInfo: &ConnectionInfo{Type: Group}}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if err := room.AddParticipant(&Participant{ID: "d", ConnectionInfo: &ConnectionInfo{Type: Group}}); err != ErrRoomFull {
+		t.Errorf("Expected %v, got %v", ErrRoomFull, err)
+	}
+	if peers := room.MeshPeers("c"); len(peers) != 2 {
+		t.Errorf("Expected the newcomer to offer to everyone else, got %v", peers)
+	}
+
+	room.RemoveParticipant("a")
+	if err := room.AddParticipant(&Participant{ID: "d", ConnectionInfo: &ConnectionInfo{Type: Group}}); err != nil {
+		t.Errorf("Expected a free slot after a leave, got %v", err)
+	}
+}
+
 func TestRemoveParticipant(t *testing.T) {
 	room := NewRoom("test-room", Broadcasting)
 	broadcaster := &Participant{
@@ -152,3 +180,114 @@ func TestChatMessages(t *testing.T) {
 		t.Errorf("Expected message content %s, got %s", message.Content, history[0].Content)
 	}
 }
+
+func TestParticipantRoles(t *testing.T) {
+	room := NewRoom("test-room", OneToOne)
+	first := &Participant{ID: "first", ConnectionInfo: &ConnectionInfo{Type: OneToOne}}
+	second := &Participant{ID: "second", ConnectionInfo: &ConnectionInfo{Type: OneToOne}}
+
+	room.AddParticipant(first)
+	room.AddParticipant(second)
+
+	if first.Role != RoleHost {
+		t.Errorf("Expected first participant to be host, got %s", first.Role)
+	}
+	if second.Role != RoleParticipant {
+		t.Errorf("Expected second participant to be participant, got %s", second.Role)
+	}
+
+	room.RemoveParticipant(first.ID)
+	if second.Role != RoleHost {
+		t.Errorf("Expected host role to pass to remaining participant, got %s", second.Role)
+	}
+
+	broadcastRoom := NewRoom("broadcast-room", Broadcasting)
+	broadcaster := &Participant{ID: "b", ConnectionInfo: &ConnectionInfo{Type: Broadcasting, IsBroadcaster: true}}
+	viewer := &Participant{ID: "v", ConnectionInfo: &ConnectionInfo{Type: Broadcasting}}
+	broadcastRoom.AddParticipant(viewer)
+	broadcastRoom.AddParticipant(broadcaster)
+
+	if broadcaster.Role != RoleBroadcaster || viewer.Role != RoleViewer {
+		t.Errorf("Expected broadcaster/viewer roles, got %s/%s", broadcaster.Role, viewer.Role)
+	}
+}
+
+func TestParticipantViews(t *testing.T) {
+	room := NewRoom("test-room", OneToOne)
+	p := &Participant{
+		ID:             "1",
+		Username:       "User 1",
+		ConnectionInfo: &ConnectionInfo{Type: OneToOne},
+		Media:          MediaState{Audio: true},
+		Attributes:     map[string]string{"lang": "en"},
+	}
+	room.AddParticipant(p)
+
+	view, ok := room.GetParticipantView("1")
+	if !ok {
+		t.Fatal("Expected participant view")
+	}
+	if view.DisplayName != "User 1" || view.Role != RoleHost || !view.Media.Audio {
+		t.Errorf("Unexpected view %+v", view)
+	}
+
+	view.Attributes["lang"] = "fr"
+	if p.Attributes["lang"] != "en" {
+		t.Error("Expected view attributes to be a copy")
+	}
+
+	data, err := json.Marshal(room.GetParticipantViews())
+	if err != nil {
+		t.Fatalf("Failed to marshal views: %v", err)
+	}
+	if strings.Contains(string(data), "Conn") || strings.Contains(string(data), "ConnectionInfo") {
+		t.Errorf("Expected views to hide server-side fields, got %s", data)
+	}
+}
+
+func TestUpdateMediaState(t *testing.T) {
+	room := NewRoom("test-room", OneToOne)
+	p := &Participant{
+		ID:             "1",
+		ConnectionInfo: &ConnectionInfo{Type: OneToOne},
+		Media:          MediaState{Audio: true, Video: true},
+	}
+	room.AddParticipant(p)
+
+	off, on := false, true
+	delta, err := room.UpdateMediaState("1", MediaStateUpdate{Audio: &off, Video: &on, Screen: &on})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if delta.Audio == nil || *delta.Audio || delta.Video != nil || delta.Screen == nil {
+		t.Errorf("Expected delta with audio and screen only, got %+v", delta)
+	}
+	if p.Media.Audio || !p.Media.Screen || !p.ConnectionInfo.IsScreenShare {
+		t.Errorf("Expected stored media state to be updated, got %+v", p.Media)
+	}
+
+	if _, err := room.UpdateMediaState("missing", MediaStateUpdate{Audio: &on}); err != ErrParticipantNotFound {
+		t.Errorf("Expected %v, got %v", ErrParticipantNotFound, err)
+	}
+}
+
+func TestFindParticipant(t *testing.T) {
+	room := NewRoom("test-room", Group)
+	room.AddParticipant(&Participant{ID: "1", Username: "Alice", ConnectionInfo: &ConnectionInfo{Type: Group}})
+	room.AddParticipant(&Participant{ID: "2", Username: "Bob", ConnectionInfo: &ConnectionInfo{Type: Group}})
+
+	if p, err := room.FindParticipant("alice"); err != nil || p.ID != "1" {
+		t.Errorf("Expected to find Alice by name, got %v, %v", p, err)
+	}
+	if _, err := room.FindParticipant("carol"); err != ErrParticipantNotFound {
+		t.Errorf("Expected %v, got %v", ErrParticipantNotFound, err)
+	}
+
+	room.AddParticipant(&Participant{ID: "3", Username: "ALICE", ConnectionInfo: &ConnectionInfo{Type: Group}})
+	if _, err := room.FindParticipant("alice"); err != ErrAmbiguousParticipant {
+		t.Errorf("Expected %v, got %v", ErrAmbiguousParticipant, err)
+	}
+	if p, err := room.FindParticipant("3"); err != nil || p.ID != "3" {
+		t.Errorf("Expected to find a participant by ID whatever the name, got %v, %v", p, err)
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+const (
+	// DefaultStageSlots is the number of viewers that can be promoted to the stage at once
+	DefaultStageSlots = 3
+	// DefaultBroadcasterGracePeriod is how long a paused broadcast waits for its broadcaster
+	DefaultBroadcasterGracePeriod = 30 * time.Second
+	// DefaultChatHistorySize is the number of public chat messages a room keeps
+	DefaultChatHistorySize = 500
+	// DefaultChatJoinHistory is the number of recent chat messages sent to a joining participant
+	DefaultChatJoinHistory = 50
+	// DefaultTypingTimeout is how long a typing indicator lasts without being refreshed
+	DefaultTypingTimeout = 5 * time.Second
+	// DefaultGroupCapacity is the number of participants a group room holds when none is given
+	DefaultGroupCapacity = 8
+)
+
+// RoomSettings holds the tunable limits of a room
+type RoomSettings struct {
+	// StageSlots is the number of viewers that can share the stage with the
+	// broadcaster in a broadcasting room
+	StageSlots int
+	// BroadcasterGracePeriod is how long a broadcast stays paused after the
+	// broadcaster disconnects before it is ended
+	BroadcasterGracePeriod time.Duration
+	// ChatHistorySize bounds the public chat history; older messages are dropped
+	ChatHistorySize int
+	// ChatJoinHistory is the number of recent messages included in room_info
+	ChatJoinHistory int
+	// TypingTimeout is how long a participant shows as typing after their
+	// last typing event
+	TypingTimeout time.Duration
+	// GroupCapacity is the number of participants a group room holds unless
+	// the room is created with its own capacity
+	GroupCapacity int
+	// Overflow decides what happens to participants joining a full room
+	Overflow OverflowPolicy
+}
+
+// DefaultRoomSettings returns the settings used when none are configured
+func DefaultRoomSettings() RoomSettings {
+	return RoomSettings{
+		StageSlots:             DefaultStageSlots,
+		BroadcasterGracePeriod: DefaultBroadcasterGracePeriod,
+		ChatHistorySize:        DefaultChatHistorySize,
+		ChatJoinHistory:        DefaultChatJoinHistory,
+		TypingTimeout:          DefaultTypingTimeout,
+		GroupCapacity:          DefaultGroupCapacity,
+		Overflow:               OverflowReject,
+	}
+}
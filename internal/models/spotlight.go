@@ -0,0 +1,134 @@
+package models
+
+// MaxSpotlight is the most participants or tracks that can share the spotlight
+const MaxSpotlight = 4
+
+// MaxPinned is the most participants or tracks a participant can pin
+const MaxPinned = 4
+
+// Spotlight puts a participant, or one of their tracks, in focus for everyone
+type Spotlight struct {
+	ParticipantID string `json:"participantId"`
+	TrackID       string `json:"trackId,omitempty"` // Empty spotlights every track of the participant
+}
+
+// SetSpotlight replaces the room's spotlight; an empty list clears it. Only
+// moderators may set it, and only on participants in the room.
+func (r *Room) SetSpotlight(actorID string, spotlight []Spotlight) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.canModerate(actorID) {
+		return ErrPermissionDenied
+	}
+	if len(spotlight) > MaxSpotlight {
+		return ErrInvalidSpotlight
+	}
+	seen := make(map[Spotlight]bool, len(spotlight))
+	entries := make([]Spotlight, 0, len(spotlight))
+	for _, s := range spotlight {
+		if _, ok := r.Participants[s.ParticipantID]; !ok {
+			return ErrParticipantNotFound
+		}
+		if !seen[s] {
+			seen[s] = true
+			entries = append(entries, s)
+		}
+	}
+	r.spotlight = entries
+	return nil
+}
+
+// GetSpotlight returns the room's spotlight, in the order it was set
+func (r *Room) GetSpotlight() []Spotlight {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]Spotlight{}, r.spotlight...)
+}
+
+// InSpotlight reports whether a participant or any of their tracks is spotlighted
+func (r *Room) InSpotlight(participantID string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, s := range r.spotlight {
+		if s.ParticipantID == participantID {
+			return true
+		}
+	}
+	return false
+}
+
+// removeFromSpotlight drops a participant from the spotlight. Callers must hold the lock.
+func (r *Room) removeFromSpotlight(participantID string) {
+	entries := r.spotlight[:0]
+	for _, s := range r.spotlight {
+		if s.ParticipantID != participantID {
+			entries = append(entries, s)
+		}
+	}
+	r.spotlight = entries
+}
+
+// SetPinned replaces the participants or tracks a participant always wants to
+// see, whoever is speaking; an empty list clears them. Pins must be on other
+// participants in the room. It returns the pins as stored.
+func (r *Room) SetPinned(participantID string, pins []Spotlight) ([]Spotlight, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.Participants[participantID]; !ok {
+		return nil, ErrParticipantNotFound
+	}
+	if len(pins) > MaxPinned {
+		return nil, ErrInvalidPin
+	}
+	seen := make(map[Spotlight]bool, len(pins))
+	entries := make([]Spotlight, 0, len(pins))
+	for _, pin := range pins {
+		if pin.ParticipantID == participantID {
+			return nil, ErrInvalidPin
+		}
+		if _, ok := r.Participants[pin.ParticipantID]; !ok {
+			return nil, ErrParticipantNotFound
+		}
+		if !seen[pin] {
+			seen[pin] = true
+			entries = append(entries, pin)
+		}
+	}
+	if r.pins == nil {
+		r.pins = make(map[string][]Spotlight)
+	}
+	if len(entries) == 0 {
+		delete(r.pins, participantID)
+	} else {
+		r.pins[participantID] = entries
+	}
+	return append([]Spotlight{}, entries...), nil
+}
+
+// GetPinned returns a participant's pins, in the order they were set
+func (r *Room) GetPinned(participantID string) []Spotlight {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]Spotlight{}, r.pins[participantID]...)
+}
+
+// removePins drops a participant's own pins and their pins by others.
+// Callers must hold the lock.
+func (r *Room) removePins(participantID string) {
+	delete(r.pins, participantID)
+	for id, pins := range r.pins {
+		kept := pins[:0]
+		for _, pin := range pins {
+			if pin.ParticipantID != participantID {
+				kept = append(kept, pin)
+			}
+		}
+		if len(kept) == 0 {
+			delete(r.pins, id)
+		} else {
+			r.pins[id] = kept
+		}
+	}
+}
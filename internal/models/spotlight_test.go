@@ -0,0 +1,65 @@
+package models
+
+import "testing"
+
+func TestSpotlight(t *testing.T) {
+	room := newChatRoom(t)
+
+	if err := room.SetSpotlight("guest", []Spotlight{{ParticipantID: "guest"}}); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	if err := room.SetSpotlight("host", []Spotlight{{ParticipantID: "missing"}}); err != ErrParticipantNotFound {
+		t.Errorf("Expected %v, got %v", ErrParticipantNotFound, err)
+	}
+	tooMany := make([]Spotlight, MaxSpotlight+1)
+	if err := room.SetSpotlight("host", tooMany); err != ErrInvalidSpotlight {
+		t.Errorf("Expected %v, got %v", ErrInvalidSpotlight, err)
+	}
+
+	err := room.SetSpotlight("host", []Spotlight{
+		{ParticipantID: "guest", TrackID: "screen"},
+		{ParticipantID: "host"},
+		{ParticipantID: "guest", TrackID: "screen"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if spotlight := room.GetSpotlight(); len(spotlight) != 2 || spotlight[0].TrackID != "screen" {
+		t.Errorf("Expected duplicates dropped in order, got %v", spotlight)
+	}
+
+	room.RemoveParticipant("guest")
+	if room.InSpotlight("guest") || !room.InSpotlight("host") {
+		t.Errorf("Expected only the leaving participant dropped, got %v", room.GetSpotlight())
+	}
+}
+
+func TestPinned(t *testing.T) {
+	room := newChatRoom(t)
+
+	if _, err := room.SetPinned("host", []Spotlight{{ParticipantID: "host"}}); err != ErrInvalidPin {
+		t.Errorf("Expected %v, got %v", ErrInvalidPin, err)
+	}
+	if _, err := room.SetPinned("host", []Spotlight{{ParticipantID: "missing"}}); err != ErrParticipantNotFound {
+		t.Errorf("Expected %v, got %v", ErrParticipantNotFound, err)
+	}
+	if _, err := room.SetPinned("host", make([]Spotlight, MaxPinned+1)); err != ErrInvalidPin {
+		t.Errorf("Expected %v, got %v", ErrInvalidPin, err)
+	}
+
+	pins, err := room.SetPinned("host", []Spotlight{{ParticipantID: "guest"}, {ParticipantID: "guest"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(pins) != 1 || len(room.GetPinned("host")) != 1 {
+		t.Errorf("Expected duplicates dropped, got %v", pins)
+	}
+	if pinned := room.GetPinned("guest"); len(pinned) != 0 {
+		t.Errorf("Expected pins to be per participant, got %v", pinned)
+	}
+
+	room.RemoveParticipant("guest")
+	if pinned := room.GetPinned("host"); len(pinned) != 0 {
+		t.Errorf("Expected the leaving participant unpinned, got %v", pinned)
+	}
+}
@@ -0,0 +1,122 @@
+package models
+
+// RaiseHand adds a viewer to the end of the hand queue and returns its
+// 1-based position. Raising an already raised hand keeps its position.
+func (r *Room) RaiseHand(participantID string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	p, ok := r.Participants[participantID]
+	if !ok {
+		return 0, ErrParticipantNotFound
+	}
+	if p.Role != RoleViewer {
+		return 0, ErrInvalidRole
+	}
+
+	for i, id := range r.HandQueue {
+		if id == participantID {
+			return i + 1, nil
+		}
+	}
+	r.HandQueue = append(r.HandQueue, participantID)
+	return len(r.HandQueue), nil
+}
+
+// LowerHand removes a participant from the hand queue and reports whether it was queued
+func (r *Room) LowerHand(participantID string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.lowerHand(participantID)
+}
+
+// lowerHand removes a participant from the hand queue. Callers must hold the lock.
+func (r *Room) lowerHand(participantID string) bool {
+	for i, id := range r.HandQueue {
+		if id == participantID {
+			r.HandQueue = append(r.HandQueue[:i], r.HandQueue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// GetHandQueue returns the IDs of participants with a raised hand, in order
+func (r *Room) GetHandQueue() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	queue := make([]string, len(r.HandQueue))
+	copy(queue, r.HandQueue)
+	return queue
+}
+
+// PromoteToStage gives a viewer publish rights on the stage. Only moderators
+// may promote, and only while a stage slot is free.
+func (r *Room) PromoteToStage(actorID, targetID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	actor, ok := r.Participants[actorID]
+	if !ok {
+		return ErrParticipantNotFound
+	}
+	if !actor.Role.CanModerate() {
+		return ErrPermissionDenied
+	}
+
+	target, ok := r.Participants[targetID]
+	if !ok {
+		return ErrParticipantNotFound
+	}
+	if target.Role != RoleViewer {
+		return ErrInvalidRole
+	}
+	if r.stageCount() >= r.Settings.StageSlots {
+		return ErrStageFull
+	}
+
+	target.Role = RoleStage
+	r.lowerHand(targetID)
+	return nil
+}
+
+// DemoteFromStage returns a stage participant to the audience and turns off
+// its media. Moderators may demote anyone; participants may step down themselves.
+func (r *Room) DemoteFromStage(actorID, targetID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	actor, ok := r.Participants[actorID]
+	if !ok {
+		return ErrParticipantNotFound
+	}
+	if actorID != targetID && !actor.Role.CanModerate() {
+		return ErrPermissionDenied
+	}
+
+	target, ok := r.Participants[targetID]
+	if !ok {
+		return ErrParticipantNotFound
+	}
+	if target.Role != RoleStage {
+		return ErrInvalidRole
+	}
+
+	target.Role = RoleViewer
+	target.Media = MediaState{}
+	if target.ConnectionInfo != nil {
+		target.ConnectionInfo.IsScreenShare = false
+	}
+	return nil
+}
+
+// stageCount returns the number of promoted participants. Callers must hold the lock.
+func (r *Room) stageCount() int {
+	count := 0
+	for _, p := range r.Participants {
+		if p.Role == RoleStage {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"testing"
+)
+
+func newBroadcastRoom(t *testing.T, slots int, viewers ...string) *Room {
+	t.Helper()
+	room := NewRoomWithSettings("test-room", Broadcasting, RoomSettings{StageSlots: slots})
+	room.AddParticipant(&Participant{
+		ID:             "broadcaster",
+		Token:          "broadcaster-token",
+		ConnectionInfo: &ConnectionInfo{Type: Broadcasting, IsBroadcaster: true},
+	})
+	for _, id := range viewers {
+		room.AddParticipant(&Participant{
+			ID:             id,
+			ConnectionInfo: &ConnectionInfo{Type: Broadcasting},
+			Media:          MediaState{Audio: true, Video: true},
+		})
+	}
+	return room
+}
+
+func TestHandQueue(t *testing.T) {
+	room := newBroadcastRoom(t, 1, "v1", "v2")
+
+	if pos, err := room.RaiseHand("v2"); err != nil || pos != 1 {
+		t.Errorf("Expected position 1, got %d (%v)", pos, err)
+	}
+	if pos, err := room.RaiseHand("v1"); err != nil || pos != 2 {
+		t.Errorf("Expected position 2, got %d (%v)", pos, err)
+	}
+	if pos, _ := room.RaiseHand("v2"); pos != 1 {
+		t.Errorf("Expected raising twice to keep position 1, got %d", pos)
+	}
+	if _, err := room.RaiseHand("broadcaster"); err != ErrInvalidRole {
+		t.Errorf("Expected %v, got %v", ErrInvalidRole, err)
+	}
+
+	room.RemoveParticipant("v2")
+	queue := room.GetHandQueue()
+	if len(queue) != 1 || queue[0] != "v1" {
+		t.Errorf("Expected queue [v1], got %v", queue)
+	}
+
+	if !room.LowerHand("v1") || room.LowerHand("v1") {
+		t.Error("Expected hand to be lowered exactly once")
+	}
+}
+
+func TestStagePromotion(t *testing.T) {
+	room := newBroadcastRoom(t, 1, "v1", "v2")
+
+	if room.GetParticipant("v1").Media.Audio {
+		t.Error("Expected viewers to join with media off")
+	}
+
+	room.RaiseHand("v1")
+	if err := room.PromoteToStage("v2", "v1"); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	if err := room.PromoteToStage("broadcaster", "v1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if room.GetParticipant("v1").Role != RoleStage {
+		t.Error("Expected v1 to be on stage")
+	}
+	if len(room.GetHandQueue()) != 0 {
+		t.Error("Expected promotion to lower the hand")
+	}
+	if err := room.PromoteToStage("broadcaster", "v2"); err != ErrStageFull {
+		t.Errorf("Expected %v, got %v", ErrStageFull, err)
+	}
+
+	on := true
+	if _, err := room.UpdateMediaState("v1", MediaStateUpdate{Audio: &on}); err != nil {
+		t.Errorf("Expected stage participant to publish, got %v", err)
+	}
+	if _, err := room.UpdateMediaState("v2", MediaStateUpdate{Audio: &on}); err != ErrPermissionDenied {
+		t.Errorf("Expected viewer publish to be denied, got %v", err)
+	}
+
+	if err := room.DemoteFromStage("v2", "v1"); err != ErrPermissionDenied {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+	if err := room.DemoteFromStage("v1", "v1"); err != nil {
+		t.Fatalf("Expected self demotion to succeed, got %v", err)
+	}
+	p := room.GetParticipant("v1")
+	if p.Role != RoleViewer || p.Media.Audio {
+		t.Errorf("Expected demoted viewer with media off, got %s %+v", p.Role, p.Media)
+	}
+}
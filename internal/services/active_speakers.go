@@ -0,0 +1,121 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultActiveSpeakerInterval is how often active speakers are reported
+	DefaultActiveSpeakerInterval = 500 * time.Millisecond
+	// speakerSmoothing is the weight of the newest interval in a speaker's smoothed level
+	speakerSmoothing = 0.4
+	// speakerThreshold is the smoothed level above which a participant counts as speaking
+	speakerThreshold = 127 - 60 // -60 dBov
+	// dominantMargin is how much louder a speaker must be to take over as dominant speaker
+	dominantMargin = 6
+)
+
+// ActiveSpeaker is a participant currently speaking in a room
+type ActiveSpeaker struct {
+	ParticipantID string `json:"participantId"`
+	Level         int    `json:"level"` // Smoothed loudness from 0 (silence, -127 dBov) to 127 (0 dBov)
+}
+
+// ActiveSpeakersFunc receives the active speakers of a room, dominant speaker first
+type ActiveSpeakersFunc func(roomID string, speakers []ActiveSpeaker)
+
+// speakerTracker smooths the audio levels of a room's participants and
+// picks its dominant speaker
+type speakerTracker struct {
+	mutex    sync.Mutex
+	samples  map[string][]int   // Participant ID -> levels received this interval
+	smoothed map[string]float64 // Participant ID -> smoothed level
+	dominant string
+	active   bool // Whether the last report had speakers
+}
+
+func newSpeakerTracker() *speakerTracker {
+	return &speakerTracker{
+		samples:  make(map[string][]int),
+		smoothed: make(map[string]float64),
+	}
+}
+
+// observe records the RFC 6464 audio level of a packet, in -dBov
+func (t *speakerTracker) observe(participantID string, dBov uint8) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.samples[participantID] = append(t.samples[participantID], 127-int(dBov&0x7f))
+}
+
+// remove forgets a participant that left
+func (t *speakerTracker) remove(participantID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.samples, participantID)
+	delete(t.smoothed, participantID)
+	if t.dominant == participantID {
+		t.dominant = ""
+	}
+}
+
+// tick folds the levels of the past interval into the smoothed levels and
+// returns the active speakers, dominant speaker first. It reports false when
+// there is nothing new to tell: nobody spoke now or in the last report.
+func (t *speakerTracker) tick() ([]ActiveSpeaker, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for participantID, level := range t.smoothed {
+		if _, ok := t.samples[participantID]; ok {
+			continue
+		}
+		if level *= 1 - speakerSmoothing; level < 1 {
+			delete(t.smoothed, participantID)
+		} else {
+			t.smoothed[participantID] = level
+		}
+	}
+	for participantID, levels := range t.samples {
+		sum := 0
+		for _, level := range levels {
+			sum += level
+		}
+		mean := float64(sum) / float64(len(levels))
+		t.smoothed[participantID] = speakerSmoothing*mean + (1-speakerSmoothing)*t.smoothed[participantID]
+	}
+	t.samples = make(map[string][]int)
+
+	speakers := make([]ActiveSpeaker, 0)
+	for participantID, level := range t.smoothed {
+		if level > speakerThreshold {
+			speakers = append(speakers, ActiveSpeaker{ParticipantID: participantID, Level: int(level + 0.5)})
+		}
+	}
+	sort.Slice(speakers, func(i, j int) bool {
+		if speakers[i].Level != speakers[j].Level {
+			return speakers[i].Level > speakers[j].Level
+		}
+		return speakers[i].ParticipantID < speakers[j].ParticipantID
+	})
+
+	// The dominant speaker keeps the floor until someone is clearly louder
+	if len(speakers) > 0 {
+		for i, s := range speakers {
+			if s.ParticipantID == t.dominant && speakers[0].Level-s.Level < dominantMargin {
+				copy(speakers[1:i+1], speakers[:i])
+				speakers[0] = s
+				break
+			}
+		}
+		t.dominant = speakers[0].ParticipantID
+	} else {
+		t.dominant = ""
+	}
+
+	report := len(speakers) > 0 || t.active
+	t.active = len(speakers) > 0
+	return speakers, report
+}
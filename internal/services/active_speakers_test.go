@@ -0,0 +1,130 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+func TestSpeakerTrackerDominantSpeaker(t *testing.T) {
+	tracker := newSpeakerTracker()
+
+	if _, report := tracker.tick(); report {
+		t.Error("Expected no report for a silent room")
+	}
+
+	// Alice speaks loudly, Bob quietly, Carol below the threshold
+	var speakers []ActiveSpeaker
+	var report bool
+	for i := 0; i < 5; i++ {
+		tracker.observe("alice", 20)
+		tracker.observe("bob", 30)
+		tracker.observe("carol", 100)
+		speakers, report = tracker.tick()
+	}
+	if !report || len(speakers) != 2 || speakers[0].ParticipantID != "alice" || speakers[1].ParticipantID != "bob" {
+		t.Fatalf("Expected alice then bob, got %+v", speakers)
+	}
+	if speakers[0].Level <= speakers[1].Level {
+		t.Errorf("Expected levels in descending order, got %+v", speakers)
+	}
+
+	// Bob getting slightly louder doesn't take the floor from alice
+	tracker.observe("alice", 24)
+	tracker.observe("bob", 20)
+	if speakers, _ = tracker.tick(); speakers[0].ParticipantID != "alice" {
+		t.Errorf("Expected alice to stay dominant, got %+v", speakers)
+	}
+
+	// Once everyone falls silent, one last empty report follows
+	for i := 0; i < 20; i++ {
+		speakers, report = tracker.tick()
+	}
+	if report || len(speakers) != 0 {
+		t.Errorf("Expected silence to be reported only once, got %+v %v", speakers, report)
+	}
+}
+
+func TestSpeakerTrackerSilenceReport(t *testing.T) {
+	tracker := newSpeakerTracker()
+	tracker.observe("alice", 0)
+	if speakers, _ := tracker.tick(); len(speakers) != 0 {
+		t.Fatalf("Expected a single loud interval to be smoothed out, got %+v", speakers)
+	}
+	tracker.observe("alice", 0)
+	if speakers, report := tracker.tick(); !report || len(speakers) != 1 {
+		t.Fatalf("Expected alice to be speaking, got %+v", speakers)
+	}
+	tracker.remove("alice")
+	if speakers, report := tracker.tick(); !report || len(speakers) != 0 {
+		t.Errorf("Expected an empty report after the speaker left, got %+v %v", speakers, report)
+	}
+	if _, report := tracker.tick(); report {
+		t.Error("Expected no further reports")
+	}
+}
+
+func TestSFUActiveSpeakers(t *testing.T) {
+	s := NewSFUManager()
+	s.rooms["alice"] = "room"
+
+	reports := make(chan []ActiveSpeaker, 10)
+	s.OnActiveSpeakers(10*time.Millisecond, func(roomID string, speakers []ActiveSpeaker) {
+		if roomID == "room" {
+			reports <- speakers
+		}
+	})
+
+	level, err := rtp.AudioLevelExtension{Level: 10, Voice: true}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := &rtp.Packet{}
+	if err := packet.SetExtension(1, level); err != nil {
+		t.Fatal(err)
+	}
+	s.observeAudioLevel("bob", 1, packet) // Not in a room
+
+	timeout := time.After(time.Second)
+	for {
+		s.observeAudioLevel("alice", 1, packet)
+		s.observeAudioLevel("alice", 2, packet) // Not the negotiated extension
+		select {
+		case speakers := <-reports:
+			if len(speakers) != 1 || speakers[0].ParticipantID != "alice" {
+				t.Fatalf("Expected alice to be the active speaker, got %+v", speakers)
+			}
+			return
+		case <-time.After(5 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("Expected an active speakers report")
+		}
+	}
+}
+
+func TestSFUActiveSpeakersDefaultInterval(t *testing.T) {
+	s := NewSFUManager()
+	s.rooms["alice"] = "room"
+
+	reports := make(chan []ActiveSpeaker, 10)
+	s.OnActiveSpeakers(0, func(roomID string, speakers []ActiveSpeaker) {
+		reports <- speakers
+	})
+
+	level, _ := rtp.AudioLevelExtension{Level: 10, Voice: true}.Marshal()
+	packet := &rtp.Packet{}
+	packet.SetExtension(1, level)
+
+	timeout := time.After(3 * DefaultActiveSpeakerInterval)
+	for {
+		s.observeAudioLevel("alice", 1, packet)
+		select {
+		case <-reports:
+			return
+		case <-time.After(5 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("Expected a zero interval to fall back to the default")
+		}
+	}
+}
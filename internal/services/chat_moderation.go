@@ -0,0 +1,247 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultChatMaxLength is the maximum chat message length when none is configured
+const DefaultChatMaxLength = 2000
+
+// ModerationError is returned when a chat filter rejects a message.
+// Code is sent back to the sender so clients can explain the rejection.
+type ModerationError struct {
+	Code    string
+	Message string
+}
+
+func (e *ModerationError) Error() string {
+	return e.Message
+}
+
+// ChatFilter inspects a chat message before it is stored. It returns the
+// possibly rewritten content, or a *ModerationError to reject the message.
+type ChatFilter interface {
+	Filter(senderID, content string) (string, error)
+}
+
+// ChatFilterFunc adapts a function to the ChatFilter interface
+type ChatFilterFunc func(senderID, content string) (string, error)
+
+// Filter calls f(senderID, content)
+func (f ChatFilterFunc) Filter(senderID, content string) (string, error) {
+	return f(senderID, content)
+}
+
+// ChatModerator runs chat content through a chain of filters in order
+type ChatModerator struct {
+	filters []ChatFilter
+}
+
+// NewChatModerator creates a moderator running the given filters in order
+func NewChatModerator(filters ...ChatFilter) *ChatModerator {
+	return &ChatModerator{filters: filters}
+}
+
+// DefaultChatModerator returns the moderator used when none is configured:
+// sanitization and the default length cap
+func DefaultChatModerator() *ChatModerator {
+	return NewChatModerator(SanitizeFilter(), MaxLengthFilter(DefaultChatMaxLength))
+}
+
+// Moderate runs content through every filter and returns the final content.
+// Messages that end up empty are rejected.
+func (m *ChatModerator) Moderate(senderID, content string) (string, error) {
+	var err error
+	for _, f := range m.filters {
+		if content, err = f.Filter(senderID, content); err != nil {
+			return "", err
+		}
+	}
+	if strings.TrimSpace(content) == "" {
+		return "", &ModerationError{Code: "empty_message", Message: "message is empty"}
+	}
+	return content, nil
+}
+
+// ModerateParts runs the parts of one message, such as a poll's question and
+// options, through every filter. The parts count as a single message against
+// rate limits. Parts that end up empty are returned empty, not rejected.
+func (m *ChatModerator) ModerateParts(senderID string, parts []string) ([]string, error) {
+	moderated := make([]string, len(parts))
+	for i, content := range parts {
+		var err error
+		for _, f := range m.filters {
+			if _, limiter := f.(*rateLimitFilter); limiter && i > 0 {
+				continue
+			}
+			if content, err = f.Filter(senderID, content); err != nil {
+				return nil, err
+			}
+		}
+		moderated[i] = content
+	}
+	return moderated, nil
+}
+
+// htmlTagPattern matches HTML comments and anything a browser could parse
+// as a tag, whatever its attributes look like. A "<" followed by a space or
+// digit, as in "a < b" or "1<2", is left alone.
+var htmlTagPattern = regexp.MustCompile(`(?s)<!--.*?-->|<[A-Za-z/!][^>]*>`)
+
+// SanitizeFilter strips HTML tags and control characters other than newlines and tabs
+func SanitizeFilter() ChatFilter {
+	return ChatFilterFunc(func(_, content string) (string, error) {
+		content = htmlTagPattern.ReplaceAllString(content, "")
+		content = strings.Map(func(r rune) rune {
+			if r == utf8.RuneError || (unicode.IsControl(r) && r != '\n' && r != '\t') {
+				return -1
+			}
+			return r
+		}, content)
+		return strings.TrimSpace(content), nil
+	})
+}
+
+// MaxLengthFilter rejects messages longer than max characters. Zero or less disables it.
+func MaxLengthFilter(max int) ChatFilter {
+	return ChatFilterFunc(func(_, content string) (string, error) {
+		if max > 0 && utf8.RuneCountInString(content) > max {
+			return "", &ModerationError{Code: "message_too_long", Message: "message is too long"}
+		}
+		return content, nil
+	})
+}
+
+// BlocklistFilter masks blocked words, matched case-insensitively as whole words, with asterisks.
+// Letters and digits of any script count as word characters, so "darn" is
+// not masked inside "darné".
+func BlocklistFilter(words []string) ChatFilter {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return ChatFilterFunc(func(_, content string) (string, error) { return content, nil })
+	}
+
+	pattern := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	return ChatFilterFunc(func(_, content string) (string, error) {
+		var b strings.Builder
+		done := 0
+		for start := 0; start < len(content); {
+			loc := pattern.FindStringIndex(content[start:])
+			if loc == nil {
+				break
+			}
+			from, to := start+loc[0], start+loc[1]
+			if !isWholeWord(content, from, to) {
+				// Retry from the next character, a blocked word may start inside this match
+				_, size := utf8.DecodeRuneInString(content[from:])
+				start = from + size
+				continue
+			}
+			b.WriteString(content[done:from])
+			b.WriteString(strings.Repeat("*", utf8.RuneCountInString(content[from:to])))
+			done, start = to, to
+		}
+		b.WriteString(content[done:])
+		return b.String(), nil
+	})
+}
+
+// isWholeWord reports whether content[from:to] is not preceded or followed by a word character
+func isWholeWord(content string, from, to int) bool {
+	before, _ := utf8.DecodeLastRuneInString(content[:from])
+	after, _ := utf8.DecodeRuneInString(content[to:])
+	return (from == 0 || !isWordRune(before)) && (to == len(content) || !isWordRune(after))
+}
+
+// isWordRune reports whether r is a letter, digit or underscore in any script
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
+}
+
+// LinkPolicy decides what happens to links in chat messages
+type LinkPolicy string
+
+const (
+	// LinkAllow keeps links untouched
+	LinkAllow LinkPolicy = "allow"
+	// LinkStrip removes links from the message
+	LinkStrip LinkPolicy = "strip"
+	// LinkBlock rejects messages containing links
+	LinkBlock LinkPolicy = "block"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkFilter applies a link policy to chat messages
+func LinkFilter(policy LinkPolicy) ChatFilter {
+	return ChatFilterFunc(func(_, content string) (string, error) {
+		switch policy {
+		case LinkStrip:
+			return strings.TrimSpace(linkPattern.ReplaceAllString(content, "")), nil
+		case LinkBlock:
+			if linkPattern.MatchString(content) {
+				return "", &ModerationError{Code: "links_not_allowed", Message: "links are not allowed"}
+			}
+		}
+		return content, nil
+	})
+}
+
+// RateLimitFilter rejects messages from senders that already sent limit
+// messages within the sliding window. A limit or window of zero or less disables it.
+func RateLimitFilter(limit int, window time.Duration) ChatFilter {
+	if limit <= 0 || window <= 0 {
+		return ChatFilterFunc(func(_, content string) (string, error) { return content, nil })
+	}
+	return &rateLimitFilter{limit: limit, window: window, sent: make(map[string][]time.Time)}
+}
+
+// rateLimitFilter is the ChatFilter returned by RateLimitFilter
+type rateLimitFilter struct {
+	limit  int
+	window time.Duration
+
+	mutex     sync.Mutex
+	sent      map[string][]time.Time
+	lastPrune time.Time
+}
+
+// Filter records a message from senderID or rejects it if the sender is over the limit
+func (f *rateLimitFilter) Filter(senderID, content string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := time.Now()
+	recent := f.sent[senderID][:0]
+	for _, t := range f.sent[senderID] {
+		if now.Sub(t) < f.window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= f.limit {
+		f.sent[senderID] = recent
+		return "", &ModerationError{Code: "rate_limited", Message: "sending messages too fast"}
+	}
+	f.sent[senderID] = append(recent, now)
+
+	// Forget idle senders once per window so the map doesn't grow without bound
+	if now.Sub(f.lastPrune) >= f.window {
+		for id, times := range f.sent {
+			if len(times) > 0 && now.Sub(times[len(times)-1]) >= f.window {
+				delete(f.sent, id)
+			}
+		}
+		f.lastPrune = now
+	}
+	return content, nil
+}
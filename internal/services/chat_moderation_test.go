@@ -0,0 +1,117 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func moderationCode(err error) string {
+	if modErr, ok := err.(*ModerationError); ok {
+		return modErr.Code
+	}
+	return ""
+}
+
+func TestChatModerator(t *testing.T) {
+	m := NewChatModerator(
+		SanitizeFilter(),
+		MaxLengthFilter(20),
+		BlocklistFilter([]string{"darn", " "}),
+	)
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		code     string
+	}{
+		{"Plain", "hello", "hello", ""},
+		{"HTML", "<script>x</script>hi <b>there</b>", "xhi there", ""},
+		{"Attributes", `<img src="x" onerror=alert(1)/>ok<!-- note -->`, "ok", ""},
+		{"Comparison", "if a < b and 1<2", "if a < b and 1<2", ""},
+		{"BareAttribute", "<img src=x onerror=alert(1) x>ok", "ok", ""},
+		{"Autofocus", "<input autofocus onfocus=alert(1)>ok", "ok", ""},
+		{"SlashAttribute", "<svg/onload=alert(1)>ok", "ok", ""},
+		{"ControlChars", "a\x00b\x1bc\nd", "abc\nd", ""},
+		{"Blocklist", "Darn it, darnit", "**** it, darnit", ""},
+		{"BlocklistUnicode", "darné éDARN ¡darn!", "darné éDARN ¡****!", ""},
+		{"TooLong", strings.Repeat("a", 21), "", "message_too_long"},
+		{"Empty", "<br>  ", "", "empty_message"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := m.Moderate("sender", tt.content)
+			if code := moderationCode(err); code != tt.code {
+				t.Fatalf("Expected code %q, got %q (%v)", tt.code, code, err)
+			}
+			if content != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, content)
+			}
+		})
+	}
+}
+
+func TestLinkFilter(t *testing.T) {
+	content := "see https://example.com/x and www.example.org"
+
+	if got, _ := LinkFilter(LinkAllow).Filter("s", content); got != content {
+		t.Errorf("Expected links to be kept, got %q", got)
+	}
+	if got, _ := LinkFilter(LinkStrip).Filter("s", content); strings.Contains(got, "example") {
+		t.Errorf("Expected links to be stripped, got %q", got)
+	}
+	if _, err := LinkFilter(LinkBlock).Filter("s", content); moderationCode(err) != "links_not_allowed" {
+		t.Errorf("Expected links_not_allowed, got %v", err)
+	}
+	if _, err := LinkFilter(LinkBlock).Filter("s", "no links here"); err != nil {
+		t.Errorf("Expected message without links to pass, got %v", err)
+	}
+}
+
+func TestRateLimitFilter(t *testing.T) {
+	f := RateLimitFilter(2, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.Filter("a", "hi"); err != nil {
+			t.Fatalf("Expected message %d to pass, got %v", i, err)
+		}
+	}
+	if _, err := f.Filter("a", "hi"); moderationCode(err) != "rate_limited" {
+		t.Errorf("Expected rate_limited, got %v", err)
+	}
+	if _, err := f.Filter("b", "hi"); err != nil {
+		t.Errorf("Expected other senders not to be limited, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := f.Filter("a", "hi"); err != nil {
+		t.Errorf("Expected limit to reset after the window, got %v", err)
+	}
+}
+
+func TestModerateParts(t *testing.T) {
+	m := NewChatModerator(SanitizeFilter(), BlocklistFilter([]string{"darn"}), RateLimitFilter(1, time.Minute))
+
+	parts, err := m.ModerateParts("a", []string{"<b>Lunch?</b>", "darn pizza", "<br>"})
+	if err != nil {
+		t.Fatalf("Expected the parts to count as one message, got %v", err)
+	}
+	if parts[0] != "Lunch?" || parts[1] != "**** pizza" || parts[2] != "" {
+		t.Errorf("Expected every part to be moderated, got %q", parts)
+	}
+	if _, err := m.ModerateParts("a", []string{"again"}); moderationCode(err) != "rate_limited" {
+		t.Errorf("Expected rate_limited, got %v", err)
+	}
+}
+
+func TestDisabledFilters(t *testing.T) {
+	for _, f := range []ChatFilter{MaxLengthFilter(0), RateLimitFilter(0, time.Second)} {
+		for i := 0; i < 3; i++ {
+			if _, err := f.Filter("a", "hi"); err != nil {
+				t.Fatalf("Expected a zero limit to disable the filter, got %v", err)
+			}
+		}
+	}
+}
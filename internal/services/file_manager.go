@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"zeem/internal/models"
+	"zeem/internal/store"
+)
+
+const (
+	// DefaultFileMaxBytes is the upload size limit when none is configured
+	DefaultFileMaxBytes = 10 << 20
+)
+
+// DefaultFileTypes are the MIME types that may be shared when none are configured
+var DefaultFileTypes = []string{
+	"application/pdf",
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"text/plain",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+var (
+	// ErrFileTooLarge is returned when an upload exceeds the size limit
+	ErrFileTooLarge = errors.New("file is too large")
+	// ErrFileTypeNotAllowed is returned when an upload's MIME type is not allowed
+	ErrFileTypeNotAllowed = errors.New("file type is not allowed")
+)
+
+// fileCleanup is a pending deletion of a room's files
+type fileCleanup struct {
+	timer *time.Timer
+}
+
+// FileManager validates, stores and cleans up files shared in room chat
+type FileManager struct {
+	files        store.FileStore
+	maxBytes     int64
+	allowedTypes map[string]bool
+	retention    time.Duration
+
+	mutex    sync.Mutex
+	cleanups map[string]*fileCleanup // Pending file deletions of empty rooms
+}
+
+// NewFileManager creates a file manager. Files of a room are deleted once the
+// room has been empty for the retention period.
+func NewFileManager(files store.FileStore, maxBytes int64, allowedTypes []string, retention time.Duration) *FileManager {
+	allowed := make(map[string]bool, len(allowedTypes))
+	for _, t := range allowedTypes {
+		allowed[strings.TrimSpace(t)] = true
+	}
+	return &FileManager{
+		files:        files,
+		maxBytes:     maxBytes,
+		allowedTypes: allowed,
+		retention:    retention,
+		cleanups:     make(map[string]*fileCleanup),
+	}
+}
+
+// MaxBytes returns the upload size limit
+func (m *FileManager) MaxBytes() int64 {
+	return m.maxBytes
+}
+
+// Save checks and stores an uploaded file and returns its attachment
+func (m *FileManager) Save(roomID, name string, r io.Reader) (models.Attachment, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return models.Attachment{}, err
+	}
+	head = head[:n]
+
+	fileType := detectFileType(name, head)
+	if !m.allowedTypes[fileType] {
+		return models.Attachment{}, ErrFileTypeNotAllowed
+	}
+
+	fileID := uuid.New().String()
+	body := io.LimitReader(io.MultiReader(bytes.NewReader(head), r), m.maxBytes+1)
+	size, err := m.files.Save(roomID, fileID, body)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if size > m.maxBytes {
+		m.files.DeleteFile(roomID, fileID)
+		return models.Attachment{}, ErrFileTooLarge
+	}
+
+	return models.Attachment{
+		ID:   fileID,
+		Name: filepath.Base(name),
+		Size: size,
+		Type: fileType,
+		URL:  fmt.Sprintf("/api/rooms/%s/files/%s", roomID, fileID),
+	}, nil
+}
+
+// Open returns the contents of a shared file
+func (m *FileManager) Open(roomID, fileID string) (io.ReadCloser, error) {
+	if _, err := uuid.Parse(fileID); err != nil {
+		return nil, store.ErrNotFound
+	}
+	return m.files.Open(roomID, fileID)
+}
+
+// Delete removes a stored file
+func (m *FileManager) Delete(roomID, fileID string) {
+	if err := m.files.DeleteFile(roomID, fileID); err != nil {
+		log.Printf("Failed to delete file %s of room %s: %v", fileID, roomID, err)
+	}
+}
+
+// RoomEmptied schedules the deletion of a room's files after the retention
+// period, replacing any deletion already pending for the room
+func (m *FileManager) RoomEmptied(room *models.Room) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.stopCleanup(room.ID)
+	pending := &fileCleanup{}
+	pending.timer = time.AfterFunc(m.retention, func() { m.cleanup(room, pending) })
+	m.cleanups[room.ID] = pending
+}
+
+// RoomJoined cancels the pending deletion of a room's files
+func (m *FileManager) RoomJoined(room *models.Room) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.stopCleanup(room.ID)
+}
+
+// stopCleanup cancels a room's pending deletion. Callers must hold the lock.
+func (m *FileManager) stopCleanup(roomID string) {
+	if pending, ok := m.cleanups[roomID]; ok {
+		pending.timer.Stop()
+		delete(m.cleanups, roomID)
+	}
+}
+
+// cleanup deletes a room's files, unless the deletion was cancelled or
+// someone is back in the room
+func (m *FileManager) cleanup(room *models.Room, pending *fileCleanup) {
+	m.mutex.Lock()
+	if m.cleanups[room.ID] != pending {
+		m.mutex.Unlock()
+		return
+	}
+	delete(m.cleanups, room.ID)
+	m.mutex.Unlock()
+
+	if !room.IsEmpty() {
+		return
+	}
+	if err := m.files.DeleteRoom(room.ID); err != nil {
+		log.Printf("Failed to delete files of room %s: %v", room.ID, err)
+		return
+	}
+	room.ClearAttachments()
+}
+
+// detectFileType returns the MIME type of a file from its extension, falling back to sniffing its content
+func detectFileType(name string, head []byte) string {
+	fileType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if fileType == "" {
+		fileType = http.DetectContentType(head)
+	}
+	mediaType, _, err := mime.ParseMediaType(fileType)
+	if err != nil {
+		return fileType
+	}
+	return mediaType
+}
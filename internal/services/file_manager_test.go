@@ -0,0 +1,74 @@
+package services
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"zeem/internal/models"
+	"zeem/internal/store"
+)
+
+func TestFileManager(t *testing.T) {
+	files, err := store.NewDiskFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskFileStore failed: %v", err)
+	}
+	fm := NewFileManager(files, 16, []string{"text/plain"}, 0)
+
+	attachment, err := fm.Save("room", "notes.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if attachment.Size != 5 || attachment.Type != "text/plain" || attachment.URL != "/api/rooms/room/files/"+attachment.ID {
+		t.Errorf("Unexpected attachment %+v", attachment)
+	}
+	r, err := fm.Open("room", attachment.ID)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	contents, _ := io.ReadAll(r)
+	r.Close()
+	if string(contents) != "hello" {
+		t.Errorf("Expected stored contents, got %q", contents)
+	}
+
+	if _, err := fm.Save("room", "big.txt", strings.NewReader(strings.Repeat("x", 17))); err != ErrFileTooLarge {
+		t.Errorf("Expected %v, got %v", ErrFileTooLarge, err)
+	}
+	if _, err := fm.Save("room", "run.exe", strings.NewReader("MZ")); err != ErrFileTypeNotAllowed {
+		t.Errorf("Expected %v, got %v", ErrFileTypeNotAllowed, err)
+	}
+	if _, err := fm.Open("room", "../escape"); err != store.ErrNotFound {
+		t.Errorf("Expected %v for an invalid file ID, got %v", store.ErrNotFound, err)
+	}
+
+	room := models.NewRoom("room", models.OneToOne)
+	room.AddChatMessage(models.ChatMessage{SenderID: "a", Attachment: &attachment})
+
+	// A join cancels the pending cleanup even if the room is empty again when it fires
+	slow := NewFileManager(files, 16, []string{"text/plain"}, 20*time.Millisecond)
+	slow.RoomEmptied(room)
+	slow.RoomEmptied(room)
+	slow.RoomJoined(room)
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := room.GetAttachment(attachment.ID); !ok {
+		t.Fatal("Expected files to be kept after someone joined")
+	}
+
+	fm.RoomEmptied(room)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := room.GetAttachment(attachment.ID); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected files to be cleaned up after the room emptied")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := fm.Open("room", attachment.ID); err != store.ErrNotFound {
+		t.Errorf("Expected %v after cleanup, got %v", store.ErrNotFound, err)
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultReactionRateLimit is the number of reactions a participant may send per DefaultReactionRateWindow
+	DefaultReactionRateLimit = 10
+	// DefaultReactionRateWindow is the sliding window of the reaction rate limit
+	DefaultReactionRateWindow = 5 * time.Second
+	// DefaultReactionInterval is how long reactions are collected before they are broadcast
+	DefaultReactionInterval = 500 * time.Millisecond
+)
+
+// DefaultReactionEmojis are the reactions allowed when none are configured
+var DefaultReactionEmojis = []string{"👍", "❤️", "😂", "😮", "👏", "🎉"}
+
+// ReactionConfig holds the limits of live reactions
+type ReactionConfig struct {
+	// Allowed is the set of emoji participants may react with
+	Allowed []string
+	// RateLimit is the number of reactions a participant may send per RateWindow
+	RateLimit  int
+	RateWindow time.Duration
+	// Interval is how long reactions are counted before a burst is flushed
+	Interval time.Duration
+}
+
+// DefaultReactionConfig returns the reaction limits used when none are configured
+func DefaultReactionConfig() ReactionConfig {
+	return ReactionConfig{
+		Allowed:    DefaultReactionEmojis,
+		RateLimit:  DefaultReactionRateLimit,
+		RateWindow: DefaultReactionRateWindow,
+		Interval:   DefaultReactionInterval,
+	}
+}
+
+// ReactionFlushFunc receives the reactions counted in a room during one interval
+type ReactionFlushFunc func(roomID string, counts map[string]int)
+
+// ReactionAggregator counts live reactions per room and flushes them as one
+// burst per interval, so a large audience does not cause a fan-out per click
+type ReactionAggregator struct {
+	allowed  map[string]bool
+	limiter  ChatFilter
+	interval time.Duration
+	flush    ReactionFlushFunc
+
+	mutex   sync.Mutex
+	pending map[string]map[string]int // Room ID -> emoji -> count
+}
+
+// NewReactionAggregator creates an aggregator that hands each burst to flush
+func NewReactionAggregator(cfg ReactionConfig, flush ReactionFlushFunc) *ReactionAggregator {
+	allowed := make(map[string]bool, len(cfg.Allowed))
+	for _, emoji := range cfg.Allowed {
+		allowed[emoji] = true
+	}
+	return &ReactionAggregator{
+		allowed:  allowed,
+		limiter:  RateLimitFilter(cfg.RateLimit, cfg.RateWindow),
+		interval: cfg.Interval,
+		flush:    flush,
+		pending:  make(map[string]map[string]int),
+	}
+}
+
+// Validate checks a reaction against the allowed set and counts it towards the
+// participant's rate limit. It returns a *ModerationError when the emoji is
+// not allowed or the participant is reacting too fast. Reactions to chat
+// messages go through it too, so they share the limits of live reactions.
+func (a *ReactionAggregator) Validate(participantID, emoji string) error {
+	if !a.allowed[emoji] {
+		return &ModerationError{Code: "reaction_not_allowed", Message: "reaction is not allowed"}
+	}
+	_, err := a.limiter.Filter(participantID, emoji)
+	return err
+}
+
+// Add counts a reaction towards the room's next burst after validating it
+func (a *ReactionAggregator) Add(roomID, participantID, emoji string) error {
+	if err := a.Validate(participantID, emoji); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	counts, ok := a.pending[roomID]
+	if !ok {
+		counts = make(map[string]int)
+		a.pending[roomID] = counts
+		time.AfterFunc(a.interval, func() { a.flushRoom(roomID) })
+	}
+	counts[emoji]++
+	return nil
+}
+
+// flushRoom hands the reactions counted in a room to the flush function
+func (a *ReactionAggregator) flushRoom(roomID string) {
+	a.mutex.Lock()
+	counts := a.pending[roomID]
+	delete(a.pending, roomID)
+	a.mutex.Unlock()
+
+	if len(counts) > 0 {
+		a.flush(roomID, counts)
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReactionAggregator(t *testing.T) {
+	bursts := make(chan map[string]int, 1)
+	aggregator := NewReactionAggregator(ReactionConfig{
+		Allowed:    []string{"👍", "🎉"},
+		RateLimit:  2,
+		RateWindow: time.Minute,
+		Interval:   20 * time.Millisecond,
+	}, func(roomID string, counts map[string]int) {
+		if roomID == "room" {
+			bursts <- counts
+		}
+	})
+
+	var moderationErr *ModerationError
+	if err := aggregator.Add("room", "a", "💩"); !errors.As(err, &moderationErr) || moderationErr.Code != "reaction_not_allowed" {
+		t.Errorf("Expected reaction_not_allowed, got %v", err)
+	}
+	if err := aggregator.Validate("c", "👍"); err != nil {
+		t.Errorf("Expected an allowed reaction to validate, got %v", err)
+	}
+	aggregator.Validate("c", "👍")
+	if err := aggregator.Add("room", "c", "👍"); !errors.As(err, &moderationErr) || moderationErr.Code != "rate_limited" {
+		t.Errorf("Expected validated reactions to count towards the rate limit, got %v", err)
+	}
+
+	for _, r := range []struct{ participant, emoji string }{{"a", "👍"}, {"a", "👍"}, {"b", "🎉"}} {
+		if err := aggregator.Add("room", r.participant, r.emoji); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := aggregator.Add("room", "a", "👍"); !errors.As(err, &moderationErr) || moderationErr.Code != "rate_limited" {
+		t.Errorf("Expected rate_limited, got %v", err)
+	}
+
+	select {
+	case counts := <-bursts:
+		if counts["👍"] != 2 || counts["🎉"] != 1 {
+			t.Errorf("Unexpected burst %v", counts)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected reactions to be flushed as one burst")
+	}
+	select {
+	case counts := <-bursts:
+		t.Errorf("Expected a single burst, got another %v", counts)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -1,34 +1,287 @@
 package services
 
 import (
+	"fmt"
+	"log"
 	"sync"
+	"time"
 
 	"zeem/internal/models"
+	"zeem/internal/store"
 )
 
 // RoomManager handles the management of video conference rooms
 type RoomManager struct {
-	rooms map[string]*models.Room
-	mutex sync.RWMutex
+	rooms    map[string]*models.Room
+	settings models.RoomSettings
+	store    store.Store
+	mutex    sync.RWMutex
+
+	emptyTTL time.Duration          // How long a room may stay empty in memory; zero or less keeps it
+	expiries map[string]*roomExpiry // Countdowns to unloading empty rooms
+	closed   map[string]bool        // Closed breakout rooms, which may not be created again
+}
+
+// roomExpiry is the countdown to unloading an empty room
+type roomExpiry struct {
+	timer *time.Timer
 }
 
-// NewRoomManager creates a new instance of RoomManager
+// NewRoomManager creates a new instance of RoomManager backed by an in-memory store
 func NewRoomManager() *RoomManager {
+	return NewRoomManagerWithStore(store.NewMemoryStore())
+}
+
+// NewRoomManagerWithStore creates a new instance of RoomManager that persists to s
+func NewRoomManagerWithStore(s store.Store) *RoomManager {
 	return &RoomManager{
-		rooms: make(map[string]*models.Room),
+		rooms:    make(map[string]*models.Room),
+		settings: models.DefaultRoomSettings(),
+		store:    s,
+		expiries: make(map[string]*roomExpiry),
+		closed:   make(map[string]bool),
+	}
+}
+
+// SetEmptyRoomTTL makes rooms that stay empty for ttl get unloaded from
+// memory. Their record, chat and sessions stay in the store, and the room is
+// loaded again when someone joins it. Scheduled rooms count from their start
+// time. Zero or less keeps rooms in memory forever.
+func (rm *RoomManager) SetEmptyRoomTTL(ttl time.Duration) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	rm.emptyTTL = ttl
+}
+
+// RoomEmptied starts the countdown to unloading a room the last participant left.
+// Closed breakout rooms are deleted right away.
+func (rm *RoomManager) RoomEmptied(roomID string) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	if rm.closed[roomID] {
+		rm.deleteRoom(roomID)
+		return
+	}
+	rm.expireAfter(roomID, rm.emptyTTL)
+}
+
+// RoomJoined stops the countdown to unloading a room someone joined
+func (rm *RoomManager) RoomJoined(roomID string) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	rm.stopExpiry(roomID)
+}
+
+// expireAfter (re)starts the countdown to unloading a room. Callers must hold the lock.
+func (rm *RoomManager) expireAfter(roomID string, delay time.Duration) {
+	if rm.emptyTTL <= 0 {
+		return
 	}
+	rm.stopExpiry(roomID)
+	expiry := &roomExpiry{}
+	expiry.timer = time.AfterFunc(delay, func() { rm.expire(roomID, expiry) })
+	rm.expiries[roomID] = expiry
+}
+
+// stopExpiry cancels the countdown of a room. Callers must hold the lock.
+func (rm *RoomManager) stopExpiry(roomID string) {
+	if expiry, ok := rm.expiries[roomID]; ok {
+		expiry.timer.Stop()
+		delete(rm.expiries, roomID)
+	}
+}
+
+// expire unloads a room whose countdown ran out, unless someone joined since.
+// The stored room, chat and sessions are kept.
+func (rm *RoomManager) expire(roomID string, expiry *roomExpiry) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	if rm.expiries[roomID] != expiry {
+		return
+	}
+	delete(rm.expiries, roomID)
+	if room := rm.rooms[roomID]; room != nil && !room.IsEmpty() {
+		return
+	}
+	delete(rm.rooms, roomID)
+}
+
+// emptyRoomDelay returns how long a new or restored room may stay empty
+// before it is unloaded. Callers must hold the lock.
+func (rm *RoomManager) emptyRoomDelay(scheduledAt int64) time.Duration {
+	delay := rm.emptyTTL
+	if untilStart := time.Until(time.Unix(scheduledAt, 0)); scheduledAt > 0 && untilStart > 0 {
+		delay += untilStart
+	}
+	return delay
+}
+
+// SetDefaultSettings sets the settings applied to rooms created afterwards
+func (rm *RoomManager) SetDefaultSettings(settings models.RoomSettings) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	rm.settings = settings
+}
+
+// Restore recreates the rooms saved in the store together with their chat
+func (rm *RoomManager) Restore() error {
+	records, err := rm.store.ListRooms()
+	if err != nil {
+		return err
+	}
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	for _, record := range records {
+		if _, err := rm.loadRoom(record); err != nil {
+			return err
+		}
+		rm.expireAfter(record.ID, rm.emptyRoomDelay(record.ScheduledAt))
+	}
+	return nil
+}
+
+// loadRoom recreates and registers a stored room together with its chat.
+// Callers must hold the lock.
+func (rm *RoomManager) loadRoom(record store.RoomRecord) (*models.Room, error) {
+	messages, err := rm.store.ListChatMessages(record.ID)
+	if err != nil {
+		return nil, err
+	}
+	room := models.NewRoomWithSettings(record.ID, record.Type, rm.settings)
+	if record.Capacity > 0 {
+		room.Capacity = record.Capacity
+	}
+	room.LoadChatMessages(messages)
+	rm.rooms[record.ID] = room
+	return room, nil
+}
+
+// GetOrCreateRoom returns the room a participant is about to join: the room in
+// memory, the stored room loaded again after it was unloaded, or a new room of
+// the given type. It stops the room's expiry countdown so that the room is not
+// unloaded before the participant is added.
+func (rm *RoomManager) GetOrCreateRoom(roomID string, roomType models.ConnectionType) *models.Room {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	if room, ok := rm.rooms[roomID]; ok {
+		rm.stopExpiry(roomID)
+		return room
+	}
+	record, err := rm.store.GetRoom(roomID)
+	if err == nil {
+		var room *models.Room
+		if room, err = rm.loadRoom(record); err == nil {
+			return room
+		}
+	}
+	if err != store.ErrNotFound {
+		log.Printf("Failed to load room %s: %v", roomID, err)
+	}
+	room := rm.createRoomLocked(roomID, roomType, 0, 0, "")
+	rm.stopExpiry(roomID)
+	return room
 }
 
 // CreateRoom creates a new room with the given ID and type
 func (rm *RoomManager) CreateRoom(roomID string, roomType models.ConnectionType) *models.Room {
+	return rm.ScheduleRoom(roomID, roomType, 0, 0)
+}
+
+// ScheduleRoom creates a new room that is expected to start at scheduledAt (a
+// Unix time, or 0). A positive capacity overrides the default size of a group room.
+func (rm *RoomManager) ScheduleRoom(roomID string, roomType models.ConnectionType, scheduledAt int64, capacity int) *models.Room {
+	return rm.createRoom(roomID, roomType, scheduledAt, capacity, "")
+}
+
+// createRoom creates, registers and saves a room; parentID links breakout rooms to their parent
+func (rm *RoomManager) createRoom(roomID string, roomType models.ConnectionType, scheduledAt int64, capacity int, parentID string) *models.Room {
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
+	return rm.createRoomLocked(roomID, roomType, scheduledAt, capacity, parentID)
+}
 
-	room := models.NewRoom(roomID, roomType)
+// createRoomLocked is createRoom for callers that hold the lock
+func (rm *RoomManager) createRoomLocked(roomID string, roomType models.ConnectionType, scheduledAt int64, capacity int, parentID string) *models.Room {
+	room := models.NewRoomWithSettings(roomID, roomType, rm.settings)
+	if roomType != models.Group {
+		capacity = 0
+	}
+	if capacity > 0 {
+		room.Capacity = capacity
+	}
+	if parentID != "" {
+		// Breakout rooms hold everyone the host assigns to them
+		room.Capacity = 0
+	}
+	room.ParentID = parentID
 	rm.rooms[roomID] = room
+	rm.expireAfter(roomID, rm.emptyRoomDelay(scheduledAt))
+
+	err := rm.store.SaveRoom(store.RoomRecord{
+		ID:          roomID,
+		Type:        roomType,
+		CreatedAt:   time.Now().Unix(),
+		ScheduledAt: scheduledAt,
+		Capacity:    capacity,
+	})
+	if err != nil {
+		log.Printf("Failed to save room %s: %v", roomID, err)
+	}
 	return room
 }
 
+// OpenBreakouts creates count breakout rooms linked to parent on behalf of actorID
+// and returns them. Broadcasting rooms break out into group rooms so that
+// everyone in a breakout can speak. Group breakouts have no capacity limit.
+func (rm *RoomManager) OpenBreakouts(parent *models.Room, actorID string, count int) ([]models.BreakoutRoom, error) {
+	if count <= 0 || count > models.MaxBreakoutRooms {
+		return nil, models.ErrInvalidBreakout
+	}
+	breakouts := make([]models.BreakoutRoom, count)
+	rm.mutex.RLock()
+	for i, n := 0, 1; i < count; n++ {
+		id := fmt.Sprintf("%s-breakout-%d", parent.ID, n)
+		if _, exists := rm.rooms[id]; exists || rm.closed[id] {
+			continue
+		}
+		breakouts[i] = models.BreakoutRoom{ID: id, Name: fmt.Sprintf("Breakout %d", i+1)}
+		i++
+	}
+	rm.mutex.RUnlock()
+	if err := parent.OpenBreakouts(actorID, breakouts); err != nil {
+		return nil, err
+	}
+
+	roomType := parent.Type
+	if roomType == models.Broadcasting {
+		roomType = models.Group
+	}
+	for _, b := range breakouts {
+		rm.createRoom(b.ID, roomType, 0, 0, parent.ID)
+	}
+	return breakouts, nil
+}
+
+// CloseBreakout marks a breakout room as closed so that it cannot be created
+// again. The room is deleted once its last participant has left.
+func (rm *RoomManager) CloseBreakout(roomID string) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	rm.closed[roomID] = true
+	if room, ok := rm.rooms[roomID]; ok && room.IsEmpty() {
+		rm.deleteRoom(roomID)
+	}
+}
+
+// IsClosed reports whether a room is a closed breakout room
+func (rm *RoomManager) IsClosed(roomID string) bool {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	return rm.closed[roomID]
+}
+
 // GetRoom returns a room by its ID
 func (rm *RoomManager) GetRoom(roomID string) *models.Room {
 	rm.mutex.RLock()
@@ -36,11 +289,26 @@ func (rm *RoomManager) GetRoom(roomID string) *models.Room {
 	return rm.rooms[roomID]
 }
 
+// GetRoomRecord returns the stored record of a room
+func (rm *RoomManager) GetRoomRecord(roomID string) (store.RoomRecord, error) {
+	return rm.store.GetRoom(roomID)
+}
+
 // DeleteRoom removes a room
 func (rm *RoomManager) DeleteRoom(roomID string) {
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
+	rm.deleteRoom(roomID)
+}
+
+// deleteRoom removes a room and its stored data. Callers must hold the lock.
+func (rm *RoomManager) deleteRoom(roomID string) {
+	rm.stopExpiry(roomID)
 	delete(rm.rooms, roomID)
+
+	if err := rm.store.DeleteRoom(roomID); err != nil {
+		log.Printf("Failed to delete room %s: %v", roomID, err)
+	}
 }
 
 // RoomExists checks if a room exists
@@ -64,3 +332,64 @@ func (rm *RoomManager) GetRoomsByType(roomType models.ConnectionType) []*models.
 	}
 	return rooms
 }
+
+// SaveChatMessage persists a new or updated chat message of a room
+func (rm *RoomManager) SaveChatMessage(roomID string, message models.ChatMessage) {
+	if err := rm.store.SaveChatMessage(roomID, message); err != nil {
+		log.Printf("Failed to save chat message %s in room %s: %v", message.ID, roomID, err)
+	}
+}
+
+// SaveSession persists a participant session record
+func (rm *RoomManager) SaveSession(session store.SessionRecord) {
+	if err := rm.store.SaveSession(session); err != nil {
+		log.Printf("Failed to save session of participant %s in room %s: %v", session.ParticipantID, session.RoomID, err)
+	}
+}
+
+// Authenticate resolves a participant access token to the role it grants in a room.
+// Tokens stay valid after the participant leaves, with the role it last had.
+func (rm *RoomManager) Authenticate(roomID, token string) (models.Role, bool) {
+	if token == "" {
+		return "", false
+	}
+	if room := rm.GetRoom(roomID); room != nil {
+		if p := room.GetParticipantByToken(token); p != nil {
+			if view, ok := room.GetParticipantView(p.ID); ok {
+				return view.Role, true
+			}
+		}
+	}
+
+	sessions, err := rm.store.ListSessions(roomID)
+	if err != nil {
+		log.Printf("Failed to load sessions of room %s: %v", roomID, err)
+		return "", false
+	}
+	for _, session := range sessions {
+		if session.Token == token {
+			return session.Role, true
+		}
+	}
+	return "", false
+}
+
+// GetChatTranscript returns every stored public chat message of a room, oldest first
+func (rm *RoomManager) GetChatTranscript(roomID string) ([]models.ChatMessage, error) {
+	messages, err := rm.store.ListChatMessages(roomID)
+	if err != nil {
+		return nil, err
+	}
+	transcript := make([]models.ChatMessage, 0, len(messages))
+	for _, m := range messages {
+		if !m.IsDirect() {
+			transcript = append(transcript, m)
+		}
+	}
+	return transcript, nil
+}
+
+// GetSessions returns the participant session records of a room
+func (rm *RoomManager) GetSessions(roomID string) ([]store.SessionRecord, error) {
+	return rm.store.ListSessions(roomID)
+}
@@ -1,9 +1,12 @@
 package services
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"zeem/internal/models"
+	"zeem/internal/store"
 )
 
 func TestRoomManager(t *testing.T) {
@@ -90,3 +93,159 @@ func TestGetRoomsByType(t *testing.T) {
 		t.Error("Expected to get the same ScreenSharing room instance")
 	}
 }
+
+func TestRoomManagerRestore(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	rm := NewRoomManagerWithStore(s)
+	room := rm.ScheduleRoom("scheduled", models.Broadcasting, 1700000000, 0)
+	msg, _ := room.AddChatMessage(models.ChatMessage{SenderID: "a", Content: "hello"})
+	rm.SaveChatMessage(room.ID, msg)
+
+	restarted := NewRoomManagerWithStore(s)
+	if err := restarted.Restore(); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	restored := restarted.GetRoom("scheduled")
+	if restored == nil || restored.Type != models.Broadcasting {
+		t.Fatalf("Expected scheduled room to be restored, got %+v", restored)
+	}
+	history := restored.GetChatHistory()
+	if len(history) != 1 || history[0].Content != "hello" {
+		t.Errorf("Expected chat to be restored, got %+v", history)
+	}
+
+	next, _ := restored.AddChatMessage(models.ChatMessage{SenderID: "a", Content: "again"})
+	if next.Seq <= msg.Seq {
+		t.Errorf("Expected sequence numbers to continue after restore, got %d", next.Seq)
+	}
+}
+
+func TestRoomManagerEmptyRoomTTL(t *testing.T) {
+	rm := NewRoomManager()
+	rm.SetEmptyRoomTTL(100 * time.Millisecond)
+
+	abandoned := rm.CreateRoom("abandoned", models.OneToOne)
+	msg, _ := abandoned.AddChatMessage(models.ChatMessage{SenderID: "a", Content: "hello"})
+	rm.SaveChatMessage(abandoned.ID, msg)
+	rm.CreateRoom("busy", models.OneToOne)
+	rm.RoomJoined("busy")
+	scheduled := rm.ScheduleRoom("scheduled", models.OneToOne, time.Now().Add(time.Hour).Unix(), 0)
+
+	time.Sleep(300 * time.Millisecond)
+	if rm.RoomExists("abandoned") {
+		t.Error("Expected the empty room to be unloaded")
+	}
+	if transcript, _ := rm.GetChatTranscript("abandoned"); len(transcript) != 1 {
+		t.Errorf("Expected the chat of an unloaded room to be kept, got %+v", transcript)
+	}
+	if !rm.RoomExists("busy") || rm.GetRoom("scheduled") != scheduled {
+		t.Error("Expected joined and upcoming rooms to be kept")
+	}
+
+	rm.RoomEmptied("busy")
+	rm.RoomJoined("busy")
+	rm.RoomEmptied("busy")
+	time.Sleep(10 * time.Millisecond)
+	if !rm.RoomExists("busy") {
+		t.Error("Expected the countdown to restart when the room empties again")
+	}
+	time.Sleep(300 * time.Millisecond)
+	if rm.RoomExists("busy") {
+		t.Error("Expected the room to be unloaded once it stayed empty")
+	}
+}
+
+func TestRoomManagerGetOrCreateRoom(t *testing.T) {
+	rm := NewRoomManager()
+	rm.SetEmptyRoomTTL(100 * time.Millisecond)
+
+	room := rm.GetOrCreateRoom("room", models.ScreenSharing)
+	if room == nil || room.Type != models.ScreenSharing || rm.GetOrCreateRoom("room", models.OneToOne) != room {
+		t.Fatalf("Expected one room of the first joiner's type, got %+v", room)
+	}
+	msg, _ := room.AddChatMessage(models.ChatMessage{SenderID: "a", Content: "hello"})
+	rm.SaveChatMessage(room.ID, msg)
+
+	time.Sleep(200 * time.Millisecond)
+	if !rm.RoomExists("room") {
+		t.Fatal("Expected a room about to be joined not to be unloaded")
+	}
+
+	rm.RoomEmptied("room")
+	time.Sleep(300 * time.Millisecond)
+	if rm.RoomExists("room") {
+		t.Fatal("Expected the empty room to be unloaded")
+	}
+	reloaded := rm.GetOrCreateRoom("room", models.OneToOne)
+	if reloaded == room || reloaded.Type != models.ScreenSharing {
+		t.Errorf("Expected the stored room to be loaded again, got %+v", reloaded)
+	}
+	if history := reloaded.GetChatHistory(); len(history) != 1 || history[0].Content != "hello" {
+		t.Errorf("Expected the chat to be loaded again, got %+v", history)
+	}
+}
+
+func TestRoomManagerCloseBreakout(t *testing.T) {
+	rm := NewRoomManager()
+	parent := rm.CreateRoom("main", models.OneToOne)
+	parent.AddParticipant(&models.Participant{ID: "host", ConnectionInfo: &models.ConnectionInfo{Type: models.OneToOne}})
+
+	if _, err := rm.OpenBreakouts(parent, "host", 2); err != nil {
+		t.Fatalf("OpenBreakouts failed: %v", err)
+	}
+	busy := rm.GetRoom("main-breakout-1")
+	busy.AddParticipant(&models.Participant{ID: "guest", ConnectionInfo: &models.ConnectionInfo{Type: models.OneToOne}})
+
+	parent.EndBreakouts(time.Time{})
+	rm.CloseBreakout("main-breakout-1")
+	rm.CloseBreakout("main-breakout-2")
+	if rm.RoomExists("main-breakout-2") {
+		t.Error("Expected an empty breakout to be deleted when it closes")
+	}
+	if !rm.RoomExists("main-breakout-1") {
+		t.Error("Expected an occupied breakout to be kept until it empties")
+	}
+	busy.RemoveParticipant("guest")
+	rm.RoomEmptied("main-breakout-1")
+	if rm.RoomExists("main-breakout-1") || !rm.IsClosed("main-breakout-1") {
+		t.Error("Expected the breakout to be deleted and stay closed once empty")
+	}
+
+	breakouts, err := rm.OpenBreakouts(parent, "host", 1)
+	if err != nil || breakouts[0].ID != "main-breakout-3" {
+		t.Errorf("Expected new breakouts to skip closed IDs, got %+v (%v)", breakouts, err)
+	}
+}
+
+func TestRoomManagerBreakoutCapacity(t *testing.T) {
+	rm := NewRoomManager()
+	parent := rm.CreateRoom("stage", models.Broadcasting)
+	parent.AddParticipant(&models.Participant{ID: "host", ConnectionInfo: &models.ConnectionInfo{Type: models.Broadcasting, IsBroadcaster: true}})
+	for i := 0; i < 20; i++ {
+		parent.AddParticipant(&models.Participant{ID: fmt.Sprintf("viewer-%d", i), ConnectionInfo: &models.ConnectionInfo{Type: models.Broadcasting}})
+	}
+
+	if _, err := rm.OpenBreakouts(parent, "host", 1); err != nil {
+		t.Fatalf("OpenBreakouts failed: %v", err)
+	}
+	assignments, err := parent.AutoAssignBreakouts("host")
+	if err != nil || len(assignments) != 20 {
+		t.Fatalf("Expected every viewer to be assigned, got %v (%v)", assignments, err)
+	}
+
+	breakout := rm.GetRoom("stage-breakout-1")
+	if breakout.Type != models.Group {
+		t.Errorf("Expected a group breakout, got %v", breakout.Type)
+	}
+	for participantID, roomID := range assignments {
+		p := &models.Participant{ID: participantID, ConnectionInfo: &models.ConnectionInfo{Type: models.Group}}
+		if err := rm.GetOrCreateRoom(roomID, models.Group).AddParticipant(p); err != nil {
+			t.Errorf("Expected %s to join the breakout, got %v", participantID, err)
+		}
+	}
+	if count := len(breakout.GetParticipants()); count != 20 {
+		t.Errorf("Expected the breakout to hold all 20 assignees, got %d", count)
+	}
+}
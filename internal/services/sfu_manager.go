@@ -4,36 +4,123 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"zeem/internal/models"
 
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
 )
 
+// simulcastLayers orders the simulcast layer RIDs from lowest to highest quality
+var simulcastLayers = []string{"q", "h", "f"}
+
+// switchTimestampGap is added to the RTP timestamp when switching layers,
+// one frame at 30 fps on the 90 kHz video clock
+const switchTimestampGap = 90000 / 30
+
+// SFUSignal is the SFU's side of a participant's negotiation: the answer to
+// the participant's offer, an offer after the tracks the participant receives
+// changed, or a local ICE candidate
+type SFUSignal struct {
+	Answer    *webrtc.SessionDescription
+	Offer     *webrtc.SessionDescription
+	Candidate *webrtc.ICECandidateInit
+}
+
+// SFUSignalFunc delivers an SFU signal to a participant of a room
+type SFUSignalFunc func(roomID string, participant *models.Participant, signal SFUSignal)
+
 type SFUManager struct {
 	mu              sync.RWMutex
 	participants    map[string]*models.Participant
+	rooms           map[string]string // participantID -> roomID
 	peerConnections map[string]*webrtc.PeerConnection
-	trackLocals     map[string]map[string]*webrtc.TrackLocalStaticRTP // participantID -> trackID -> track
+	negotiations    map[string]*sync.Mutex // participantID -> serializes offers and answers
+	signal          SFUSignalFunc
+	forwarders      map[string]map[string]*trackForwarder // participantID -> trackID -> forwarder
+	spotlights      map[string][]models.Spotlight         // roomID -> spotlight
+	speakers        map[string]*speakerTracker            // roomID -> audio levels
+	recent          map[string][]string                   // roomID -> participant IDs, most recently active first
+	pins            map[string][]models.Spotlight         // subscriberID -> pinned participants or tracks
+	floors          map[string]string                     // roomID -> presenter, in rooms with floor control
+	screens         map[string]string                     // participantID -> ID of their screen track or its stream
+	lastN           int                                   // Video senders each subscriber receives; 0 means all
 }
 
 func NewSFUManager() *SFUManager {
 	return &SFUManager{
 		participants:    make(map[string]*models.Participant),
+		rooms:           make(map[string]string),
 		peerConnections: make(map[string]*webrtc.PeerConnection),
-		trackLocals:     make(map[string]map[string]*webrtc.TrackLocalStaticRTP),
+		negotiations:    make(map[string]*sync.Mutex),
+		forwarders:      make(map[string]map[string]*trackForwarder),
+		spotlights:      make(map[string][]models.Spotlight),
+		speakers:        make(map[string]*speakerTracker),
+		recent:          make(map[string][]string),
+		pins:            make(map[string][]models.Spotlight),
+		floors:          make(map[string]string),
+		screens:         make(map[string]string),
 	}
 }
 
-func (s *SFUManager) AddParticipant(participant *models.Participant) error {
+// SetLastN limits the video each subscriber receives to the n most recently
+// active speakers of the room, plus the spotlight and the subscriber's pins.
+// Zero or less forwards all video.
+func (s *SFUManager) SetLastN(n int) {
+	s.mu.Lock()
+	s.lastN = n
+	var keyframes []keyframeRequest
+	for roomID := range s.recent {
+		keyframes = append(keyframes, s.applyLastN(roomID)...)
+	}
+	s.mu.Unlock()
+
+	s.requestKeyframes(keyframes)
+}
+
+// OnSignal sets how the SFU sends participants its offers and ICE candidates
+func (s *SFUManager) OnSignal(signal SFUSignalFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signal = signal
+}
+
+// HasParticipant reports whether a participant has a peer connection with the SFU
+func (s *SFUManager) HasParticipant(participantID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.peerConnections[participantID]
+	return ok
+}
+
+// AddParticipant creates the peer connection of a participant of a room.
+// A participant that already has one keeps it.
+func (s *SFUManager) AddParticipant(roomID string, participant *models.Participant) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if _, exists := s.peerConnections[participant.ID]; exists {
+		return nil
+	}
+
 	// Create a new PeerConnection
 	me := webrtc.MediaEngine{}
 	if err := me.RegisterDefaultCodecs(); err != nil {
 		return err
 	}
+	// Simulcast layers are told apart by their RID
+	for _, uri := range []string{sdp.SDESMidURI, sdp.SDESRTPStreamIDURI} {
+		if err := me.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: uri}, webrtc.RTPCodecTypeVideo); err != nil {
+			return err
+		}
+	}
+	// Audio packets carry their level (RFC 6464) for active speaker detection
+	if err := me.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: sdp.AudioLevelURI}, webrtc.RTPCodecTypeAudio); err != nil {
+		return err
+	}
 
 	api := webrtc.NewAPI(webrtc.WithMediaEngine(&me))
 	peerConnection, err := api.NewPeerConnection(webrtc.Configuration{
@@ -48,8 +135,40 @@ func (s *SFUManager) AddParticipant(participant *models.Participant) error {
 	}
 
 	s.participants[participant.ID] = participant
+	s.rooms[participant.ID] = roomID
 	s.peerConnections[participant.ID] = peerConnection
-	s.trackLocals[participant.ID] = make(map[string]*webrtc.TrackLocalStaticRTP)
+	s.forwarders[participant.ID] = make(map[string]*trackForwarder)
+	s.recent[roomID] = append(s.recent[roomID], participant.ID)
+	negotiation := &sync.Mutex{}
+	s.negotiations[participant.ID] = negotiation
+
+	// Trickle ICE candidates, and offer again whenever tracks are added.
+	// Candidates wait for the description under way, since the participant
+	// can't add them before it.
+	peerConnection.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate == nil {
+			return
+		}
+		init := candidate.ToJSON()
+		negotiation.Lock()
+		defer negotiation.Unlock()
+		s.sendSignal(participant.ID, SFUSignal{Candidate: &init})
+	})
+	peerConnection.OnNegotiationNeeded(func() {
+		go s.renegotiate(participant.ID, peerConnection, negotiation)
+	})
+
+	// Receive the tracks already published in the room
+	for senderID, forwarders := range s.forwarders {
+		if senderID == participant.ID || s.rooms[senderID] != roomID {
+			continue
+		}
+		for _, fwd := range forwarders {
+			if err := s.subscribe(participant.ID, peerConnection, fwd); err != nil {
+				log.Printf("Failed to forward track to participant %s: %v", participant.ID, err)
+			}
+		}
+	}
 
 	// Handle ICE connection state
 	peerConnection.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
@@ -59,7 +178,7 @@ func (s *SFUManager) AddParticipant(participant *models.Participant) error {
 	// Handle tracks
 	peerConnection.OnTrack(func(remoteTrack *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
 		log.Printf("Track received from participant %s", participant.ID)
-		s.handleTrack(participant.ID, remoteTrack)
+		s.handleTrack(participant.ID, remoteTrack, receiver)
 	})
 
 	log.Printf("Participant added: %s", participant.Username)
@@ -68,98 +187,718 @@ func (s *SFUManager) AddParticipant(participant *models.Participant) error {
 
 func (s *SFUManager) RemoveParticipant(participantID string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
+	var keyframes []keyframeRequest
+	// The peer connection is closed after unlocking, as its callbacks take the lock
+	pc := s.peerConnections[participantID]
 	if participant, exists := s.participants[participantID]; exists {
-		// Close and remove peer connection
-		if pc, ok := s.peerConnections[participantID]; ok {
-			pc.Close()
-			delete(s.peerConnections, participantID)
-		}
+		// Remove peer connection
+		delete(s.peerConnections, participantID)
+		delete(s.negotiations, participantID)
 
 		// Remove all tracks associated with this participant
-		delete(s.trackLocals, participantID)
+		for _, fwd := range s.forwarders[participantID] {
+			for subscriberID, sender := range fwd.senders() {
+				if subscriber, ok := s.peerConnections[subscriberID]; ok && sender != nil {
+					if err := subscriber.RemoveTrack(sender); err != nil {
+						log.Printf("Failed to remove track from participant %s: %v", subscriberID, err)
+					}
+					go s.renegotiate(subscriberID, subscriber, s.negotiations[subscriberID])
+				}
+			}
+		}
+		delete(s.forwarders, participantID)
 
 		// Remove participant
+		roomID := s.rooms[participantID]
 		delete(s.participants, participantID)
+		delete(s.rooms, participantID)
+		if tracker, ok := s.speakers[roomID]; ok {
+			tracker.remove(participantID)
+		}
+		delete(s.pins, participantID)
+		delete(s.screens, participantID)
+		for _, forwarders := range s.forwarders {
+			for _, fwd := range forwarders {
+				fwd.unsubscribe(participantID)
+			}
+		}
+
+		// Someone else moves up into the last N
+		if recent := removeID(s.recent[roomID], participantID); len(recent) > 0 {
+			s.recent[roomID] = recent
+			keyframes = s.applyLastN(roomID)
+		} else {
+			delete(s.recent, roomID)
+			delete(s.speakers, roomID)
+			delete(s.floors, roomID)
+		}
 		log.Printf("Participant removed: %s", participant.Username)
 	}
+	s.mu.Unlock()
+
+	if pc != nil {
+		pc.Close()
+	}
+
+	s.requestKeyframes(keyframes)
 }
 
-func (s *SFUManager) handleTrack(senderID string, remoteTrack *webrtc.TrackRemote) {
+// SetSpotlight updates the spotlight of a room. Spotlighted video is
+// forwarded at its highest simulcast layer to every subscriber, while the
+// other video of the room drops to its lowest layer to make room for it.
+func (s *SFUManager) SetSpotlight(roomID string, spotlight []models.Spotlight) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	if len(spotlight) == 0 {
+		delete(s.spotlights, roomID)
+	} else {
+		s.spotlights[roomID] = append([]models.Spotlight(nil), spotlight...)
+	}
 
-	// Create a new local track
-	trackLocal, err := webrtc.NewTrackLocalStaticRTP(remoteTrack.Codec().RTPCodecCapability, remoteTrack.ID(), remoteTrack.StreamID())
-	if err != nil {
-		log.Printf("Failed to create new track: %v", err)
-		return
+	var keyframes []keyframeRequest
+	for participantID, forwarders := range s.forwarders {
+		if s.rooms[participantID] != roomID {
+			continue
+		}
+		for _, fwd := range forwarders {
+			if ssrc, changed := fwd.setTarget(s.preferredLayer(fwd)); changed {
+				keyframes = append(keyframes, keyframeRequest{fwd.senderID, ssrc})
+			}
+		}
+	}
+	keyframes = append(keyframes, s.applyLastN(roomID)...)
+	s.mu.Unlock()
+
+	s.requestKeyframes(keyframes)
+}
+
+// SetFloor puts a room under floor control: only the presenter's screen track
+// is forwarded, and none while the floor is free. Camera video is unaffected.
+// Rooms without participants in the SFU are ignored.
+func (s *SFUManager) SetFloor(roomID, presenterID string) {
+	s.mu.Lock()
+	var keyframes []keyframeRequest
+	if _, ok := s.recent[roomID]; ok {
+		s.floors[roomID] = presenterID
+		keyframes = s.applyLastN(roomID)
 	}
+	s.mu.Unlock()
+
+	s.requestKeyframes(keyframes)
+}
 
-	// Store the track
-	s.trackLocals[senderID][remoteTrack.ID()] = trackLocal
+// SetScreenTrack marks which of a participant's tracks carries their screen
+// share, by track or stream ID, as signaled by the client. An empty ID clears it.
+func (s *SFUManager) SetScreenTrack(participantID, trackID string) {
+	s.mu.Lock()
+	if trackID == "" {
+		delete(s.screens, participantID)
+	} else {
+		s.screens[participantID] = trackID
+	}
+	var keyframes []keyframeRequest
+	if roomID, ok := s.rooms[participantID]; ok {
+		keyframes = s.applyLastN(roomID)
+	}
+	s.mu.Unlock()
 
-	// Forward the track to all other participants
-	for participantID, pc := range s.peerConnections {
-		if participantID == senderID {
+	s.requestKeyframes(keyframes)
+}
+
+// SetPinned replaces the participants or tracks a subscriber always receives,
+// whether or not they are among the last N speakers
+func (s *SFUManager) SetPinned(subscriberID string, pins []models.Spotlight) {
+	s.mu.Lock()
+	if len(pins) == 0 {
+		delete(s.pins, subscriberID)
+	} else {
+		s.pins[subscriberID] = append([]models.Spotlight(nil), pins...)
+	}
+	var keyframes []keyframeRequest
+	if roomID, ok := s.rooms[subscriberID]; ok {
+		keyframes = s.applyLastN(roomID)
+	}
+	s.mu.Unlock()
+
+	s.requestKeyframes(keyframes)
+}
+
+// promoteSpeakers moves the active speakers of a room to the front of its
+// recently active participants, dominant speaker first, and switches the
+// video subscribers receive accordingly
+func (s *SFUManager) promoteSpeakers(roomID string, speakers []ActiveSpeaker) {
+	s.mu.Lock()
+	recent := s.recent[roomID]
+	for i := len(speakers) - 1; i >= 0; i-- {
+		id := speakers[i].ParticipantID
+		if _, ok := s.rooms[id]; ok {
+			recent = append([]string{id}, removeID(recent, id)...)
+		}
+	}
+	s.recent[roomID] = recent
+	keyframes := s.applyLastN(roomID)
+	s.mu.Unlock()
+
+	s.requestKeyframes(keyframes)
+}
+
+// applyLastN pauses the video subscribers of a room should no longer receive
+// and resumes the video they should, returning the keyframes resumed tracks
+// need. Callers must hold the lock.
+func (s *SFUManager) applyLastN(roomID string) []keyframeRequest {
+	var keyframes []keyframeRequest
+	for senderID, forwarders := range s.forwarders {
+		if s.rooms[senderID] != roomID {
 			continue
 		}
+		for _, fwd := range forwarders {
+			resumed := false
+			for _, subscriberID := range fwd.subscriberIDs() {
+				forward := s.forwards(subscriberID, fwd)
+				if fwd.setPaused(subscriberID, !forward) && forward {
+					resumed = true
+				}
+			}
+			if resumed {
+				keyframes = append(keyframes, keyframeRequest{senderID, fwd.targetSSRC()})
+			}
+		}
+	}
+	return keyframes
+}
 
-		// Add the track to the peer connection
-		if _, err = pc.AddTrack(trackLocal); err != nil {
-			log.Printf("Failed to add track to peer %s: %v", participantID, err)
+// forwards reports whether a subscriber should receive a track: all audio,
+// screen tracks under floor control only from the presenter, and otherwise
+// video that is pinned, spotlighted or sent by one of the subscriber's last N
+// speakers. Callers must hold the lock.
+func (s *SFUManager) forwards(subscriberID string, fwd *trackForwarder) bool {
+	if fwd.kind != webrtc.RTPCodecTypeVideo {
+		return true
+	}
+	roomID := s.rooms[fwd.senderID]
+	if presenterID, ok := s.floors[roomID]; ok && s.isScreenTrack(fwd) {
+		return fwd.senderID == presenterID
+	}
+	if s.lastN <= 0 {
+		return true
+	}
+	if fwd.pinnedBy(s.spotlights[roomID]) || fwd.pinnedBy(s.pins[subscriberID]) {
+		return true
+	}
+	rank := 0
+	for _, id := range s.recent[roomID] {
+		if id == subscriberID {
 			continue
 		}
+		if id == fwd.senderID {
+			return true
+		}
+		if rank++; rank >= s.lastN {
+			return false
+		}
+	}
+	return false
+}
+
+// isScreenTrack reports whether a track is its sender's signaled screen
+// share. Callers must hold the lock.
+func (s *SFUManager) isScreenTrack(fwd *trackForwarder) bool {
+	id, ok := s.screens[fwd.senderID]
+	return ok && (id == fwd.trackID || id == fwd.streamID)
+}
+
+// subscribe forwards a track to a subscriber through a track of its own, so
+// that it can be paused for that subscriber alone. Callers must hold the lock.
+func (s *SFUManager) subscribe(subscriberID string, pc *webrtc.PeerConnection, fwd *trackForwarder) error {
+	local, err := webrtc.NewTrackLocalStaticRTP(fwd.codec, fwd.trackID, fwd.streamID)
+	if err != nil {
+		return err
+	}
+	sender, err := pc.AddTrack(local)
+	if err != nil {
+		return err
+	}
+	fwd.subscribe(subscriberID, local, sender, !s.forwards(subscriberID, fwd))
+	go s.renegotiate(subscriberID, pc, s.negotiations[subscriberID])
+	return nil
+}
+
+// removeID returns ids without id
+func removeID(ids []string, id string) []string {
+	kept := make([]string, 0, len(ids))
+	for _, other := range ids {
+		if other != id {
+			kept = append(kept, other)
+		}
+	}
+	return kept
+}
+
+// OnActiveSpeakers reports the active speakers of every room with speech to
+// notify once per interval, plus once more when the room falls silent. An
+// interval of zero or less means DefaultActiveSpeakerInterval.
+func (s *SFUManager) OnActiveSpeakers(interval time.Duration, notify ActiveSpeakersFunc) {
+	if interval <= 0 {
+		interval = DefaultActiveSpeakerInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.mu.Lock()
+			trackers := make(map[string]*speakerTracker, len(s.speakers))
+			for roomID, tracker := range s.speakers {
+				trackers[roomID] = tracker
+			}
+			s.mu.Unlock()
+
+			for roomID, tracker := range trackers {
+				if speakers, changed := tracker.tick(); changed {
+					s.promoteSpeakers(roomID, speakers)
+					notify(roomID, speakers)
+				}
+			}
+		}
+	}()
+}
+
+// observeAudioLevel feeds the audio level carried by a packet to the speaker
+// tracker of the sender's room
+func (s *SFUManager) observeAudioLevel(senderID string, extensionID int, packet *rtp.Packet) {
+	raw := packet.GetExtension(uint8(extensionID))
+	if raw == nil {
+		return
+	}
+	var level rtp.AudioLevelExtension
+	if err := level.Unmarshal(raw); err != nil {
+		return
+	}
+
+	s.mu.RLock()
+	roomID, ok := s.rooms[senderID]
+	tracker := s.speakers[roomID]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if tracker == nil {
+		// Only the room's first packet takes the write lock
+		s.mu.Lock()
+		if tracker = s.speakers[roomID]; tracker == nil {
+			tracker = newSpeakerTracker()
+			s.speakers[roomID] = tracker
+		}
+		s.mu.Unlock()
+	}
+	tracker.observe(senderID, level.Level)
+}
+
+// audioLevelExtensionID returns the negotiated ID of the audio level header
+// extension of a receiver, or 0 if it was not negotiated
+func audioLevelExtensionID(receiver *webrtc.RTPReceiver) int {
+	for _, ext := range receiver.GetParameters().HeaderExtensions {
+		if ext.URI == sdp.AudioLevelURI {
+			return ext.ID
+		}
+	}
+	return 0
+}
+
+func (s *SFUManager) handleTrack(senderID string, remoteTrack *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+	s.mu.Lock()
+
+	// Simulcast layers of a track arrive as separate remote tracks sharing one
+	// track ID; they are all forwarded through a single local track
+	fwd, ok := s.forwarders[senderID][remoteTrack.ID()]
+	if !ok {
+		fwd = newTrackForwarder(senderID, remoteTrack.ID(), remoteTrack.Kind())
+		fwd.codec, fwd.streamID = remoteTrack.Codec().RTPCodecCapability, remoteTrack.StreamID()
+		s.forwarders[senderID][remoteTrack.ID()] = fwd
 
-		log.Printf("Track forwarded to participant %s", participantID)
+		// Forward the track to the other participants of the room
+		for participantID, pc := range s.peerConnections {
+			if participantID == senderID || s.rooms[participantID] != s.rooms[senderID] {
+				continue
+			}
+			if err := s.subscribe(participantID, pc, fwd); err != nil {
+				log.Printf("Failed to add track to peer %s: %v", participantID, err)
+				continue
+			}
+
+			log.Printf("Track forwarded to participant %s", participantID)
+		}
 	}
+	fwd.addLayer(remoteTrack.RID(), remoteTrack.SSRC())
+	ssrc, changed := fwd.setTarget(s.preferredLayer(fwd))
+	s.mu.Unlock()
 
-	// Start forwarding RTP packets
+	if changed {
+		s.requestKeyframe(senderID, ssrc)
+	}
+
+	audioLevelID := 0
+	if remoteTrack.Kind() == webrtc.RTPCodecTypeAudio {
+		audioLevelID = audioLevelExtensionID(receiver)
+	}
+
+	// Start forwarding RTP packets of the selected layer
 	go func() {
 		for {
 			packet, _, err := remoteTrack.ReadRTP()
 			if err != nil {
 				return
 			}
-
-			// Write RTP packets to all track locals
-			for participantID := range s.peerConnections {
-				if participantID == senderID {
-					continue
-				}
-
-				if track, ok := s.trackLocals[senderID][remoteTrack.ID()]; ok {
-					if err := track.WriteRTP(packet); err != nil {
-						log.Printf("Failed to write RTP to participant %s: %v", participantID, err)
-					}
+			if audioLevelID != 0 {
+				s.observeAudioLevel(senderID, audioLevelID, packet)
+			}
+			if !fwd.rewrite(remoteTrack.RID(), packet) {
+				continue
+			}
+			for _, local := range fwd.activeTracks() {
+				if err := local.WriteRTP(packet); err != nil {
+					log.Printf("Failed to forward RTP of participant %s: %v", senderID, err)
 				}
 			}
 		}
 	}()
 }
 
-func (s *SFUManager) HandleOffer(participantID string, offer webrtc.SessionDescription) (webrtc.SessionDescription, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// preferredLayer picks the layer a track should be forwarded at: the highest
+// unless its room has a spotlight the track is not part of. Callers must hold the lock.
+func (s *SFUManager) preferredLayer(fwd *trackForwarder) string {
+	spotlight := s.spotlights[s.rooms[fwd.senderID]]
+	return fwd.pickLayer(len(spotlight) == 0 || fwd.pinnedBy(spotlight))
+}
 
-	pc, exists := s.peerConnections[participantID]
-	if !exists {
-		return webrtc.SessionDescription{}, fmt.Errorf("no peer connection found for participant %s", participantID)
+// keyframeRequest is a keyframe to ask a sender for once the lock is released
+type keyframeRequest struct {
+	senderID string
+	ssrc     webrtc.SSRC
+}
+
+// requestKeyframes sends the keyframe requests collected under the lock
+func (s *SFUManager) requestKeyframes(keyframes []keyframeRequest) {
+	for _, k := range keyframes {
+		s.requestKeyframe(k.senderID, k.ssrc)
+	}
+}
+
+// requestKeyframe asks a sender for a keyframe on one of its streams, so that
+// subscribers can start decoding a layer that was just switched to
+func (s *SFUManager) requestKeyframe(senderID string, ssrc webrtc.SSRC) {
+	s.mu.RLock()
+	pc, ok := s.peerConnections[senderID]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if err := pc.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(ssrc)}}); err != nil {
+		log.Printf("Failed to request keyframe from participant %s: %v", senderID, err)
+	}
+}
+
+// HandleOffer applies a participant's offer and sends the SFU's answer. An
+// offer of the SFU's own still waiting for an answer is rolled back, so the
+// participant's offer wins; the SFU offers again once it is answered.
+func (s *SFUManager) HandleOffer(participantID string, offer webrtc.SessionDescription) error {
+	pc, negotiation, err := s.peer(participantID)
+	if err != nil {
+		return err
 	}
+	negotiation.Lock()
+	defer negotiation.Unlock()
 
+	if pc.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+		if err := pc.SetLocalDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeRollback}); err != nil {
+			return err
+		}
+	}
 	if err := pc.SetRemoteDescription(offer); err != nil {
-		return webrtc.SessionDescription{}, err
+		return err
 	}
 
 	answer, err := pc.CreateAnswer(nil)
 	if err != nil {
-		return webrtc.SessionDescription{}, err
+		return err
 	}
 
 	if err = pc.SetLocalDescription(answer); err != nil {
-		return webrtc.SessionDescription{}, err
+		return err
 	}
 
-	return answer, nil
+	s.sendSignal(participantID, SFUSignal{Answer: &answer})
+	return nil
+}
+
+// HandleAnswer applies a participant's answer to an offer of the SFU
+func (s *SFUManager) HandleAnswer(participantID string, answer webrtc.SessionDescription) error {
+	pc, negotiation, err := s.peer(participantID)
+	if err != nil {
+		return err
+	}
+	negotiation.Lock()
+	defer negotiation.Unlock()
+	return pc.SetRemoteDescription(answer)
+}
+
+// AddICECandidate adds an ICE candidate of a participant
+func (s *SFUManager) AddICECandidate(participantID string, candidate webrtc.ICECandidateInit) error {
+	pc, _, err := s.peer(participantID)
+	if err != nil {
+		return err
+	}
+	return pc.AddICECandidate(candidate)
+}
+
+// peer returns the peer connection of a participant and the lock serializing its negotiation
+func (s *SFUManager) peer(participantID string) (*webrtc.PeerConnection, *sync.Mutex, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pc, exists := s.peerConnections[participantID]
+	if !exists {
+		return nil, nil, fmt.Errorf("no peer connection found for participant %s", participantID)
+	}
+	return pc, s.negotiations[participantID], nil
+}
+
+// renegotiate offers a participant the tracks it receives after they changed.
+// Nothing is offered while another negotiation is under way; the peer
+// connection asks again once it is stable. Nor is an offer that changes
+// nothing: pion keeps asking while a transceiver the participant wants to
+// receive on has no track yet, which would otherwise offer in a loop. As pion
+// then stops asking, tracks added or removed renegotiate explicitly.
+func (s *SFUManager) renegotiate(participantID string, pc *webrtc.PeerConnection, negotiation *sync.Mutex) {
+	negotiation.Lock()
+	defer negotiation.Unlock()
+
+	if pc.SignalingState() != webrtc.SignalingStateStable || pc.ConnectionState() == webrtc.PeerConnectionStateClosed {
+		return
+	}
+	offer, err := pc.CreateOffer(nil)
+	if err == nil && sameSession(offer, pc.CurrentLocalDescription()) {
+		return
+	}
+	if err == nil {
+		err = pc.SetLocalDescription(offer)
+	}
+	if err != nil {
+		log.Printf("Failed to renegotiate with participant %s: %v", participantID, err)
+		return
+	}
+	s.sendSignal(participantID, SFUSignal{Offer: pc.LocalDescription()})
+}
+
+// sameSession reports whether a description only differs from the current
+// one in its origin line
+func sameSession(desc webrtc.SessionDescription, current *webrtc.SessionDescription) bool {
+	if current == nil || current.Type != desc.Type {
+		return false
+	}
+	parsed, err := desc.Unmarshal()
+	if err != nil {
+		return false
+	}
+	currentParsed, err := current.Unmarshal()
+	if err != nil {
+		return false
+	}
+	parsed.Origin = currentParsed.Origin
+	a, errA := parsed.Marshal()
+	b, errB := currentParsed.Marshal()
+	return errA == nil && errB == nil && string(a) == string(b)
+}
+
+// sendSignal delivers an SFU signal to a participant, if anyone listens
+func (s *SFUManager) sendSignal(participantID string, signal SFUSignal) {
+	s.mu.RLock()
+	send, participant, roomID := s.signal, s.participants[participantID], s.rooms[participantID]
+	s.mu.RUnlock()
+	if send != nil && participant != nil {
+		send(roomID, participant, signal)
+	}
+}
+
+// trackForwarder forwards one layer of a sender's track to its subscribers,
+// rewriting sequence numbers and timestamps so that layer switches look like
+// one continuous stream
+type trackForwarder struct {
+	senderID string
+	trackID  string
+	kind     webrtc.RTPCodecType
+	codec    webrtc.RTPCodecCapability
+	streamID string
+
+	mu        sync.Mutex
+	layers    map[string]webrtc.SSRC // Received layers by RID; "" without simulcast
+	current   string                 // Layer being forwarded
+	target    string                 // Layer to switch to on its next packet
+	started   bool
+	seqOffset uint16
+	tsOffset  uint32
+	lastSeq   uint16
+	lastTS    uint32
+
+	subscribers map[string]*subscription // By subscriber ID
+}
+
+// subscription is a subscriber's copy of a forwarded track
+type subscription struct {
+	local  *webrtc.TrackLocalStaticRTP
+	sender *webrtc.RTPSender
+	paused bool // Not among the subscriber's last N, so nothing is written
+}
+
+func newTrackForwarder(senderID, trackID string, kind webrtc.RTPCodecType) *trackForwarder {
+	return &trackForwarder{
+		senderID:    senderID,
+		trackID:     trackID,
+		kind:        kind,
+		subscribers: make(map[string]*subscription),
+		layers:      make(map[string]webrtc.SSRC),
+	}
+}
+
+// pinnedBy reports whether the track's sender, or the track itself, is in pins
+func (f *trackForwarder) pinnedBy(pins []models.Spotlight) bool {
+	for _, pin := range pins {
+		if pin.ParticipantID == f.senderID && (pin.TrackID == "" || pin.TrackID == f.trackID) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribe adds a subscriber's copy of the track
+func (f *trackForwarder) subscribe(subscriberID string, local *webrtc.TrackLocalStaticRTP, sender *webrtc.RTPSender, paused bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribers[subscriberID] = &subscription{local: local, sender: sender, paused: paused}
+}
+
+// unsubscribe drops a subscriber that left
+func (f *trackForwarder) unsubscribe(subscriberID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subscribers, subscriberID)
+}
+
+// senders returns the senders of the track by subscriber ID
+func (f *trackForwarder) senders() map[string]*webrtc.RTPSender {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	senders := make(map[string]*webrtc.RTPSender, len(f.subscribers))
+	for id, sub := range f.subscribers {
+		senders[id] = sub.sender
+	}
+	return senders
+}
+
+// subscriberIDs returns the IDs of the track's subscribers
+func (f *trackForwarder) subscriberIDs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]string, 0, len(f.subscribers))
+	for id := range f.subscribers {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// setPaused pauses or resumes the track for a subscriber and reports whether that changed anything
+func (f *trackForwarder) setPaused(subscriberID string, paused bool) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub, ok := f.subscribers[subscriberID]
+	if !ok || sub.paused == paused {
+		return false
+	}
+	sub.paused = paused
+	return true
+}
+
+// activeTracks returns the subscriber tracks packets are written to
+func (f *trackForwarder) activeTracks() []*webrtc.TrackLocalStaticRTP {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tracks := make([]*webrtc.TrackLocalStaticRTP, 0, len(f.subscribers))
+	for _, sub := range f.subscribers {
+		if !sub.paused {
+			tracks = append(tracks, sub.local)
+		}
+	}
+	return tracks
+}
+
+// targetSSRC returns the SSRC of the layer being switched to
+func (f *trackForwarder) targetSSRC() webrtc.SSRC {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.layers[f.target]
+}
+
+// addLayer records a received layer of the track
+func (f *trackForwarder) addLayer(rid string, ssrc webrtc.SSRC) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.layers[rid] = ssrc
+}
+
+// pickLayer returns the highest or the lowest received layer
+func (f *trackForwarder) pickLayer(highest bool) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	picked, pickedRank := "", 0
+	first := true
+	for rid := range f.layers {
+		rank := layerRank(rid)
+		if first || (highest && rank > pickedRank) || (!highest && rank < pickedRank) {
+			picked, pickedRank, first = rid, rank, false
+		}
+	}
+	return picked
+}
+
+// setTarget switches the track to a layer and returns the layer's SSRC.
+// It reports whether a video track changed layers and so needs a keyframe.
+func (f *trackForwarder) setTarget(rid string) (webrtc.SSRC, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if rid == f.target {
+		return 0, false
+	}
+	f.target = rid
+	return f.layers[rid], f.kind == webrtc.RTPCodecTypeVideo
+}
+
+// rewrite prepares a packet received on a layer for forwarding and reports
+// whether it should be forwarded at all
+func (f *trackForwarder) rewrite(rid string, packet *rtp.Packet) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if rid != f.current {
+		if rid != f.target {
+			return false
+		}
+		f.current = rid
+		if f.started {
+			f.seqOffset = f.lastSeq + 1 - packet.SequenceNumber
+			f.tsOffset = f.lastTS + switchTimestampGap - packet.Timestamp
+		}
+	}
+	packet.SequenceNumber += f.seqOffset
+	packet.Timestamp += f.tsOffset
+	f.lastSeq, f.lastTS, f.started = packet.SequenceNumber, packet.Timestamp, true
+	return true
+}
+
+// layerRank orders simulcast layers by quality; unknown RIDs rank lowest
+func layerRank(rid string) int {
+	for i, layer := range simulcastLayers {
+		if layer == rid {
+			return i
+		}
+	}
+	return -1
 }
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+
+	"zeem/internal/models"
+)
+
+func TestTrackForwarderLayerSwitch(t *testing.T) {
+	fwd := newTrackForwarder("sender", "video", webrtc.RTPCodecTypeVideo)
+	fwd.addLayer("q", 1)
+	fwd.addLayer("f", 3)
+	fwd.addLayer("h", 2)
+
+	if layer := fwd.pickLayer(true); layer != "f" {
+		t.Errorf("Expected the highest layer, got %q", layer)
+	}
+	if layer := fwd.pickLayer(false); layer != "q" {
+		t.Errorf("Expected the lowest layer, got %q", layer)
+	}
+
+	if ssrc, changed := fwd.setTarget("q"); !changed || ssrc != 1 {
+		t.Fatalf("Expected a switch to q to need a keyframe, got %d %v", ssrc, changed)
+	}
+	if _, changed := fwd.setTarget("q"); changed {
+		t.Error("Expected no keyframe when staying on a layer")
+	}
+	if fwd.rewrite("f", &rtp.Packet{Header: rtp.Header{SequenceNumber: 500}}) {
+		t.Error("Expected packets of other layers to be dropped")
+	}
+	if !fwd.rewrite("q", &rtp.Packet{Header: rtp.Header{SequenceNumber: 10, Timestamp: 1000}}) {
+		t.Fatal("Expected packets of the target layer to be forwarded")
+	}
+
+	fwd.setTarget("f")
+	if !fwd.rewrite("q", &rtp.Packet{Header: rtp.Header{SequenceNumber: 11, Timestamp: 1000}}) {
+		t.Error("Expected the old layer to be forwarded until the new one arrives")
+	}
+	packet := &rtp.Packet{Header: rtp.Header{SequenceNumber: 9000, Timestamp: 50000}}
+	if !fwd.rewrite("f", packet) {
+		t.Fatal("Expected the new layer to take over")
+	}
+	if packet.SequenceNumber != 12 || packet.Timestamp != 1000+switchTimestampGap {
+		t.Errorf("Expected a continuous stream, got seq %d ts %d", packet.SequenceNumber, packet.Timestamp)
+	}
+	if fwd.rewrite("q", &rtp.Packet{Header: rtp.Header{SequenceNumber: 12}}) {
+		t.Error("Expected the old layer to be dropped after the switch")
+	}
+}
+
+func TestSFUSpotlightLayers(t *testing.T) {
+	s := NewSFUManager()
+	s.rooms["speaker"] = "room"
+	s.rooms["other"] = "room"
+	speaker := newTrackForwarder("speaker", "cam", webrtc.RTPCodecTypeVideo)
+	other := newTrackForwarder("other", "cam", webrtc.RTPCodecTypeVideo)
+	for _, fwd := range []*trackForwarder{speaker, other} {
+		fwd.addLayer("q", 1)
+		fwd.addLayer("f", 2)
+		s.forwarders[fwd.senderID] = map[string]*trackForwarder{fwd.trackID: fwd}
+	}
+
+	if s.preferredLayer(other) != "f" {
+		t.Error("Expected the highest layer without a spotlight")
+	}
+	s.SetSpotlight("room", []models.Spotlight{{ParticipantID: "speaker"}})
+	if speaker.target != "f" || other.target != "q" {
+		t.Errorf("Expected the spotlight at the highest layer and the rest at the lowest, got %q and %q", speaker.target, other.target)
+	}
+	s.SetSpotlight("room", nil)
+	if other.target != "f" {
+		t.Errorf("Expected the highest layer again once the spotlight is cleared, got %q", other.target)
+	}
+}
+
+func TestSFULastN(t *testing.T) {
+	s := NewSFUManager()
+	s.SetLastN(1)
+	participants := []string{"alice", "bob", "carol"}
+	video := make(map[string]*trackForwarder)
+	for _, id := range participants {
+		s.rooms[id] = "room"
+		s.recent["room"] = append(s.recent["room"], id)
+		video[id] = newTrackForwarder(id, "cam", webrtc.RTPCodecTypeVideo)
+		s.forwarders[id] = map[string]*trackForwarder{"cam": video[id]}
+	}
+	audio := newTrackForwarder("bob", "mic", webrtc.RTPCodecTypeAudio)
+	s.forwarders["bob"]["mic"] = audio
+	for _, fwd := range append([]*trackForwarder{audio}, video["alice"], video["bob"], video["carol"]) {
+		for _, id := range participants {
+			if id != fwd.senderID {
+				fwd.subscribe(id, nil, nil, !s.forwards(id, fwd))
+			}
+		}
+	}
+	receives := func(subscriberID, senderID string) bool {
+		return !video[senderID].subscribers[subscriberID].paused
+	}
+
+	if !receives("carol", "alice") || receives("carol", "bob") || !receives("alice", "bob") {
+		t.Error("Expected everyone to receive the first other participant to join")
+	}
+	if audio.subscribers["alice"].paused || audio.subscribers["carol"].paused {
+		t.Error("Expected audio to reach everyone")
+	}
+
+	s.promoteSpeakers("room", []ActiveSpeaker{{ParticipantID: "carol"}})
+	if !receives("alice", "carol") || !receives("bob", "carol") || receives("alice", "bob") {
+		t.Error("Expected the active speaker's video to replace the others")
+	}
+	if !receives("carol", "alice") {
+		t.Error("Expected the speaker to keep receiving the most recent other speaker")
+	}
+
+	s.SetPinned("alice", []models.Spotlight{{ParticipantID: "bob"}})
+	if !receives("alice", "bob") || !receives("alice", "carol") || receives("carol", "bob") {
+		t.Error("Expected pins to add video for the subscriber that pinned it only")
+	}
+
+	s.SetPinned("alice", nil)
+	s.SetSpotlight("room", []models.Spotlight{{ParticipantID: "bob", TrackID: "cam"}})
+	if !receives("alice", "bob") || !receives("carol", "bob") {
+		t.Error("Expected spotlighted video to reach everyone")
+	}
+}
+
+func TestSFUFloor(t *testing.T) {
+	s := NewSFUManager()
+	participants := []string{"alice", "bob", "carol"}
+	screens := make(map[string]*trackForwarder)
+	cameras := make(map[string]*trackForwarder)
+	for _, id := range participants {
+		s.rooms[id] = "room"
+		s.recent["room"] = append(s.recent["room"], id)
+		screens[id] = newTrackForwarder(id, "screen", webrtc.RTPCodecTypeVideo)
+		cameras[id] = newTrackForwarder(id, "camera", webrtc.RTPCodecTypeVideo)
+		s.forwarders[id] = map[string]*trackForwarder{"screen": screens[id], "camera": cameras[id]}
+	}
+	audio := newTrackForwarder("bob", "mic", webrtc.RTPCodecTypeAudio)
+	s.forwarders["bob"]["mic"] = audio
+	for _, forwarders := range s.forwarders {
+		for _, fwd := range forwarders {
+			for _, id := range participants {
+				if id != fwd.senderID {
+					fwd.subscribe(id, nil, nil, !s.forwards(id, fwd))
+				}
+			}
+		}
+	}
+	receives := func(subscriberID, senderID string) bool {
+		return !screens[senderID].subscribers[subscriberID].paused
+	}
+
+	s.SetFloor("room", "")
+	if !receives("alice", "bob") {
+		t.Error("Expected screen tracks nobody signaled to be forwarded as usual")
+	}
+	for _, id := range participants {
+		s.SetScreenTrack(id, "screen")
+	}
+	if receives("alice", "bob") || receives("bob", "carol") {
+		t.Error("Expected no screen share while the floor is free")
+	}
+	if cameras["bob"].subscribers["alice"].paused || audio.subscribers["alice"].paused {
+		t.Error("Expected camera video and audio to reach everyone")
+	}
+
+	s.SetFloor("room", "bob")
+	if !receives("alice", "bob") || !receives("carol", "bob") || receives("bob", "alice") || receives("bob", "carol") {
+		t.Error("Expected only the presenter's screen share to be forwarded")
+	}
+
+	s.SetFloor("room", "carol")
+	if receives("alice", "bob") || !receives("alice", "carol") {
+		t.Error("Expected the screen share to follow the floor")
+	}
+	if cameras["bob"].subscribers["alice"].paused {
+		t.Error("Expected camera video to stay under last-N")
+	}
+
+	s.SetScreenTrack("bob", "")
+	if !receives("alice", "bob") {
+		t.Error("Expected a stopped screen share to be forwarded as regular video")
+	}
+
+	s.SetFloor("other", "alice")
+	if _, ok := s.floors["other"]; ok {
+		t.Error("Expected rooms without participants to be ignored")
+	}
+}
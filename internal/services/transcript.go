@@ -0,0 +1,126 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	// Embedded zone database so timezone parameters work on minimal images
+	_ "time/tzdata"
+
+	"zeem/internal/models"
+)
+
+// TranscriptFormat selects how a chat transcript is rendered
+type TranscriptFormat string
+
+const (
+	// TranscriptJSON renders the transcript as a JSON document
+	TranscriptJSON TranscriptFormat = "json"
+	// TranscriptText renders the transcript as plain text, one message per line
+	TranscriptText TranscriptFormat = "text"
+	// TranscriptHTML renders the transcript as an escaped HTML page
+	TranscriptHTML TranscriptFormat = "html"
+)
+
+// ErrUnknownTranscriptFormat is returned for unsupported transcript formats
+var ErrUnknownTranscriptFormat = errors.New("unknown transcript format")
+
+// transcriptTimeLayout is the timestamp layout used in text and HTML transcripts
+const transcriptTimeLayout = "2006-01-02 15:04:05 MST"
+
+// transcriptEntry is one rendered line of a transcript
+type transcriptEntry struct {
+	ID         string `json:"id"`
+	SenderID   string `json:"senderId"`
+	SenderName string `json:"senderName"`
+	Time       string `json:"time"`
+	Timestamp  int64  `json:"timestamp"`
+	Content    string `json:"content"`
+	Edited     bool   `json:"edited,omitempty"`
+	Deleted    bool   `json:"deleted,omitempty"`
+	Attachment string `json:"attachment,omitempty"`
+}
+
+var transcriptTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Chat transcript - {{.RoomID}}</title>
+</head>
+<body>
+<h1>Chat transcript - {{.RoomID}}</h1>
+<ul>
+{{- range .Entries}}
+<li><time datetime="{{.Time}}">{{.Time}}</time> <strong>{{.SenderName}}</strong>: {{if .Deleted}}<em>message deleted</em>{{else}}{{.Content}}{{if .Attachment}} <em>[file: {{.Attachment}}]</em>{{end}}{{if .Edited}} <em>(edited)</em>{{end}}{{end}}</li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+// RenderTranscript writes a room's chat messages to w in the given format,
+// with timestamps shown in loc
+func RenderTranscript(w io.Writer, roomID string, messages []models.ChatMessage, format TranscriptFormat, loc *time.Location) error {
+	entries := make([]transcriptEntry, 0, len(messages))
+	for _, m := range messages {
+		var attachment string
+		if m.Attachment != nil {
+			attachment = m.Attachment.Name
+		}
+		entries = append(entries, transcriptEntry{
+			ID:         m.ID,
+			SenderID:   m.SenderID,
+			SenderName: m.SenderName,
+			Time:       time.Unix(m.Timestamp, 0).In(loc).Format(transcriptTimeLayout),
+			Timestamp:  m.Timestamp,
+			Content:    m.Content,
+			Edited:     m.EditedAt != 0,
+			Deleted:    m.Deleted,
+			Attachment: attachment,
+		})
+	}
+
+	switch format {
+	case TranscriptJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{
+			"roomId":   roomID,
+			"timezone": loc.String(),
+			"messages": entries,
+		})
+
+	case TranscriptText:
+		for _, e := range entries {
+			content := e.Content
+			if e.Deleted {
+				content = "(message deleted)"
+			} else {
+				if e.Attachment != "" {
+					content = strings.TrimSpace(content + " [file: " + e.Attachment + "]")
+				}
+				if e.Edited {
+					content += " (edited)"
+				}
+			}
+			if _, err := fmt.Fprintf(w, "[%s] %s: %s\n", e.Time, e.SenderName, content); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case TranscriptHTML:
+		return transcriptTemplate.Execute(w, map[string]interface{}{
+			"RoomID":  roomID,
+			"Entries": entries,
+		})
+
+	default:
+		return ErrUnknownTranscriptFormat
+	}
+}
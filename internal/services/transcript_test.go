@@ -0,0 +1,62 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"zeem/internal/models"
+)
+
+func TestRenderTranscript(t *testing.T) {
+	messages := []models.ChatMessage{
+		{ID: "1", SenderName: "Alice", Content: "<b>hi</b>", Timestamp: 0},
+		{ID: "2", SenderName: "Bob", Content: "fixed", Timestamp: 60, EditedAt: 90},
+		{ID: "3", SenderName: "Bob", Deleted: true, Timestamp: 120},
+	}
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+
+	var text bytes.Buffer
+	if err := RenderTranscript(&text, "room", messages, TranscriptText, loc); err != nil {
+		t.Fatalf("RenderTranscript failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(text.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", text.String())
+	}
+	if lines[0] != "[1970-01-01 07:00:00 WIB] Alice: <b>hi</b>" {
+		t.Errorf("Unexpected first line %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "fixed (edited)") || !strings.HasSuffix(lines[2], "(message deleted)") {
+		t.Errorf("Unexpected lines %q", lines[1:])
+	}
+
+	var html bytes.Buffer
+	if err := RenderTranscript(&html, "room", messages, TranscriptHTML, time.UTC); err != nil {
+		t.Fatalf("RenderTranscript failed: %v", err)
+	}
+	if strings.Contains(html.String(), "<b>hi</b>") || !strings.Contains(html.String(), "&lt;b&gt;hi&lt;/b&gt;") {
+		t.Errorf("Expected HTML content to be escaped, got %s", html.String())
+	}
+
+	var raw bytes.Buffer
+	if err := RenderTranscript(&raw, "room", messages, TranscriptJSON, loc); err != nil {
+		t.Fatalf("RenderTranscript failed: %v", err)
+	}
+	var doc struct {
+		Timezone string            `json:"timezone"`
+		Messages []json.RawMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(raw.Bytes(), &doc); err != nil || doc.Timezone != "Asia/Jakarta" || len(doc.Messages) != 3 {
+		t.Errorf("Unexpected JSON transcript %s (%v)", raw.String(), err)
+	}
+
+	if err := RenderTranscript(&raw, "room", messages, "pdf", loc); err != ErrUnknownTranscriptFormat {
+		t.Errorf("Expected %v, got %v", ErrUnknownTranscriptFormat, err)
+	}
+}
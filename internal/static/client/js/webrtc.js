@@ -162,7 +162,7 @@ class WebRTCClient {
                     await this.handleIceCandidate(message);
                     break;
                 case 'participant_joined':
-                    console.log('New participant joined:', message.data.username);
+                    console.log('New participant joined:', message.data.displayName);
                     // Create offer for new participant
                     try {
                         const offer = await this.peerConnection.createOffer({
@@ -182,6 +182,12 @@ class WebRTCClient {
                 case 'participant_left':
                     this.handleParticipantLeft(message);
                     break;
+                case 'muted':
+                    this.handleMuted();
+                    break;
+                case 'move_to_room':
+                    await this.moveToRoom(message.data.roomId);
+                    break;
             }
         };
     }
@@ -244,6 +250,32 @@ class WebRTCClient {
         }
     }
 
+    async moveToRoom(roomId) {
+        // Leave the current room and re-join signaling and media in the new one
+        this.socket.onclose = null;
+        this.socket.close();
+        this.peerConnection.close();
+        this.remoteStreams.forEach((stream, id) => {
+            const container = document.getElementById(`container-remote-${id}`);
+            if (container) {
+                container.remove();
+            }
+        });
+        this.remoteStreams.clear();
+
+        this.roomId = roomId;
+        await this.connectSignalingServer();
+        await this.createPeerConnection();
+        await this.addTracksToPeerConnection();
+    }
+
+    handleMuted() {
+        const audioTrack = this.localStream && this.localStream.getAudioTracks()[0];
+        if (audioTrack) {
+            audioTrack.enabled = false;
+        }
+    }
+
     handleParticipantLeft(message) {
         const videoElement = document.getElementById(`video-${message.senderId}`);
         if (videoElement) {
@@ -279,6 +311,7 @@ class WebRTCClient {
         const videoTrack = this.localStream.getVideoTracks()[0];
         if (videoTrack) {
             videoTrack.enabled = !videoTrack.enabled;
+            this.sendMediaState({ video: videoTrack.enabled });
             return videoTrack.enabled;
         }
         return false;
@@ -288,11 +321,22 @@ class WebRTCClient {
         const audioTrack = this.localStream.getAudioTracks()[0];
         if (audioTrack) {
             audioTrack.enabled = !audioTrack.enabled;
+            this.sendMediaState({ audio: audioTrack.enabled });
             return audioTrack.enabled;
         }
         return false;
     }
 
+    sendMediaState(state) {
+        if (this.socket && this.socket.readyState === WebSocket.OPEN) {
+            this.socket.send(JSON.stringify({
+                type: 'media_state',
+                data: state,
+                roomId: this.roomId
+            }));
+        }
+    }
+
     async shareScreen() {
         try {
             const screenStream = await navigator.mediaDevices.getDisplayMedia({ video: true });
@@ -305,12 +349,14 @@ class WebRTCClient {
             // Update local video
             const localVideo = document.getElementById('video-local');
             localVideo.srcObject = screenStream;
+            this.socket.send(JSON.stringify({ type: 'screen_share_start', roomId: this.roomId }));
 
             // Handle stop sharing
             videoTrack.onended = async () => {
                 const cameraTrack = this.localStream.getVideoTracks()[0];
                 await sender.replaceTrack(cameraTrack);
                 localVideo.srcObject = this.localStream;
+                this.socket.send(JSON.stringify({ type: 'screen_share_stop', roomId: this.roomId }));
             };
 
             return true;
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"zeem/internal/models"
+)
+
+var (
+	roomsBucket    = []byte("rooms")
+	chatBucket     = []byte("chat")     // roomID -> seq -> message
+	sessionsBucket = []byte("sessions") // roomID -> participantID -> session
+)
+
+// BoltStore is a Store backed by an embedded BoltDB file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) a BoltDB file at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{roomsBucket, chatBucket, sessionsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// SaveRoom creates or replaces a room record
+func (s *BoltStore) SaveRoom(room RoomRecord) error {
+	return s.put(roomsBucket, room.ID, []byte(room.ID), room)
+}
+
+// GetRoom returns a room record or ErrNotFound
+func (s *BoltStore) GetRoom(roomID string) (RoomRecord, error) {
+	var room RoomRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(roomsBucket).Get([]byte(roomID))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &room)
+	})
+	return room, err
+}
+
+// ListRooms returns all room records
+func (s *BoltStore) ListRooms() ([]RoomRecord, error) {
+	rooms := make([]RoomRecord, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(roomsBucket).ForEach(func(_, data []byte) error {
+			var room RoomRecord
+			if err := json.Unmarshal(data, &room); err != nil {
+				return err
+			}
+			rooms = append(rooms, room)
+			return nil
+		})
+	})
+	return rooms, err
+}
+
+// DeleteRoom removes a room with its chat and sessions
+func (s *BoltStore) DeleteRoom(roomID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(roomID)
+		if err := tx.Bucket(roomsBucket).Delete(key); err != nil {
+			return err
+		}
+		for _, name := range [][]byte{chatBucket, sessionsBucket} {
+			err := tx.Bucket(name).DeleteBucket(key)
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// storedChatMessage is a chat message as stored, including the edit history
+// that is left out of the message's JSON everywhere else
+type storedChatMessage struct {
+	models.ChatMessage
+	Edits []models.ChatEdit `json:"edits,omitempty"`
+}
+
+// SaveChatMessage creates or replaces a chat message, keyed by its sequence number
+func (s *BoltStore) SaveChatMessage(roomID string, message models.ChatMessage) error {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(message.Seq))
+	return s.put(chatBucket, roomID, key, storedChatMessage{ChatMessage: message, Edits: message.Edits})
+}
+
+// ListChatMessages returns a room's messages ordered by sequence number
+func (s *BoltStore) ListChatMessages(roomID string) ([]models.ChatMessage, error) {
+	messages := make([]models.ChatMessage, 0)
+	err := s.forEach(chatBucket, roomID, func(data []byte) error {
+		var message storedChatMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			return err
+		}
+		message.ChatMessage.Edits = message.Edits
+		messages = append(messages, message.ChatMessage)
+		return nil
+	})
+	return messages, err
+}
+
+// SaveSession creates or replaces a participant session record
+func (s *BoltStore) SaveSession(session SessionRecord) error {
+	return s.put(sessionsBucket, session.RoomID, []byte(session.ParticipantID), session)
+}
+
+// ListSessions returns a room's session records ordered by join time
+func (s *BoltStore) ListSessions(roomID string) ([]SessionRecord, error) {
+	sessions := make([]SessionRecord, 0)
+	err := s.forEach(sessionsBucket, roomID, func(data []byte) error {
+		var session SessionRecord
+		if err := json.Unmarshal(data, &session); err != nil {
+			return err
+		}
+		sessions = append(sessions, session)
+		return nil
+	})
+	sortSessions(sessions)
+	return sessions, err
+}
+
+// Close closes the database file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// put stores v as JSON under key. For the rooms bucket the key is stored
+// directly; for the other buckets it goes into the room's nested bucket.
+func (s *BoltStore) put(bucket []byte, roomID string, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if string(bucket) != string(roomsBucket) {
+			if b, err = b.CreateBucketIfNotExists([]byte(roomID)); err != nil {
+				return err
+			}
+		}
+		return b.Put(key, data)
+	})
+}
+
+// forEach calls fn with every value in a room's nested bucket, in key order
+func (s *BoltStore) forEach(bucket []byte, roomID string, fn func(data []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket).Bucket([]byte(roomID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, data []byte) error {
+			return fn(data)
+		})
+	})
+}
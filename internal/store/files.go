@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// FileStore keeps the contents of files shared in rooms
+type FileStore interface {
+	// Save writes a file's contents and returns the number of bytes written
+	Save(roomID, fileID string, r io.Reader) (int64, error)
+	// Open returns a reader for a file's contents, or ErrNotFound
+	Open(roomID, fileID string) (io.ReadCloser, error)
+	// DeleteFile removes a single file
+	DeleteFile(roomID, fileID string) error
+	// DeleteRoom removes every file of a room
+	DeleteRoom(roomID string) error
+}
+
+// DiskFileStore is a FileStore that keeps files in a directory per room
+type DiskFileStore struct {
+	baseDir string
+}
+
+// NewDiskFileStore creates a file store rooted at baseDir, creating it if needed
+func NewDiskFileStore(baseDir string) (*DiskFileStore, error) {
+	if err := os.MkdirAll(baseDir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskFileStore{baseDir: baseDir}, nil
+}
+
+// Save writes a file's contents and returns the number of bytes written
+func (s *DiskFileStore) Save(roomID, fileID string, r io.Reader) (int64, error) {
+	dir := s.roomDir(roomID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, filepath.Base(fileID)), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// Open returns a reader for a file's contents, or ErrNotFound
+func (s *DiskFileStore) Open(roomID, fileID string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.roomDir(roomID), filepath.Base(fileID)))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// DeleteFile removes a single file
+func (s *DiskFileStore) DeleteFile(roomID, fileID string) error {
+	return os.Remove(filepath.Join(s.roomDir(roomID), filepath.Base(fileID)))
+}
+
+// DeleteRoom removes every file of a room
+func (s *DiskFileStore) DeleteRoom(roomID string) error {
+	return os.RemoveAll(s.roomDir(roomID))
+}
+
+// roomDir returns the directory of a room. Room IDs come from clients, so
+// they are hex-encoded to keep them from escaping the base directory.
+func (s *DiskFileStore) roomDir(roomID string) string {
+	return filepath.Join(s.baseDir, hex.EncodeToString([]byte(roomID)))
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"sort"
+	"sync"
+
+	"zeem/internal/models"
+)
+
+// MemoryStore is a Store that keeps everything in process memory.
+// It is the default and loses all data on restart.
+type MemoryStore struct {
+	rooms     map[string]RoomRecord
+	chat      map[string]*roomChat
+	sessions  map[string]map[string]SessionRecord
+	chatLimit int // Public and direct messages kept per room; zero or less keeps all
+	mutex     sync.RWMutex
+}
+
+// roomChat is the stored chat of a room
+type roomChat struct {
+	messages map[int64]models.ChatMessage
+	public   []int64 // Sequence numbers of public messages, ascending
+	direct   []int64 // Sequence numbers of direct messages, ascending
+}
+
+// NewMemoryStore creates an empty in-memory store that keeps every chat message
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithChatLimit(0)
+}
+
+// NewMemoryStoreWithChatLimit creates an empty in-memory store that keeps only
+// the latest limit public and limit direct messages of each room
+func NewMemoryStoreWithChatLimit(limit int) *MemoryStore {
+	return &MemoryStore{
+		rooms:     make(map[string]RoomRecord),
+		chat:      make(map[string]*roomChat),
+		sessions:  make(map[string]map[string]SessionRecord),
+		chatLimit: limit,
+	}
+}
+
+// SaveRoom creates or replaces a room record
+func (s *MemoryStore) SaveRoom(room RoomRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.rooms[room.ID] = room
+	return nil
+}
+
+// GetRoom returns a room record or ErrNotFound
+func (s *MemoryStore) GetRoom(roomID string) (RoomRecord, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return RoomRecord{}, ErrNotFound
+	}
+	return room, nil
+}
+
+// ListRooms returns all room records
+func (s *MemoryStore) ListRooms() ([]RoomRecord, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	rooms := make([]RoomRecord, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	return rooms, nil
+}
+
+// DeleteRoom removes a room with its chat and sessions
+func (s *MemoryStore) DeleteRoom(roomID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.rooms, roomID)
+	delete(s.chat, roomID)
+	delete(s.sessions, roomID)
+	return nil
+}
+
+// SaveChatMessage creates or replaces a chat message, keyed by its sequence
+// number. Beyond the chat limit the oldest message of the same kind is dropped.
+func (s *MemoryStore) SaveChatMessage(roomID string, message models.ChatMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	chat := s.chat[roomID]
+	if chat == nil {
+		chat = &roomChat{messages: make(map[int64]models.ChatMessage)}
+		s.chat[roomID] = chat
+	}
+	if _, ok := chat.messages[message.Seq]; ok {
+		chat.messages[message.Seq] = message
+		return nil
+	}
+
+	chat.messages[message.Seq] = message
+	seqs := &chat.public
+	if message.IsDirect() {
+		seqs = &chat.direct
+	}
+	*seqs = insertSeq(*seqs, message.Seq)
+	if s.chatLimit > 0 && len(*seqs) > s.chatLimit {
+		delete(chat.messages, (*seqs)[0])
+		*seqs = (*seqs)[1:]
+	}
+	return nil
+}
+
+// insertSeq adds seq to the ascending seqs
+func insertSeq(seqs []int64, seq int64) []int64 {
+	i := sort.Search(len(seqs), func(i int) bool { return seqs[i] >= seq })
+	seqs = append(seqs, 0)
+	copy(seqs[i+1:], seqs[i:])
+	seqs[i] = seq
+	return seqs
+}
+
+// ListChatMessages returns a room's messages ordered by sequence number
+func (s *MemoryStore) ListChatMessages(roomID string) ([]models.ChatMessage, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	messages := make([]models.ChatMessage, 0)
+	if chat := s.chat[roomID]; chat != nil {
+		for _, m := range chat.messages {
+			messages = append(messages, m)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Seq < messages[j].Seq })
+	return messages, nil
+}
+
+// SaveSession creates or replaces a participant session record
+func (s *MemoryStore) SaveSession(session SessionRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.sessions[session.RoomID] == nil {
+		s.sessions[session.RoomID] = make(map[string]SessionRecord)
+	}
+	s.sessions[session.RoomID][session.ParticipantID] = session
+	return nil
+}
+
+// ListSessions returns a room's session records ordered by join time
+func (s *MemoryStore) ListSessions(roomID string) ([]SessionRecord, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	sessions := make([]SessionRecord, 0, len(s.sessions[roomID]))
+	for _, session := range s.sessions[roomID] {
+		sessions = append(sessions, session)
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+// Close releases the resources held by the store
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// sortSessions orders sessions by join time, then participant ID
+func sortSessions(sessions []SessionRecord) {
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].JoinedAt != sessions[j].JoinedAt {
+			return sessions[i].JoinedAt < sessions[j].JoinedAt
+		}
+		return sessions[i].ParticipantID < sessions[j].ParticipantID
+	})
+}
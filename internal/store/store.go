@@ -0,0 +1,55 @@
+package store
+
+import (
+	"errors"
+
+	"zeem/internal/models"
+)
+
+// ErrNotFound is returned when a record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// RoomRecord is the persisted description of a room
+type RoomRecord struct {
+	ID          string                `json:"id"`
+	Type        models.ConnectionType `json:"type"`
+	CreatedAt   int64                 `json:"createdAt"`
+	ScheduledAt int64                 `json:"scheduledAt,omitempty"`
+	Capacity    int                   `json:"capacity,omitempty"` // Set for group rooms created with their own capacity
+}
+
+// SessionRecord is the persisted record of one participant's stay in a room
+type SessionRecord struct {
+	RoomID        string      `json:"roomId"`
+	ParticipantID string      `json:"participantId"`
+	Token         string      `json:"token"`
+	DisplayName   string      `json:"displayName"`
+	Role          models.Role `json:"role"`
+	JoinedAt      int64       `json:"joinedAt"`
+	LeftAt        int64       `json:"leftAt,omitempty"`
+}
+
+// Store persists rooms, their chat and participant sessions
+type Store interface {
+	// SaveRoom creates or replaces a room record
+	SaveRoom(room RoomRecord) error
+	// GetRoom returns a room record or ErrNotFound
+	GetRoom(roomID string) (RoomRecord, error)
+	// ListRooms returns all room records
+	ListRooms() ([]RoomRecord, error)
+	// DeleteRoom removes a room with its chat and sessions
+	DeleteRoom(roomID string) error
+
+	// SaveChatMessage creates or replaces a chat message, keyed by its sequence number
+	SaveChatMessage(roomID string, message models.ChatMessage) error
+	// ListChatMessages returns a room's public and direct messages ordered by sequence number
+	ListChatMessages(roomID string) ([]models.ChatMessage, error)
+
+	// SaveSession creates or replaces a participant session record
+	SaveSession(session SessionRecord) error
+	// ListSessions returns a room's session records ordered by join time
+	ListSessions(roomID string) ([]SessionRecord, error)
+
+	// Close releases the resources held by the store
+	Close() error
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"zeem/internal/models"
+)
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+
+	// Rooms
+	if err := s.SaveRoom(RoomRecord{ID: "room-1", Type: models.Broadcasting, ScheduledAt: 1700000000}); err != nil {
+		t.Fatalf("SaveRoom failed: %v", err)
+	}
+	room, err := s.GetRoom("room-1")
+	if err != nil || room.Type != models.Broadcasting || room.ScheduledAt != 1700000000 {
+		t.Errorf("Unexpected room %+v (%v)", room, err)
+	}
+	if _, err := s.GetRoom("missing"); err != ErrNotFound {
+		t.Errorf("Expected %v, got %v", ErrNotFound, err)
+	}
+	if rooms, _ := s.ListRooms(); len(rooms) != 1 {
+		t.Errorf("Expected 1 room, got %d", len(rooms))
+	}
+
+	// Chat
+	for _, m := range []models.ChatMessage{
+		{ID: "b", Seq: 2, Content: "second"},
+		{ID: "a", Seq: 1, Content: "first"},
+		{ID: "c", Seq: 300, Content: "direct", Recipients: []string{"p2"}},
+	} {
+		if err := s.SaveChatMessage("room-1", m); err != nil {
+			t.Fatalf("SaveChatMessage failed: %v", err)
+		}
+	}
+	s.SaveChatMessage("room-1", models.ChatMessage{ID: "b", Seq: 2, Content: "edited",
+		Edits: []models.ChatEdit{{Content: "second", EditedAt: 5}}})
+
+	messages, err := s.ListChatMessages("room-1")
+	if err != nil || len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d (%v)", len(messages), err)
+	}
+	if messages[0].ID != "a" || messages[1].Content != "edited" || !messages[2].IsDirect() {
+		t.Errorf("Unexpected messages %+v", messages)
+	}
+	if edits := messages[1].Edits; len(edits) != 1 || edits[0].Content != "second" {
+		t.Errorf("Expected the edit history to be kept, got %+v", edits)
+	}
+
+	// Sessions
+	s.SaveSession(SessionRecord{RoomID: "room-1", ParticipantID: "p2", JoinedAt: 20})
+	s.SaveSession(SessionRecord{RoomID: "room-1", ParticipantID: "p1", JoinedAt: 10})
+	s.SaveSession(SessionRecord{RoomID: "room-1", ParticipantID: "p1", JoinedAt: 10, LeftAt: 30})
+
+	sessions, err := s.ListSessions("room-1")
+	if err != nil || len(sessions) != 2 || sessions[0].ParticipantID != "p1" || sessions[0].LeftAt != 30 {
+		t.Errorf("Unexpected sessions %+v (%v)", sessions, err)
+	}
+
+	// Deletion
+	if err := s.DeleteRoom("room-1"); err != nil {
+		t.Fatalf("DeleteRoom failed: %v", err)
+	}
+	if messages, _ := s.ListChatMessages("room-1"); len(messages) != 0 {
+		t.Errorf("Expected chat to be deleted with the room, got %d messages", len(messages))
+	}
+	if sessions, _ := s.ListSessions("room-1"); len(sessions) != 0 {
+		t.Errorf("Expected sessions to be deleted with the room, got %d", len(sessions))
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStoreChatLimit(t *testing.T) {
+	s := NewMemoryStoreWithChatLimit(2)
+	for seq := int64(1); seq <= 4; seq++ {
+		s.SaveChatMessage("room-1", models.ChatMessage{Seq: seq})
+	}
+	s.SaveChatMessage("room-1", models.ChatMessage{Seq: 5, Recipients: []string{"p2"}})
+	s.SaveChatMessage("room-1", models.ChatMessage{Seq: 4, Content: "edited"})
+
+	messages, _ := s.ListChatMessages("room-1")
+	if len(messages) != 3 || messages[0].Seq != 3 || messages[1].Content != "edited" || !messages[2].IsDirect() {
+		t.Errorf("Expected the last 2 public messages and the direct one, got %+v", messages)
+	}
+}
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zeem.db")
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore failed: %v", err)
+	}
+	testStore(t, s)
+
+	// Data survives reopening the file
+	s.SaveRoom(RoomRecord{ID: "room-2", Type: models.OneToOne})
+	s.Close()
+
+	s, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore failed: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.GetRoom("room-2"); err != nil {
+		t.Errorf("Expected room to survive reopening, got %v", err)
+	}
+}